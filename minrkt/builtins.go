@@ -0,0 +1,452 @@
+package minrkt
+
+import (
+	"fmt"
+	"sort"
+)
+
+type builtinFunc func(args []interface{}, p Params) (interface{}, TypeEnum, error)
+
+// procedures and constants implemented in go, looked up after local and global identifiers
+var builtins = make(map[string]interface{})
+
+func defineBuiltin(name string, fn builtinFunc) {
+	builtins[name] = builtinValue{name: name, fn: fn}
+}
+
+// builtins accepting keyword arguments, e.g. (with-output-to-file path thunk #:exists 'replace)
+type keywordBuiltinFunc func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error)
+
+type keywordBuiltin struct {
+	// keywords without #:
+	accepted []string
+	fn       keywordBuiltinFunc
+}
+
+var keywordBuiltins = make(map[string]keywordBuiltin)
+
+func (kb keywordBuiltin) accepts(kw string) bool {
+	for _, accepted := range kb.accepted {
+		if accepted == kw {
+			return true
+		}
+	}
+	return false
+}
+
+func defineKeywordBuiltin(name string, accepted []string, fn keywordBuiltinFunc) {
+	keywordBuiltins[name] = keywordBuiltin{accepted, fn}
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return fn(args, nil, p)
+	})
+}
+
+func init() {
+	builtins["empty"] = null
+	builtins["null"] = null
+
+	defineBuiltin("list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return sliceToList(args), TYPE_LIST, nil
+	})
+	defineBuiltin("cons", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("cons", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return &pair{car: args[0], cdr: args[1]}, TYPE_LIST, nil
+	})
+	defineBuiltin("car", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("car", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if l, ok := args[0].(*pair); ok {
+			return l.car, typeOf(l.car), nil
+		}
+		return nil, TYPE_ERROR, contractError("car", "pair?", args[0])
+	})
+	defineBuiltin("cdr", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("cdr", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if l, ok := args[0].(*pair); ok {
+			return l.cdr, typeOf(l.cdr), nil
+		}
+		return nil, TYPE_ERROR, contractError("cdr", "pair?", args[0])
+	})
+	defineBuiltin("null?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("null?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(emptyList)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	builtins["empty?"] = builtinValue{name: "empty?", fn: builtins["null?"].(builtinValue).fn}
+	defineBuiltin("pair?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("pair?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*pair)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("list?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("list?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := listToSlice(args[0])
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("procedure?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("procedure?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return isProcedure(args[0]), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("symbol?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("symbol?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(symbol)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("string?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("string?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(string)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("boolean?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("boolean?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(bool)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("length", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		lists, err := listArgs("length", args, 1)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return int64(len(lists[0])), TYPE_FLOAT64, nil
+	})
+	defineBuiltin("reverse", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		lists, err := listArgs("reverse", args, 1)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		reversed := make([]interface{}, len(lists[0]))
+		for i, v := range lists[0] {
+			reversed[len(reversed)-1-i] = v
+		}
+		return sliceToList(reversed), TYPE_LIST, nil
+	})
+	defineBuiltin("append", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		lists, err := listArgs("append", args, len(args))
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var elements []interface{}
+		for _, l := range lists {
+			elements = append(elements, l...)
+		}
+		return sliceToList(elements), TYPE_LIST, nil
+	})
+
+	// higher-order procedures, procedures are called through applyProcedure so
+	// user functions, lambdas and builtins can all be passed
+	defineNative("map", func(args []interface{}, p *Params, k *kont) step {
+		return mapStep("map", args, p, k)
+	})
+	defineNative("for-each", func(args []interface{}, p *Params, k *kont) step {
+		return mapStep("for-each", args, p, k)
+	})
+	defineBuiltin("andmap", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (andmap f l) is the last result when every result is true
+		var res interface{} = true
+		err := eachElements("andmap", args, p, func(got interface{}) bool {
+			res = got
+			return isTrue(got)
+		})
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return res, typeOf(res), nil
+	})
+	defineBuiltin("ormap", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (ormap f l) is the first true result
+		var res interface{} = false
+		err := eachElements("ormap", args, p, func(got interface{}) bool {
+			res = got
+			return !isTrue(got)
+		})
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return res, typeOf(res), nil
+	})
+	defineBuiltin("filter", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("filter", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		lists, err := listArgs("filter", args[1:], 1)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var kept []interface{}
+		for _, v := range lists[0] {
+			if got, t, err := applySingle(args[0], []interface{}{v}, p); err != nil {
+				return got, t, err
+			} else if isTrue(got) {
+				kept = append(kept, v)
+			}
+		}
+		return sliceToList(kept), TYPE_LIST, nil
+	})
+	defineBuiltin("foldl", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return fold("foldl", args, p)
+	})
+	defineBuiltin("foldr", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return fold("foldr", args, p)
+	})
+	defineNative("apply", func(args []interface{}, p *Params, k *kont) step {
+		// (apply f 1 2 '(3 4)) -> (f 1 2 3 4)
+		if err := checkArity("apply", args, 2, -1); err != nil {
+			return fail(err, p, k)
+		}
+		last, ok := listToSlice(args[len(args)-1])
+		if !ok {
+			return fail(contractError("apply", "list?", args[len(args)-1]), p, k)
+		}
+		procArgs := append(append([]interface{}{}, args[1:len(args)-1]...), last...)
+		return applyStep(args[0], procArgs, nil, p, k)
+	})
+	defineBuiltin("build-list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (build-list 3 f) -> (list (f 0) (f 1) (f 2))
+		if err := checkArity("build-list", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		n, err := naturalArg("build-list", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		elements := make([]interface{}, n)
+		for i := range elements {
+			if got, t, err := applySingle(args[1], []interface{}{int64(i)}, p); err != nil {
+				return got, t, err
+			} else {
+				elements[i] = got
+			}
+		}
+		return sliceToList(elements), TYPE_LIST, nil
+	})
+	defineBuiltin("member", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the tail of the list starting with the element, or #f
+		if err := checkArity("member", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for l, ok := args[1].(*pair); ok; l, ok = l.cdr.(*pair) {
+			if isEqual(args[0], l.car) {
+				return l, TYPE_LIST, nil
+			}
+		}
+		return false, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("assoc", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the first pair of the association list whose car is the key, or #f
+		if err := checkArity("assoc", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		lists, err := listArgs("assoc", args[1:], 1)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, v := range lists[0] {
+			if entry, ok := v.(*pair); !ok {
+				return nil, TYPE_ERROR, &RacketError{Kind: ERR_CONTRACT, Proc: "assoc", Message: "non-pair found in list", Fields: []ErrorField{{"given", FormatValue(v)}}}
+			} else if isEqual(args[0], entry.car) {
+				return entry, TYPE_LIST, nil
+			}
+		}
+		return false, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("sort", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (sort l less-than?) is stable like racket's sort
+		if err := checkArity("sort", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		lists, err := listArgs("sort", args[:1], 1)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		sorted := append([]interface{}{}, lists[0]...)
+		var sortErr error
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sortErr != nil {
+				return false
+			}
+			got, _, err := applySingle(args[1], []interface{}{sorted[i], sorted[j]}, p)
+			sortErr = err
+			return err == nil && isTrue(got)
+		})
+		if sortErr != nil {
+			return nil, TYPE_ERROR, sortErr
+		}
+		return sliceToList(sorted), TYPE_LIST, nil
+	})
+	defineBuiltin("range", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (range end), (range start end) or (range start end step)
+		var bounds = []interface{}{int64(0), int64(0), int64(1)}
+		if err := checkArity("range", args, 1, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for i, arg := range args {
+			if isNumber(arg) {
+				bounds[i] = arg
+			} else {
+				return nil, TYPE_ERROR, contractError("range", "real?", arg)
+			}
+		}
+		if len(args) == 1 {
+			bounds[0], bounds[1] = int64(0), bounds[0]
+		}
+		start, end, step := bounds[0], bounds[1], bounds[2]
+		var elements []interface{}
+		for n := start; (numCompare(">", step, int64(0)) && numCompare("<", n, end)) ||
+			(numCompare("<", step, int64(0)) && numCompare(">", n, end)); n = numAdd(n, step) {
+			elements = append(elements, n)
+		}
+		return sliceToList(elements), TYPE_LIST, nil
+	})
+}
+
+// check that all the operands are lists and convert them into slices
+func listArgs(name string, args []interface{}, want int) ([][]interface{}, error) {
+	if len(args) != want {
+		return nil, arityError(name, fmt.Sprint(want), len(args))
+	}
+	lists := make([][]interface{}, len(args))
+	for i, arg := range args {
+		l, ok := listToSlice(arg)
+		if !ok {
+			return nil, contractError(name, "list?", arg)
+		}
+		lists[i] = l
+	}
+	return lists, nil
+}
+
+/*
+(f l1 l2 ...) where all lists have the same length, call the procedure with
+the i-th element of every list. The callback stops the iteration when it returns false.
+*/
+func eachElements(name string, args []interface{}, p Params, callback func(got interface{}) bool) error {
+	lists, err := sameSizeLists(name, args)
+	if err != nil {
+		return err
+	}
+	for i := range lists[0] {
+		got, _, err := applySingle(args[0], elementsAt(lists, i), p)
+		if err != nil {
+			return err
+		}
+		if !callback(got) {
+			break
+		}
+	}
+	return nil
+}
+
+func mapLists(name string, args []interface{}, p Params) ([]interface{}, error) {
+	var results []interface{}
+	err := eachElements(name, args, p, func(got interface{}) bool {
+		results = append(results, got)
+		return true
+	})
+	return results, err
+}
+
+// the list arguments of (f l1 l2 ...), which have the same length
+func sameSizeLists(name string, args []interface{}) ([][]interface{}, error) {
+	if err := checkArity(name, args, 2, -1); err != nil {
+		return nil, err
+	}
+	lists, err := listArgs(name, args[1:], len(args)-1)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lists {
+		if len(l) != len(lists[0]) {
+			return nil, newRacketError(ERR_CONTRACT, name, "all lists must have same size")
+		}
+	}
+	return lists, nil
+}
+
+// the i-th element of every list
+func elementsAt(lists [][]interface{}, i int) []interface{} {
+	elements := make([]interface{}, len(lists))
+	for j, l := range lists {
+		elements[j] = l[i]
+	}
+	return elements
+}
+
+/*
+The steps of map and for-each, the results so far are a list in reverse order
+which isn't modified, so a continuation can resume the iteration again
+*/
+func mapStep(name string, args []interface{}, p *Params, k *kont) step {
+	lists, err := sameSizeLists(name, args)
+	if err != nil {
+		return fail(err, p, k)
+	}
+	var next func(i int, results interface{}, k *kont) step
+	next = func(i int, results interface{}, k *kont) step {
+		if i == len(lists[0]) && name == "for-each" {
+			return ret(void, TYPE_VOID, k)
+		} else if i == len(lists[0]) {
+			var l interface{} = null
+			for ; results != null; results = results.(*pair).cdr {
+				l = &pair{car: results.(*pair).car, cdr: l}
+			}
+			return ret(l, TYPE_LIST, k)
+		}
+		return applyOne(args[0], elementsAt(lists, i), p, k, func(got interface{}, k *kont) step {
+			return next(i+1, &pair{car: got, cdr: results}, k)
+		})
+	}
+	return next(0, null, k)
+}
+
+/*
+(foldl f init l1 l2 ...) calls (f e1 e2 ... acc) from the left,
+foldr does the same from the right
+*/
+func fold(name string, args []interface{}, p Params) (interface{}, TypeEnum, error) {
+	if err := checkArity(name, args, 3, -1); err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	lists, err := listArgs(name, args[2:], len(args)-2)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	for _, l := range lists {
+		if len(l) != len(lists[0]) {
+			return nil, TYPE_ERROR, newRacketError(ERR_CONTRACT, name, "all lists must have same size")
+		}
+	}
+	acc := args[1]
+	for k := range lists[0] {
+		i := k
+		if name == "foldr" {
+			i = len(lists[0]) - 1 - k
+		}
+		procArgs := make([]interface{}, 0, len(lists)+1)
+		for _, l := range lists {
+			procArgs = append(procArgs, l[i])
+		}
+		if acc, _, err = applySingle(args[0], append(procArgs, acc), p); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+	}
+	return acc, typeOf(acc), nil
+}
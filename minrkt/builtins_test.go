@@ -0,0 +1,109 @@
+package minrkt
+
+import (
+	"testing"
+)
+
+func newTestParams() Params {
+	return Params{MapIdentifier: make(map[string]interface{}), CallStack: make([]map[string]interface{}, 1), modules: newModuleRegistry()}
+}
+
+// tokenize, parse and evaluate one line of input
+func evalLine(p Params, line string) (interface{}, TypeEnum, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	root, err := Parse(tokens)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	return root.Eval(p)
+}
+
+func TestListBuiltins(t *testing.T) {
+	p := newTestParams()
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(list 1 2 3)", "'(1 2 3)"},
+		{"(cons 1 (cons 2 empty))", "'(1 2)"},
+		{"(cons 1 2)", "'(1 . 2)"},
+		{"(car '(1 2))", "1"},
+		{"(cdr '(1 2))", "'(2)"},
+		{"(null? '())", "#t"},
+		{"(length (list 1 2 3))", "3"},
+		{"(reverse '(1 2 3))", "'(3 2 1)"},
+		{"(append '(1) '(2 3) '())", "'(1 2 3)"},
+		{"'(a (b c))", "'(a (b c))"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	// (car '())
+	if _, _, err := evalLine(p, "(car '())"); err == nil {
+		t.Error("expected evaluation error(car: operand should be a pair) doesn't show up for expression (car '())")
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (sq x) (* x x))")
+	evalLine(p, "(define (make-adder n) (lambda (x) (+ x n)))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(map sq '(1 2 3))", "'(1 4 9)"},
+		{"(map + '(1 2) '(10 20))", "'(11 22)"},
+		{"(map (make-adder 10) '(1 2))", "'(11 12)"},
+		{"(filter (lambda (x) (> x 2)) '(1 2 3 4))", "'(3 4)"},
+		{"(foldl cons '() '(1 2 3))", "'(3 2 1)"},
+		{"(foldr cons '() '(1 2 3))", "'(1 2 3)"},
+		{"(foldl (lambda (a b acc) (+ acc (* a b))) 0 '(1 2) '(3 4))", "11"},
+		{"(andmap sq '(1 2))", "4"},
+		{"(andmap (lambda (x) (> x 1)) '(1 2))", "#f"},
+		{"(ormap (lambda (x) (> x 1)) '(1 2))", "#t"},
+		{"(apply + 1 2 '(3 4))", "10"},
+		{"(apply sq '(5))", "25"},
+		{"(build-list 4 sq)", "'(0 1 4 9)"},
+		{"(assoc 'b '((a 1) (b 2)))", "'(b 2)"},
+		{"(assoc 'c '((a 1) (b 2)))", "#f"},
+		{"(member 2 '(1 2 3))", "'(2 3)"},
+		{"(member '(1) '(1 (1)))", "'((1))"},
+		{"(if (member 2 '(1 2)) 1 0)", "1"},
+		{"(and (member 2 '(1 2)) (not (assoc 3 '((1 2)))))", "#t"},
+		{"(sort '(3 1 2) <)", "'(1 2 3)"},
+		{"(sort '(3 1 2) (lambda (a b) (> a b)))", "'(3 2 1)"},
+		{"(range 4)", "'(0 1 2 3)"},
+		{"(range 1 10 3)", "'(1 4 7)"},
+		{"(for-each sq '(1 2))", ""},
+		{"((lambda (x y) (+ x y)) 1 2)", "3"},
+		{"(let ([x 1] [y 2]) (+ x y))", "3"},
+		{"(let* ([x 1] [y (+ x 1)]) y)", "2"},
+		{"sq", "#<procedure:sq>"},
+		{"car", "#<procedure:car>"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	// (map + '(1 2) '(1))
+	if _, _, err := evalLine(p, "(map + '(1 2) '(1))"); err == nil {
+		t.Error("expected evaluation error(all lists must have same size) doesn't show up for expression (map + '(1 2) '(1))")
+	}
+	// (sort '(1 2) sq)
+	if _, _, err := evalLine(p, "(sort '(2 1) car)"); err == nil {
+		t.Error("expected evaluation error(car: operand should be a pair) doesn't show up for expression (sort '(2 1) car)")
+	}
+}
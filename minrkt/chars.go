@@ -0,0 +1,154 @@
+package minrkt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// value of #\a, a unicode code point
+type char rune
+
+// names of #\space and the like, the first name of a character is the one it is printed with
+var charNames = []struct {
+	name string
+	r    rune
+}{
+	{"nul", 0}, {"null", 0}, {"backspace", 8}, {"tab", 9}, {"newline", 10}, {"linefeed", 10}, {"vtab", 11},
+	{"page", 12}, {"return", 13}, {"space", 32}, {"rubout", 127}, {"delete", 127},
+}
+
+// #\a, #\space or #\u3BB, text starts with #\
+func parseChar(text string) (char, error) {
+	name := strings.TrimPrefix(text, "#\\")
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return char(r), nil
+	}
+	for _, c := range charNames {
+		if strings.EqualFold(name, c.name) {
+			return char(c.r), nil
+		}
+	}
+	if len(name) > 1 && len(name) <= 7 && (name[0] == 'u' || name[0] == 'U') {
+		if n, err := strconv.ParseUint(name[1:], 16, 32); err == nil && isCodePoint(int64(n)) {
+			return char(n), nil
+		}
+	}
+	return 0, fmt.Errorf("bad character constant: %s", text)
+}
+
+// code points which aren't surrogates can be characters
+func isCodePoint(n int64) bool {
+	return n >= 0 && n <= unicode.MaxRune && !(n >= 0xD800 && n <= 0xDFFF)
+}
+
+// the way write prints characters, e.g. #\a, #\space or #\u0001
+func formatChar(c char) string {
+	for _, named := range charNames {
+		if rune(c) == named.r {
+			return "#\\" + named.name
+		}
+	}
+	if unicode.IsGraphic(rune(c)) {
+		return "#\\" + string(rune(c))
+	}
+	return fmt.Sprintf("#\\u%04X", rune(c))
+}
+
+func charArg(proc string, arg interface{}) (char, error) {
+	c, ok := arg.(char)
+	if !ok {
+		return 0, contractError(proc, "char?", arg)
+	}
+	return c, nil
+}
+
+// (char<? a b c) like the string comparisons
+func defineCharComparison(name string, compare func(a, b rune) bool) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(name, args, 1, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if _, err := charArg(name, arg); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+		}
+		for i := 1; i < len(args); i++ {
+			if !compare(rune(args[i-1].(char)), rune(args[i].(char))) {
+				return false, TYPE_BOOLEAN, nil
+			}
+		}
+		return true, TYPE_BOOLEAN, nil
+	})
+}
+
+func init() {
+	defineBuiltin("char?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("char?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(char)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("char->integer", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("char->integer", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		c, err := charArg("char->integer", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return int64(c), TYPE_FLOAT64, nil
+	})
+	defineBuiltin("integer->char", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("integer->char", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		n, ok := args[0].(int64)
+		if !ok || !isCodePoint(n) {
+			return nil, TYPE_ERROR, contractError("integer->char",
+				"(and/c (integer-in 0 #x10FFFF) (not/c (integer-in #xD800 #xDFFF)))", args[0])
+		}
+		return char(n), TYPE_CHAR, nil
+	})
+	predicates := map[string]func(rune) bool{
+		"char-alphabetic?": unicode.IsLetter,
+		"char-numeric?":    unicode.IsNumber,
+		"char-whitespace?": unicode.IsSpace,
+		"char-upper-case?": unicode.IsUpper,
+		"char-lower-case?": unicode.IsLower,
+	}
+	for name, pred := range predicates {
+		name, pred := name, pred
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			c, err := charArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return pred(rune(c)), TYPE_BOOLEAN, nil
+		})
+	}
+	for name, convert := range map[string]func(rune) rune{"char-upcase": unicode.ToUpper, "char-downcase": unicode.ToLower} {
+		name, convert := name, convert
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			c, err := charArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return char(convert(rune(c))), TYPE_CHAR, nil
+		})
+	}
+	defineCharComparison("char=?", func(a, b rune) bool { return a == b })
+	defineCharComparison("char<?", func(a, b rune) bool { return a < b })
+	defineCharComparison("char>?", func(a, b rune) bool { return a > b })
+}
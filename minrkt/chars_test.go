@@ -0,0 +1,112 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChars(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (λ-square x) (* x x))")
+	evalLine(p, "(define café 'crème)")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"#\\a", "#\\a"},
+		{"#\\space", "#\\space"},
+		{"#\\u03BB", "#\\λ"},
+		{"#\\λ", "#\\λ"},
+		{"#\\(", "#\\("},
+		{"'(#\\a #\\nul)", "'(#\\a #\\nul)"},
+		{"(integer->char 1)", "#\\u0001"},
+		{"(char? #\\a)", "#t"},
+		{"(char? \"a\")", "#f"},
+		{"(char->integer #\\A)", "65"},
+		{"(char->integer #\\newline)", "10"},
+		{"(integer->char 955)", "#\\λ"},
+		{"(char-alphabetic? #\\é)", "#t"},
+		{"(char-alphabetic? #\\1)", "#f"},
+		{"(char-numeric? #\\1)", "#t"},
+		{"(char-whitespace? #\\tab)", "#t"},
+		{"(char-upcase #\\é)", "#\\É"},
+		{"(char-downcase #\\A)", "#\\a"},
+		{"(char=? #\\a #\\a #\\a)", "#t"},
+		{"(char<? #\\a #\\b #\\a)", "#f"},
+		{"(eqv? #\\a (string-ref \"a\" 0))", "#t"},
+		{"(string-length \"λx\")", "2"},
+		{"(string-ref \"aλb\" 1)", "#\\λ"},
+		{"(string-ref \"aλb\" 2)", "#\\b"},
+		{"(string->list \"aλ\")", "'(#\\a #\\λ)"},
+		{"(list->string (list #\\a #\\λ))", "\"aλ\""},
+		{"(list->string '())", "\"\""},
+		{"(string #\\a #\\space #\\b)", "\"a b\""},
+		{"(substring \"λμνξ\" 1 3)", "\"μν\""},
+		{"(format \"~a ~s\" #\\a #\\a)", "\"a #\\\\a\""},
+		{"(read (open-input-string \"(#\\\\) #\\\\space)\"))", "'(#\\) #\\space)"},
+		{"(hash-ref (hash #\\a 1) #\\a)", "1"},
+		{"(λ-square 3)", "9"},
+		{"café", "'crème"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(string-ref \"abc\" 3)", "string-ref: index is out of range\n  index: 3\n  valid range: [0, 2]\n  string: \"abc\""},
+		{"(string-ref \"\" 0)", "string-ref: index is out of range for empty string\n  index: 0"},
+		{"(char->integer \"a\")", "char->integer: contract violation\n  expected: char?\n  given: \"a\""},
+		{"(integer->char 55296)", "integer->char: contract violation\n  expected: (and/c (integer-in 0 #x10FFFF) (not/c (integer-in #xD800 #xDFFF)))\n  given: 55296"},
+		{"(list->string '(#\\a 1))", "list->string: contract violation\n  expected: (listof char?)\n  given: '(#\\a 1)"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+	if _, _, err := evalLine(p, "#\\abc"); err == nil || err.Error() != "bad character constant: #\\abc" {
+		t.Error("expected a bad character constant error but got", err)
+	}
+}
+
+// the code points of a long string are decoded once for a loop indexing it
+func TestIndexedStrings(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define s (apply string-append (for/list ([i 100]) \"λx\")))")
+	evalLine(p, "(define ascii (apply string-append (for/list ([i 100]) \"a\")))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(string-length s)", "200"},
+		{"(list (string-ref s 198) (string-ref s 199))", "'(#\\λ #\\x)"},
+		{"(substring s 197 200)", "\"xλx\""},
+		{"(for/sum ([i (string-length s)]) (if (char=? (string-ref s i) #\\λ) 1 0))", "100"},
+		{"(list (string-length ascii) (string-ref ascii 99) (substring ascii 98))", "'(100 #\\a \"aa\")"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+	s := p.MapIdentifier["s"].(string)
+	if is := indexString(s); is.runes == nil || indexString(s) != is {
+		t.Error("expected the decoded code points of the string to be kept")
+	}
+	if ascii := indexString(p.MapIdentifier["ascii"].(string)); ascii.runes != nil {
+		t.Error("expected ASCII strings to be indexed by bytes")
+	}
+}
@@ -0,0 +1,156 @@
+package minrkt
+
+/*
+A continuation is the list of frames waiting for the value of call/cc, see
+machine.go. Calling it jumps to those frames, from anywhere and any number of
+times: dynamic-wind post thunks run for the frames left and pre thunks for the
+frames entered, with-handlers only catches raised errors so jumps go through.
+A continuation captured by an earlier evaluation of the REPL continues the
+current one, like racket's default prompt. Escape continuations of let/ec and
+call/ec only jump out of their frames, once they returned using them is an error.
+*/
+type continuationValue struct {
+	k      *kont
+	escape bool
+}
+
+// prompt tag of call-with-continuation-prompt and abort-current-continuation
+type promptTag struct {
+	name string
+}
+
+var defaultPromptTag = &promptTag{name: "default"}
+
+// the frame of the procedure of call-with-continuation-prompt
+type promptFrame struct {
+	tag     *promptTag
+	handler interface{}
+	p       *Params
+}
+
+// raised as an error to jump to target, arrive is the step once the frames of target are reached
+type continuationJump struct {
+	target *kont
+	escape bool
+	arrive func(k *kont) step
+}
+
+func (j *continuationJump) Error() string {
+	return "continuation application: no target for the jump"
+}
+
+// (let/ec k body ...) binds k to an escape continuation of the let/ec expression
+func evalLetEscape(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 2 {
+		return fail(syntaxError("let/ec", "bad syntax"), p, k)
+	}
+	name, ok := e.operands[0].(*ExpIdentifier)
+	if !ok {
+		return fail(syntaxError("let/ec", "not an identifier: %s", e.operands[0].Print()), p, k)
+	}
+	// the frame of the escape continuation only marks the target of the jumps
+	escape := &kont{next: k}
+	frame := p.extendFrame()
+	frame[name.val] = continuationValue{k: escape, escape: true}
+	body := p.withFrame(frame)
+	return eval(newBody(e.operands[1:]), &body, escape)
+}
+
+func init() {
+	callCC := func(name string, escape bool) nativeFunc {
+		return func(args []interface{}, p *Params, k *kont) step {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return fail(err, p, k)
+			}
+			if escape {
+				k = &kont{next: k}
+			}
+			return applyStep(args[0], []interface{}{continuationValue{k: k, escape: escape}}, nil, p, k)
+		}
+	}
+	for _, name := range []string{"call-with-current-continuation", "call/cc"} {
+		defineNative(name, callCC(name, false))
+	}
+	for _, name := range []string{"call-with-escape-continuation", "call/ec"} {
+		defineNative(name, callCC(name, true))
+	}
+	defineBuiltin("continuation?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("continuation?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(continuationValue)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("default-continuation-prompt-tag", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("default-continuation-prompt-tag", args, 0, 0); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return defaultPromptTag, TYPE_PROMPT_TAG, nil
+	})
+	defineBuiltin("make-continuation-prompt-tag", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("make-continuation-prompt-tag", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		tag := &promptTag{}
+		if len(args) == 1 {
+			if name, ok := args[0].(symbol); ok {
+				tag.name = string(name)
+			} else {
+				return nil, TYPE_ERROR, contractError("make-continuation-prompt-tag", "symbol?", args[0])
+			}
+		}
+		return tag, TYPE_PROMPT_TAG, nil
+	})
+	defineNative("call-with-continuation-prompt", func(args []interface{}, p *Params, k *kont) step {
+		// (call-with-continuation-prompt proc [tag handler] arg ...)
+		if err := checkArity("call-with-continuation-prompt", args, 1, -1); err != nil {
+			return fail(err, p, k)
+		}
+		tag := defaultPromptTag
+		var handler interface{} = false
+		if len(args) > 1 {
+			var ok bool
+			if tag, ok = args[1].(*promptTag); !ok {
+				return fail(contractError("call-with-continuation-prompt", "continuation-prompt-tag?", args[1]), p, k)
+			}
+		}
+		if len(args) > 2 {
+			if handler = args[2]; handler != false && !isProcedure(handler) {
+				return fail(contractError("call-with-continuation-prompt", "(or/c procedure? #f)", handler), p, k)
+			}
+		}
+		var procArgs []interface{}
+		if len(args) > 3 {
+			procArgs = args[3:]
+		}
+		prompt := &kont{next: k, mark: &promptFrame{tag: tag, handler: handler, p: p}}
+		return applyStep(args[0], procArgs, nil, p, prompt)
+	})
+	defineNative("abort-current-continuation", func(args []interface{}, p *Params, k *kont) step {
+		// (abort-current-continuation tag v ...) escapes to the closest prompt with the tag
+		if err := checkArity("abort-current-continuation", args, 1, -1); err != nil {
+			return fail(err, p, k)
+		}
+		tag, ok := args[0].(*promptTag)
+		if !ok {
+			return fail(contractError("abort-current-continuation", "continuation-prompt-tag?", args[0]), p, k)
+		}
+		for prompt := k; prompt != nil; prompt = prompt.next {
+			mark, ok := prompt.mark.(*promptFrame)
+			if !ok || mark.tag != tag {
+				continue
+			}
+			// the handler is called outside of the prompt, the default one calls the given thunk
+			return fail(&continuationJump{target: prompt.next, arrive: func(k *kont) step {
+				if mark.handler == false {
+					if len(args) != 2 {
+						return fail(arityError("default prompt handler", "1", len(args)-1), mark.p, k)
+					}
+					return applyStep(args[1], nil, nil, mark.p, k)
+				}
+				return applyStep(mark.handler, args[1:], nil, mark.p, k)
+			}}, p, k)
+		}
+		return fail(newRacketError(ERR_CONTRACT, "abort-current-continuation", "continuation includes no prompt with the given tag"), p, k)
+	})
+}
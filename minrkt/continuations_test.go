@@ -0,0 +1,129 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContinuations(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define log '())")
+	evalLine(p, "(define (find-first pred l) (call/cc (lambda (return) (for-each (lambda (x) (if (pred x) (return x) x)) l) #f)))")
+	evalLine(p, "(define saved #f)")
+	evalLine(p, "(define tag (make-continuation-prompt-tag 'tag))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(call/cc (lambda (k) 1))", "1"},
+		{"(+ 1 (call/cc (lambda (k) (+ 10 (k 2)))))", "3"},
+		{"(call-with-current-continuation (lambda (k) (map k '(1 2))))", "1"},
+		{"(call/ec (lambda (k) (k 'out) 'in))", "'out"},
+		{"(find-first (lambda (x) (> x 2)) '(1 2 3 4))", "3"},
+		{"(find-first (lambda (x) (> x 5)) '(1 2 3 4))", "#f"},
+		{"(let/ec k (+ 1 (k 5)))", "5"},
+		{"(let/ec k 1 2)", "2"},
+		{"(continuation? (call/cc (lambda (k) k)))", "#t"},
+		{"(continuation? car)", "#f"},
+		// jumps aren't caught by exception handlers
+		{"(let/ec k (with-handlers ([(lambda (e) #t) (lambda (e) 'caught)]) (k 'escaped)))", "'escaped"},
+		// but run the post thunks of dynamic-wind
+		{"(let/ec k (dynamic-wind (lambda () 0) (lambda () (k 1)) (lambda () (set-log 'post))))", "1"},
+		{"(call-with-continuation-prompt (lambda () (+ 1 (abort-current-continuation (default-continuation-prompt-tag) (lambda () 10)))))", "10"},
+		{"(call-with-continuation-prompt (lambda (x) (* x 2)) (default-continuation-prompt-tag) #f 21)", "42"},
+		{"(call-with-continuation-prompt (lambda () (abort-current-continuation tag 1 2)) tag (lambda (a b) (+ a b)))", "3"},
+		// the closest prompt with the tag receives the abort
+		{"(call-with-continuation-prompt (lambda () (+ 1 (call-with-continuation-prompt (lambda () (abort-current-continuation tag 5)) (default-continuation-prompt-tag)))) tag (lambda (v) (* v 2)))", "10"},
+	}
+	// bound in the session like a definition, the builtins stay the same for the other tests
+	p.MapIdentifier["set-log"] = builtinValue{"set-log", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		p.MapIdentifier["log"] = &pair{args[0], p.MapIdentifier["log"]}
+		return void, TYPE_VOID, nil
+	}}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+	if got := FormatValue(p.MapIdentifier["log"]); got != "'(post)" {
+		t.Error("expected the post thunk to run once but got", got)
+	}
+
+	// a continuation of a previous evaluation continues the current one
+	evalLine(p, "(define k (call/cc (lambda (k) k)))")
+	if _, _, err := evalLine(p, "(k 1)"); err != nil {
+		t.Error("unexpected error re-entering the continuation:", err)
+	} else if got := FormatValue(p.MapIdentifier["k"]); got != "1" {
+		t.Error("expected k to be defined again as 1 but got", got)
+	}
+	evalLine(p, "(define r (+ 100 (call/cc (lambda (k) (define saved k) 1))))")
+	evalLine(p, "(saved 5)")
+	if got := FormatValue(p.MapIdentifier["r"]); got != "105" {
+		t.Error("expected r to be defined again as 105 but got", got)
+	}
+
+	// re-entering a dynamic-wind runs the pre thunk again
+	evalLine(p, "(define log '())")
+	evalLine(p, "(dynamic-wind (lambda () (set-log 'in)) (lambda () (call/cc (lambda (k) (define saved k))) 'body) (lambda () (set-log 'out)))")
+	evalLine(p, "(saved #f)")
+	if got := FormatValue(p.MapIdentifier["log"]); got != "'(out in out in)" {
+		t.Error("expected the thunks to run again but got", got)
+	}
+
+	// escape continuations can't be used once let/ec or call/ec returned
+	_, _, err := evalLine(p, "((let/ec k k) 1)")
+	var re *RacketError
+	if !errors.As(err, &re) || re.Error() != "continuation application: attempt to jump into an escape continuation" {
+		t.Error("expected an escape continuation error but got", err)
+	}
+	evalLine(p, "(define e (call/ec (lambda (k) k)))")
+	_, _, err = evalLine(p, "(e 1)")
+	if !errors.As(err, &re) || re.Error() != "continuation application: attempt to jump into an escape continuation" {
+		t.Error("expected an escape continuation error but got", err)
+	}
+	// nor continuations captured by procedures called from go once the builtin returned
+	evalLine(p, "(sort '(2 1) (lambda (a b) (call/cc (lambda (k) (define saved k))) (< a b)))")
+	_, _, err = evalLine(p, "(saved #t)")
+	if !errors.As(err, &re) || re.Error() != "continuation application: attempt to cross a continuation barrier" {
+		t.Error("expected a continuation barrier error but got", err)
+	}
+	_, _, err = evalLine(p, "(abort-current-continuation tag 1)")
+	if !errors.As(err, &re) || re.Proc != "abort-current-continuation" {
+		t.Error("expected a missing prompt error but got", err)
+	}
+}
+
+func TestContinuations_Reentry(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define return-k #f)")
+	evalLine(p, "(define resume-k #f)")
+	// the elements of the list one at a time, by jumping back into for-each
+	evalLine(p, `(define (next)
+  (call/cc (lambda (r)
+    (define return-k r)
+    (if resume-k
+        (resume-k #f)
+        (begin (for-each (lambda (x) (call/cc (lambda (k) (define resume-k k) (return-k x)))) '(1 2 3))
+               (return-k 'done))))))`)
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(let ([x (call/cc (lambda (k) k))]) (if (continuation? x) (x 5) x))", "5"},
+		{"(let ([p (call/cc (lambda (k) (cons 0 k)))]) (if (< (car p) 3) ((cdr p) (cons (+ (car p) 1) (cdr p))) (car p)))", "3"},
+		{"(list (next) (next) (next) (next))", "'(1 2 3 done)"},
+		// the values given to map so far are kept when it's resumed
+		{"(let ([l (map (lambda (x) (call/cc (lambda (k) (cons x k)))) '(1 2))]) (if (pair? (car l)) ((cdr (car l)) 10) l))", "'(10 (2 . #<continuation>))"},
+		{"(+ 1 (call/cc (lambda (k) (apply k '(2)))))", "3"},
+		{"(call-with-values (lambda () (call/cc (lambda (k) (k 1 2)))) list)", "'(1 2)"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package minrkt
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// identity like racket's eq?, numbers, booleans, strings and symbols are compared by value
+func isEq(a, b interface{}) bool {
+	switch x := a.(type) {
+	case functionValue:
+		y, ok := b.(functionValue)
+		return ok && sameClosure(x, y)
+	case builtinValue:
+		y, ok := b.(builtinValue)
+		return ok && x.name == y.name && reflect.ValueOf(x.fn).Pointer() == reflect.ValueOf(y.fn).Pointer()
+	case caseLambdaValue:
+		y, ok := b.(caseLambdaValue)
+		return ok && len(x.clauses) == len(y.clauses) && len(x.clauses) > 0 && sameClosure(x.clauses[0], y.clauses[0])
+	}
+	switch y := b.(type) {
+	case functionValue, builtinValue, caseLambdaValue:
+		return false
+	case float64:
+		// the same bits like racket's eqv?: 0.0 and -0.0 differ, +nan.0 is itself, and hashes agree with it
+		x, ok := a.(float64)
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	case *big.Int, *big.Rat:
+		// exact numbers are normalized, only big ones are compared to big ones
+		return isExact(a) && numCompare("=", a, b)
+	}
+	return a == b
+}
+
+// closures of the same lambda expression created in the same environment can't be told apart
+func sameClosure(a, b functionValue) bool {
+	return a.body == b.body && a.line == b.line && a.col == b.col &&
+		reflect.ValueOf(a.env).Pointer() == reflect.ValueOf(b.env).Pointer()
+}
+
+// a pair of mutable values being compared by equal?
+type equalVisit struct {
+	a, b interface{}
+}
+
+// structural equality like racket's equal?
+func isEqual(a, b interface{}) bool {
+	return equalWith(a, b, nil)
+}
+
+// seen holds the vectors, hashes and structs being compared, meeting them again
+// means the data is cyclic and the comparison so far found no difference
+func equalWith(a, b interface{}, seen map[equalVisit]bool) bool {
+	for {
+		x, ok := a.(*pair)
+		if !ok {
+			break
+		}
+		y, ok := b.(*pair)
+		if !ok || !equalWith(x.car, y.car, seen) {
+			return false
+		}
+		a, b = x.cdr, y.cdr
+	}
+	switch a.(type) {
+	case *vector, *hashTable, *structValue:
+		if isEq(a, b) {
+			return true
+		}
+		visit := equalVisit{a, b}
+		if seen == nil {
+			seen = map[equalVisit]bool{}
+		} else if seen[visit] {
+			return true
+		}
+		seen[visit] = true
+	}
+	switch x := a.(type) {
+	case *vector:
+		y, ok := b.(*vector)
+		if !ok || len(x.elements) != len(y.elements) {
+			return false
+		}
+		for i := range x.elements {
+			if !equalWith(x.elements[i], y.elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *hashTable:
+		y, ok := b.(*hashTable)
+		return ok && hashTablesEqual(x, y, seen)
+	case *structValue:
+		y, ok := b.(*structValue)
+		return ok && structsEqual(x, y, seen)
+	case *regexpValue:
+		y, ok := b.(*regexpValue)
+		return ok && x.source == y.source && x.pregexp == y.pregexp
+	}
+	return isEq(a, b)
+}
+
+func init() {
+	defineBuiltin("eq?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("eq?", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return isEq(args[0], args[1]), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("eqv?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// numbers and characters are the only values where eqv? differs from eq?, both are compared by value here,
+		// inexact numbers by their bits so that (eqv? 0.0 -0.0) is #f and (eqv? +nan.0 +nan.0) is #t
+		if err := checkArity("eqv?", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return isEq(args[0], args[1]), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("equal?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("equal?", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return isEqual(args[0], args[1]), TYPE_BOOLEAN, nil
+	})
+}
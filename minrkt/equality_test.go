@@ -0,0 +1,84 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEquality(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(struct point (x y) #:transparent)")
+	evalLine(p, "(struct account (id))")
+	evalLine(p, "(define (f x) x)")
+	evalLine(p, "(define (make-adder n) (lambda (x) (+ x n)))")
+	evalLine(p, "(define v1 (make-vector 2 0))")
+	evalLine(p, "(define v2 (make-vector 2 0))")
+	evalLine(p, "(vector-set! v1 1 v1)")
+	evalLine(p, "(vector-set! v2 1 v2)")
+	evalLine(p, "(define nan (let ([inf (expt 10.0 400)]) (- inf inf)))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(eq? 'a 'a)", "#t"},
+		{"(eq? '() '())", "#t"},
+		{"(eq? (list 1) (list 1))", "#f"},
+		{"(let ([l (list 1)]) (eq? l l))", "#t"},
+		{"(eq? f f)", "#t"},
+		{"(eq? car car)", "#t"},
+		{"(eq? car cdr)", "#f"},
+		{"(eq? (make-adder 1) (make-adder 1))", "#f"},
+		{"(let ([g (make-adder 1)]) (eq? g g))", "#t"},
+		{"(eqv? 1.5 1.5)", "#t"},
+		{"(eqv? 0.0 -0.0)", "#f"},
+		{"(= 0.0 -0.0)", "#t"},
+		{"(list nan (eqv? nan nan) (equal? (list nan) (list nan)))", "'(+nan.0 #t #t)"},
+		{"(let ([h (make-hash)]) (hash-set! h 0.0 'zero) (hash-set! h nan 'nan) (list (hash-ref h -0.0 #f) (hash-ref h 0.0) (hash-ref h nan)))", "'(#f zero nan)"},
+		{"(eqv? \"a\" 'a)", "#f"},
+		{"(equal? '(1 (2 #(3 \"x\"))) (list 1 (list 2 (vector 3 \"x\"))))", "#t"},
+		{"(equal? '(1 2) '(1 2 3))", "#f"},
+		{"(equal? \"abc\" \"abc\")", "#t"},
+		{"(equal? (hash 'a '(1)) (hash 'a (list 1)))", "#t"},
+		{"(equal? (hash 'a 1) (hasheq 'a 1))", "#f"},
+		{"(equal? (point 1 '(2)) (point 1 '(2)))", "#t"},
+		{"(equal? (account 1) (account 1))", "#f"},
+		{"(equal? f f)", "#t"},
+		{"(equal? v1 v2)", "#t"},
+		{"(begin (vector-set! v2 0 1) (equal? v1 v2))", "#f"},
+		{"(< 1 2 3 4)", "#t"},
+		{"(< 1 3 2)", "#f"},
+		{"(<= 1 1 2)", "#t"},
+		{"(> 3 2 1)", "#t"},
+		{"(>= 3 3 4)", "#f"},
+		{"(= 2 2 2)", "#t"},
+		{"(= 1)", "#t"},
+		{"(apply < '(1 2 3))", "#t"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(<)", "<: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: at least 1\n  given: 0"},
+		{"(< 1 2 'a)", "<: contract violation\n  expected: real?\n  given: 'a"},
+		{"(< 2 1 'a)", "<: contract violation\n  expected: real?\n  given: 'a"},
+		{"(= 1 \"1\")", "=: contract violation\n  expected: number?\n  given: \"1\""},
+		{"(equal? 1)", "equal?: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 2\n  given: 1"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package minrkt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// kind of an evaluation error, named after racket's exception structs
+type ErrorKind string
+
+const (
+	ERR_FAIL           ErrorKind = "exn:fail"
+	ERR_CONTRACT       ErrorKind = "exn:fail:contract"
+	ERR_DIVIDE_BY_ZERO ErrorKind = "exn:fail:contract:divide-by-zero"
+	ERR_ARITY          ErrorKind = "exn:fail:contract:arity"
+	ERR_UNBOUND        ErrorKind = "exn:fail:contract:variable" // unbound identifier
+	ERR_SYNTAX         ErrorKind = "exn:fail:syntax"
+	ERR_FILESYSTEM     ErrorKind = "exn:fail:filesystem"
+)
+
+// kinds having an exn:...? predicate and a struct:exn:... type
+var exnKinds = []ErrorKind{"exn", ERR_FAIL, ERR_CONTRACT, ERR_DIVIDE_BY_ZERO, ERR_ARITY, ERR_UNBOUND, ERR_SYNTAX, ERR_FILESYSTEM}
+
+// one "  expected: number?" line of an error message
+type ErrorField struct {
+	Name  string
+	Value string
+}
+
+/*
+RacketError is returned by evaluation, its message is formatted like racket's:
+
+	+: contract violation
+	  expected: number?
+	  given: #t
+*/
+type RacketError struct {
+	// empty when a value which isn't an exception is raised, e.g. (raise 42)
+	Kind ErrorKind
+	// procedure or syntactic form reporting the error, e.g. +
+	Proc    string
+	Message string
+	Fields  []ErrorField
+	// value given to raise, nil for errors reported by the interpreter
+	Value interface{}
+	// functions being called when the error was raised, the innermost one first
+	Context []StackFrame
+}
+
+// StackFrame is a call of a function defined by define or lambda
+type StackFrame struct {
+	// empty for anonymous functions
+	Name string
+	// position of the definition of the function
+	Line int
+	Col  int
+}
+
+func (f StackFrame) String() string {
+	if f.Name == "" {
+		return fmt.Sprintf("%d:%d", f.Line, f.Col)
+	}
+	return fmt.Sprintf("%d:%d %s", f.Line, f.Col, f.Name)
+}
+
+// at most this many lines are printed by ContextString
+const maxContextLines = 16
+
+/*
+ContextString prints the context like racket, repeated calls of recursive
+functions are collapsed into one line:
+
+	context...:
+	   1:0 fib [repeats 20 more times]
+	   3:0 main
+*/
+func (e *RacketError) ContextString() string {
+	if len(e.Context) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("context...:")
+	lines := 0
+	for i := 0; i < len(e.Context); i++ {
+		if lines == maxContextLines {
+			sb.WriteString("\n   ...")
+			break
+		}
+		repeats := 0
+		for i+1 < len(e.Context) && e.Context[i+1] == e.Context[i] {
+			repeats++
+			i++
+		}
+		sb.WriteString("\n   " + e.Context[i].String())
+		if repeats > 0 {
+			sb.WriteString(fmt.Sprintf(" [repeats %d more times]", repeats))
+		}
+		lines++
+	}
+	return sb.String()
+}
+
+// the context is taken where the error is raised, outer calls don't replace it
+func attachContext(err error, frames []StackFrame) {
+	var re *RacketError
+	if errors.As(err, &re) && re.Context == nil {
+		re.Context = make([]StackFrame, len(frames))
+		for i, frame := range frames {
+			re.Context[len(frames)-1-i] = frame
+		}
+	}
+}
+
+func (e *RacketError) Error() string {
+	if e.Value != nil && e.Kind == "" {
+		return "uncaught exception: " + FormatValue(e.Value)
+	}
+	var sb strings.Builder
+	if e.Proc != "" {
+		sb.WriteString(e.Proc + ": ")
+	}
+	sb.WriteString(e.Message)
+	for _, f := range e.Fields {
+		sb.WriteString("\n  " + f.Name + ": " + f.Value)
+	}
+	return sb.String()
+}
+
+// value of the field with this name, e.g. Field("given")
+func (e *RacketError) Field(name string) string {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func newRacketError(kind ErrorKind, proc string, format string, a ...interface{}) *RacketError {
+	return &RacketError{Kind: kind, Proc: proc, Message: fmt.Sprintf(format, a...)}
+}
+
+// e.g. (+ 1 #t) -> +: contract violation, expected: number?, given: #t
+func contractError(proc string, expected string, given interface{}) *RacketError {
+	return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "contract violation",
+		Fields: []ErrorField{{"expected", expected}, {"given", FormatValue(given)}}}
+}
+
+func divideByZeroError(proc string) *RacketError {
+	return &RacketError{Kind: ERR_DIVIDE_BY_ZERO, Proc: proc, Message: "division by zero"}
+}
+
+func syntaxError(form string, format string, a ...interface{}) *RacketError {
+	return newRacketError(ERR_SYNTAX, form, format, a...)
+}
+
+func unboundError(name string) *RacketError {
+	return &RacketError{Kind: ERR_UNBOUND, Proc: name, Message: "undefined;\n cannot reference an identifier before its definition"}
+}
+
+// same message as racket, e.g. for (fib 2 3)
+func arityError(proc string, expected string, given int) *RacketError {
+	return &RacketError{Kind: ERR_ARITY, Proc: proc,
+		Message: "arity mismatch;\n the expected number of arguments does not match the given number",
+		Fields:  []ErrorField{{"expected", expected}, {"given", fmt.Sprint(given)}}}
+}
+
+// a continuation receiving a number of values it doesn't accept, e.g. (+ (values 1 2) 3)
+func resultArityError(proc string, expected string, received int) *RacketError {
+	return &RacketError{Kind: ERR_ARITY, Proc: proc, Message: "result arity mismatch;\n expected number of values not received",
+		Fields: []ErrorField{{"expected", expected}, {"received", fmt.Sprint(received)}}}
+}
+
+// number of arguments of builtins, max is -1 when there is no maximum
+func checkArity(proc string, args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return arityError(proc, arityString(min, max), len(args))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRacketError(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (f x) (+ x 1))")
+	cases := []struct {
+		line string
+		kind ErrorKind
+		proc string
+		want string
+	}{
+		{"(+ 1 true)", ERR_CONTRACT, "+", "+: contract violation\n  expected: number?\n  given: #t"},
+		{"(* 2 '(1))", ERR_CONTRACT, "*", "*: contract violation\n  expected: number?\n  given: '(1)"},
+		{"(< 1 'a)", ERR_CONTRACT, "<", "<: contract violation\n  expected: real?\n  given: 'a"},
+		{"(/ 1 0)", ERR_DIVIDE_BY_ZERO, "/", "/: division by zero"},
+		{"(f 1 2)", ERR_ARITY, "f", "f: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 1\n  given: 2"},
+		{"(car 1)", ERR_CONTRACT, "car", "car: contract violation\n  expected: pair?\n  given: 1"},
+		{"(cons 1)", ERR_ARITY, "cons", "cons: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 2\n  given: 1"},
+		{"y", ERR_UNBOUND, "y", "y: undefined;\n cannot reference an identifier before its definition"},
+		{"(g 1)", ERR_UNBOUND, "g", "g: undefined;\n cannot reference an identifier before its definition"},
+		{"(if 1 2)", ERR_SYNTAX, "if", "if: bad syntax;\n if statement should have three expressions"},
+		{"(lambda (x 1) 1)", ERR_SYNTAX, "lambda", "lambda: not an identifier: 1.00"},
+		{"((f 1) 2)", ERR_CONTRACT, "application", "application: not a procedure;\n expected a procedure that can be applied to arguments\n  given: 2"},
+		// errors of arguments are not discarded
+		{"(f (car '()))", ERR_CONTRACT, "car", "car: contract violation\n  expected: pair?\n  given: '()"},
+		{"(map f '(1 a))", ERR_CONTRACT, "+", "+: contract violation\n  expected: number?\n  given: 'a"},
+	}
+	for _, c := range cases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if err == nil {
+			t.Error("expected evaluation error doesn't show up for expression", c.line)
+		} else if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if re.Kind != c.kind || re.Proc != c.proc {
+			t.Error("expected error kind", c.kind, "from", c.proc, "for", c.line, " but got", re.Kind, "from", re.Proc)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+
+	_, _, err := evalLine(p, "(+ 1 true)")
+	if re := err.(*RacketError); re.Field("expected") != "number?" || re.Field("given") != "#t" {
+		t.Error("expected fields expected: number? and given: #t but got", re.Fields)
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (count n)\n  (if (= n 0) (car '()) (+ 1 (count (- n 1)))))")
+	evalLine(p, "(define (main) (* 2 (count 20)))")
+	_, _, err := evalLine(p, "(main)")
+	var re *RacketError
+	if !errors.As(err, &re) {
+		t.Fatal("expected a RacketError but got", err)
+	}
+	// the innermost call is the first one
+	if len(re.Context) != 22 || re.Context[0] != (StackFrame{Name: "count", Line: 1, Col: 0}) || re.Context[21].Name != "main" {
+		t.Error("expected 21 calls of count then main but got", re.Context)
+	}
+	want := "context...:\n   1:0 count [repeats 20 more times]\n   1:0 main"
+	if got := re.ContextString(); got != want {
+		t.Errorf("expected context %q but got %q", want, got)
+	}
+
+	// a call in tail position replaces the frame of its caller, a long loop is one frame
+	evalLine(p, "(define (loop n) (if (= n 0) (car '()) (loop (- n 1))))")
+	evalLine(p, "(define (run) (+ 1 (loop 100000)))")
+	_, _, err = evalLine(p, "(run)")
+	if !errors.As(err, &re) || len(re.Context) != 2 || re.Context[0].Name != "loop" || re.Context[1].Name != "run" {
+		t.Error("expected loop then run in the context but got", err)
+	} else if want := "context...:\n   1:0 loop\n   1:0 run"; re.ContextString() != want {
+		t.Errorf("expected context %q but got %q", want, re.ContextString())
+	}
+
+	// anonymous functions are shown by their position
+	_, _, err = evalLine(p, "(map (lambda (x) (/ 1 x)) '(1 0))")
+	if !errors.As(err, &re) || len(re.Context) != 1 || re.Context[0].String() != "1:5" {
+		t.Error("expected the lambda at 1:5 in the context but got", err)
+	}
+
+	// errors outside of functions have no context
+	_, _, err = evalLine(p, "(car 1)")
+	if !errors.As(err, &re) || re.Context != nil || re.ContextString() != "" {
+		t.Error("expected no context but got", re.Context)
+	}
+}
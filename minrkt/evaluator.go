@@ -1,10 +1,13 @@
 package minrkt
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func (e *ExpNum) Print() string {
 	// fmt.Println(e.val)
-	return fmt.Sprintf("%.2f ", e.val)
+	return fmt.Sprintf("%.2f ", toFloat(e.val))
 }
 
 func (e *ExpBool) Print() string {
@@ -15,6 +18,9 @@ func (e *ExpBool) Print() string {
 func (e *ExpOperator) Print() string {
 	// fmt.Println(e.opeType)
 	var result = e.opeType + " "
+	if e.proc != nil {
+		result = e.proc.Print()
+	}
 	for _, child := range e.operands {
 		result += child.Print()
 	}
@@ -26,6 +32,22 @@ func (e *ExpIdentifier) Print() string {
 	return fmt.Sprintf("%s ", e.val)
 }
 
+func (e *ExpQuote) Print() string {
+	return FormatValue(e.val) + " "
+}
+
+func (e *ExpKeyword) Print() string {
+	return e.val + " "
+}
+
+func (e *ExpKeyword) Eval(p Params) (interface{}, TypeEnum, error) {
+	return nil, TYPE_ERROR, syntaxError(e.val, "keyword misused as an expression")
+}
+
+func (e *ExpQuote) Eval(p Params) (interface{}, TypeEnum, error) {
+	return e.val, typeOf(e.val), nil
+}
+
 func (e *ExpBool) Eval(p Params) (interface{}, TypeEnum, error) {
 	return e.val, TYPE_BOOLEAN, nil
 }
@@ -35,306 +57,860 @@ func (e *ExpNum) Eval(p Params) (interface{}, TypeEnum, error) {
 }
 
 func (e *ExpIdentifier) Eval(p Params) (interface{}, TypeEnum, error) {
-	var val interface{}
-	var ok = false
-	argsMap := p.CallStack[len(p.CallStack)-1]
-	if val, ok = argsMap[e.val]; !ok {
-		if val, ok = p.MapIdentifier[e.val]; !ok {
-			return nil, TYPE_ERROR, fmt.Errorf("%s: undifined", e.val)
-		}
-	}
-	switch got := val.(type) {
-	case float64:
-		return got, TYPE_FLOAT64, nil
-	case bool:
-		return got, TYPE_BOOLEAN, nil
-	default:
-		return "#<procedure:" + e.val + ">", TYPE_NOTIFICATION, nil
+	if e.val == "." {
+		return nil, TYPE_ERROR, syntaxError("", "illegal use of `.`")
 	}
-
+	if val, ok, err := lookupIdentifier(e.val, p); err != nil {
+		return nil, TYPE_ERROR, err
+	} else if ok {
+		return val, typeOf(val), nil
+	}
+	return nil, TYPE_ERROR, unboundError(e.val)
 }
 
+// operators are evaluated by the loop of machine.go, nested in the evaluation of p.cont
 func (e *ExpOperator) Eval(p Params) (interface{}, TypeEnum, error) {
-	var sum float64 = 0
+	return execute(p, func(k *kont) step { return eval(e, &p, k) })
+}
+
+func (e *ExpKeyword) compute(p *Params, k *kont) step {
+	return returnTo(p, k)(e.Eval(*p))
+}
+
+func (e *ExpQuote) compute(p *Params, k *kont) step {
+	return returnTo(p, k)(e.Eval(*p))
+}
+
+func (e *ExpBool) compute(p *Params, k *kont) step {
+	return returnTo(p, k)(e.Eval(*p))
+}
+
+func (e *ExpNum) compute(p *Params, k *kont) step {
+	return returnTo(p, k)(e.Eval(*p))
+}
+
+func (e *ExpIdentifier) compute(p *Params, k *kont) step {
+	return returnTo(p, k)(e.Eval(*p))
+}
+
+/*
+The step evaluating the operator: sub-expressions are evaluated with frames
+waiting for their values, the expressions in tail position with k. The forms
+which don't evaluate sub-expressions of the same body run as go code.
+*/
+func (e *ExpOperator) compute(p *Params, k *kont) step {
 	switch e.opeType {
-	case "+":
-		for _, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return got, t, err
-			} else if t == TYPE_FLOAT64 {
-				sum += got.(float64)
-			} else {
-				return got, t, fmt.Errorf("operand for + should be number")
+	case "+", "-", "*", "/":
+		// all the operands must be numbers
+		return evalEach(e.operands, p, k, func(v interface{}) error {
+			if !isNumber(v) {
+				return contractError(e.opeType, "number?", v)
 			}
-		}
-		return sum, TYPE_FLOAT64, nil
-	case "-":
-		for i, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return got, t, err
-			} else if t == TYPE_FLOAT64 {
-				if i == 0 {
-					sum += got.(float64)
-				} else {
-					sum -= got.(float64)
+			return nil
+		}, func(nums []interface{}, k *kont) step {
+			return returnTo(p, k)(arithmetic(e.opeType, nums))
+		})
+	case "and", "or":
+		// every value except #f stands for true, the operands are evaluated until the result is known
+		short := e.opeType == "or"
+		var next func(i int, k *kont) step
+		next = func(i int, k *kont) step {
+			if i == len(e.operands) {
+				return ret(!short, TYPE_BOOLEAN, k)
+			}
+			return evalOne(e.operands[i], p, k, func(got interface{}, k *kont) step {
+				if isTrue(got) == short {
+					return ret(short, TYPE_BOOLEAN, k)
 				}
-			} else {
-				return got, t, fmt.Errorf("operand for + should be number")
+				return next(i+1, k)
+			})
+		}
+		return next(0, k)
+	case "not":
+		// check there is only 1 operand for not
+		if len(e.operands) != 1 {
+			return fail(arityError("not", "1", len(e.operands)), p, k)
+		}
+		// number stands for true
+		// not true is false
+		return evalOne(e.operands[0], p, k, func(got interface{}, k *kont) step {
+			return ret(!isTrue(got), TYPE_BOOLEAN, k)
+		})
+	case ">", ">=", "=", "<", "<=":
+		if len(e.operands) == 0 {
+			return fail(arityError(e.opeType, "at least 1", 0), p, k)
+		}
+		expected := "real?"
+		if e.opeType == "=" {
+			expected = "number?"
+		}
+		// all the operands are checked, even after a comparison is false
+		return evalEach(e.operands, p, k, func(v interface{}) error {
+			if !isNumber(v) {
+				return contractError(e.opeType, expected, v)
 			}
+			return nil
+		}, func(nums []interface{}, k *kont) step {
+			return ret(compareNums(e.opeType, nums), TYPE_BOOLEAN, k)
+		})
+	case "if":
+		if len(e.operands) != 3 {
+			return fail(syntaxError("if", "bad syntax;\n if statement should have three expressions"), p, k)
 		}
-		// (- 9) -> -9
-		if len(e.operands) == 1 {
-			sum = -sum
-		}
-		return sum, TYPE_FLOAT64, nil
-	case "*":
-		sum = 1
-		for _, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return got, t, err
-			} else if t == TYPE_FLOAT64 {
-				sum *= got.(float64)
-			} else {
-				return got, t, fmt.Errorf("operand for + should be number")
+		// check the first expression
+		return evalOne(e.operands[0], p, k, func(got interface{}, k *kont) step {
+			if isTrue(got) {
+				// the first expression is true, get the second result
+				return eval(e.operands[1], p, k)
 			}
+			// get the third result
+			return eval(e.operands[2], p, k)
+		})
+	case "lambda":
+		return returnTo(p, k)(evalLambda(e, *p))
+	case "case-lambda":
+		return returnTo(p, k)(evalCaseLambda(e, *p))
+	case "let", "let*":
+		return evalLet(e, p, k)
+	case "begin":
+		if len(e.operands) == 0 {
+			return ret(void, TYPE_VOID, k)
 		}
-		return sum, TYPE_FLOAT64, nil
-	case "/":
-		for i, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return got, t, err
-			} else if t == TYPE_FLOAT64 {
-				if i == 0 {
-					sum = got.(float64)
-				} else {
-					divisor := got.(float64)
-					if divisor == 0 {
-						return nil, TYPE_FLOAT64, fmt.Errorf("divide by zero error")
-					}
-					sum /= divisor
-				}
-			} else {
-				return got, t, fmt.Errorf("operand for + should be number")
+		var next func(i int, k *kont) step
+		next = func(i int, k *kont) step {
+			if i == len(e.operands)-1 {
+				return eval(e.operands[i], p, k)
 			}
+			return eval(e.operands[i], p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+				return next(i+1, k)
+			}))
 		}
-		// ( / 9) -> 1/9
-		if len(e.operands) == 1 {
-			sum = 1 / sum
-		}
-		return sum, TYPE_FLOAT64, nil
-	case "and":
-		res := true
-		for _, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return nil, t, err
-			} else if t == TYPE_BOOLEAN {
-				res = res && got.(bool)
-			} else if t == TYPE_FLOAT64 {
-				continue
-			} else {
-				return nil, t, fmt.Errorf("operand for and should be boolean")
+		return next(0, k)
+	case "quote":
+		// (quote x) is the same as 'x
+		if len(e.operands) != 1 {
+			return fail(syntaxError("quote", "bad syntax"), p, k)
+		}
+		datum, err := expToDatum(e.operands[0])
+		return returnTo(p, k)(datum, typeOf(datum), err)
+	case "quasiquote":
+		return returnTo(p, k)(evalQuasiquote(e, p.at(k)))
+	case "unquote", "unquote-splicing":
+		return fail(syntaxError(e.opeType, "not in quasiquote"), p, k)
+	case "define-values":
+		return evalDefineValues(e, p, k)
+	case "let-values", "let*-values":
+		return evalLetValues(e, p, k)
+	case "receive":
+		return evalReceive(e, p, k)
+	case "parameterize":
+		return evalParameterize(e, p, k)
+	case "with-handlers":
+		return evalWithHandlers(e, p, k)
+	case "let/ec":
+		return evalLetEscape(e, p, k)
+	case "delay":
+		return returnTo(p, k)(evalDelay(e, p.at(k)))
+	case "stream-cons":
+		return returnTo(p, k)(evalStreamCons(e, p.at(k)))
+	case "generator":
+		return returnTo(p, k)(evalGenerator(e, p.at(k)))
+	case "for", "for/list", "for/sum", "for/product", "for/and", "for/or", "for/first", "for/last", "for/fold",
+		"for*", "for*/list", "for*/sum", "for*/product", "for*/and", "for*/or", "for*/first", "for*/last", "for*/fold":
+		return returnTo(p, k)(evalFor(e, p.at(k)))
+	case "struct":
+		return returnTo(p, k)(evalStruct(e, p.at(k)))
+	case "require":
+		return returnTo(p, k)(evalRequire(e, p.at(k)))
+	case "provide":
+		// provide forms are collected by loadModule
+		return fail(syntaxError("provide", "not at module level"), p, k)
+	case "define":
+		return evalDefine(e, p, k)
+	default:
+		// function invocation will fall into here
+		// (fib 2)
+		return evalApplication(e, p, k)
+	}
+}
+
+// (lambda (x y) body ...) or (lambda args body ...)
+func evalLambda(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) < 2 {
+		return nil, TYPE_ERROR, syntaxError("lambda", "bad syntax")
+	}
+	params, err := formalsToParams("lambda", e.operands[0])
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	if fv, err := newFunction("lambda", "", params, e.operands[1:], p); err != nil {
+		return nil, TYPE_ERROR, err
+	} else {
+		fv.line, fv.col = e.line, e.col
+		return fv, TYPE_PROCEDURE, nil
+	}
+}
+
+// (case-lambda [(x) body ...] [(x y) body ...] [args body ...])
+func evalCaseLambda(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	var cl caseLambdaValue
+	for _, c := range e.operands {
+		clause, ok := c.(*ExpOperator)
+		if !ok || len(clause.operands) == 0 {
+			return nil, TYPE_ERROR, syntaxError("case-lambda", "bad syntax")
+		}
+		var formals Exp = clause.proc
+		if formals == nil {
+			formals = newExpIdentifier(clause.opeType)
+		}
+		params, err := formalsToParams("case-lambda", formals)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		fv, err := newFunction("case-lambda", "", params, clause.operands, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if len(fv.keywords) > 0 || len(fv.optionals) > 0 {
+			return nil, TYPE_ERROR, syntaxError("case-lambda", "optional and keyword arguments are not allowed")
+		}
+		fv.line, fv.col = e.line, e.col
+		cl.clauses = append(cl.clauses, fv)
+	}
+	return cl, TYPE_PROCEDURE, nil
+}
+
+/*
+(let ([x 1] [y 2]) body ...), let* sees the previous bindings. Every binding
+makes a new frame, a continuation resuming the evaluation of a binding
+doesn't change the variables seen by the procedures created before.
+*/
+func evalLet(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 2 {
+		return fail(syntaxError(e.opeType, "bad syntax"), p, k)
+	}
+	bindings, ok := e.operands[0].(*ExpOperator)
+	if !ok {
+		return fail(syntaxError(e.opeType, "expected a list of bindings"), p, k)
+	}
+	var bindingList []Exp
+	if bindings.proc != nil {
+		bindingList = append([]Exp{bindings.proc}, bindings.operands...)
+	} else if bindings.opeType != "" {
+		return fail(syntaxError(e.opeType, "expected a list of bindings"), p, k)
+	}
+	names := make([]string, len(bindingList))
+	exps := make([]Exp, len(bindingList))
+	for i, b := range bindingList {
+		binding, ok := b.(*ExpOperator)
+		if !ok || binding.proc != nil || len(binding.operands) != 1 {
+			return fail(syntaxError(e.opeType, "bad binding"), p, k)
+		}
+		names[i], exps[i] = binding.opeType, binding.operands[0]
+	}
+	body := newBody(e.operands[1:])
+	if e.opeType == "let" {
+		// let only sees the enclosing scope
+		return evalEach(exps, p, k, nil, func(vals []interface{}, k *kont) step {
+			frame := p.extendFrame()
+			for i, name := range names {
+				frame[name] = vals[i]
 			}
-			// short circuit
-			if !res {
-				break
+			inner := p.withFrame(frame)
+			return eval(body, &inner, k)
+		})
+	}
+	var next func(i int, scope *Params, k *kont) step
+	next = func(i int, scope *Params, k *kont) step {
+		if i == len(exps) {
+			return eval(body, scope, k)
+		}
+		return evalOne(exps[i], scope, k, func(v interface{}, k *kont) step {
+			frame := scope.extendFrame()
+			frame[names[i]] = v
+			inner := scope.withFrame(frame)
+			return next(i+1, &inner, k)
+		})
+	}
+	return next(0, p, k)
+}
+
+// (define x expr) or (define (f args ...) body ...)
+func evalDefine(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 2 {
+		return fail(syntaxError("define", "bad syntax;\n define statement should have an identifier and an expression"), p, k)
+	}
+	// get identifier
+	switch v := e.operands[0].(type) {
+	case *ExpIdentifier:
+		if len(e.operands) != 2 {
+			return fail(syntaxError("define", "bad syntax;\n define statement should have an identifier and an expression"), p, k)
+		}
+		return evalOne(e.operands[1], p, k, func(expression interface{}, k *kont) step {
+			// (define f (lambda (x) x)) names the procedure f
+			if fv, ok := expression.(functionValue); ok && fv.name == "" {
+				fv.name = v.val
+				expression = fv
+			} else if cl, ok := expression.(caseLambdaValue); ok && cl.name == "" {
+				cl.name = v.val
+				expression = cl
 			}
+			p.MapIdentifier[v.val] = expression
+			return ret(nil, TYPE_DEFINE, k)
+		})
+	case *ExpOperator:
+		// the first operator token after define is function
+		// value of map for function should be (args, function body)
+		if v.proc != nil || v.opeType == "" {
+			return fail(syntaxError("define", "bad syntax;\n define statement should followed by an identifier"), p, k)
 		}
-		return res, TYPE_BOOLEAN, nil
-	case "or":
-		res := false
-		for _, c := range e.operands {
-			if got, t, err := c.Eval(p); err != nil {
-				return nil, t, err
-			} else if t == TYPE_BOOLEAN {
-				value := got.(bool)
-				res = res || value
-			} else if t == TYPE_FLOAT64 {
-				res = true
-			} else {
-				return nil, t, fmt.Errorf("operand for and should be boolean")
+		fv, err := newFunction("define", v.opeType, v.operands, e.operands[1:], *p)
+		if err != nil {
+			return fail(err, p, k)
+		}
+		fv.line, fv.col = e.line, e.col
+		p.MapIdentifier[v.opeType] = fv
+		return ret(nil, TYPE_DEFINE, k)
+	}
+	// type is not identifier
+	return fail(syntaxError("define", "bad syntax;\n define statement should followed by an identifier"), p, k)
+}
+
+/*
+(f 1 #:scale 2): the arguments are evaluated in order, the expression after a
+keyword is a keyword argument, then the procedure
+*/
+func evalApplication(e *ExpOperator, p *Params, k *kont) step {
+	var exps []Exp
+	// the keyword of each expression, empty for positional arguments
+	var kws []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(e.operands); i++ {
+		kw, ok := e.operands[i].(*ExpKeyword)
+		if !ok {
+			exps, kws = append(exps, e.operands[i]), append(kws, "")
+			continue
+		}
+		if i+1 >= len(e.operands) {
+			return fail(syntaxError("application", "missing argument expression after keyword %s", kw.val), p, k)
+		}
+		if seen[kw.val] {
+			return fail(syntaxError("application", "duplicate keyword %s in application", kw.val), p, k)
+		}
+		seen[kw.val] = true
+		i++
+		exps, kws = append(exps, e.operands[i]), append(kws, kw.val[2:])
+	}
+	return evalEach(exps, p, k, nil, func(vals []interface{}, k *kont) step {
+		// expressions are bound to function arguments
+		var args []interface{}
+		var keywords map[string]interface{}
+		for i, v := range vals {
+			if kws[i] == "" {
+				args = append(args, v)
+				continue
 			}
-			// short circuit
-			if res {
-				break
+			if keywords == nil {
+				keywords = make(map[string]interface{})
 			}
+			keywords[kws[i]] = v
 		}
-		return res, TYPE_BOOLEAN, nil
-	case "not":
-		// check there is only 1 operand for not
-		if got, t, err := e.operands[0].Eval(p); err != nil {
-			return got, t, err
-		} else if t == TYPE_BOOLEAN {
-			value := got.(bool)
-			return !value, TYPE_BOOLEAN, nil
-		} else if t == TYPE_FLOAT64 {
-			// number stands for true
-			// not true is false
-			return false, TYPE_BOOLEAN, nil
-		} else {
-			return nil, t, fmt.Errorf("operand for and should be boolean")
+		if e.proc != nil {
+			return evalOne(e.proc, p, k, func(proc interface{}, k *kont) step {
+				return applyStep(proc, args, keywords, p, k)
+			})
 		}
-	case ">":
-		firstNum, secondNum, t, err := getTwoNum(e, p)
-		if err != nil {
-			return nil, t, err
+		// check function name exist in environmnet
+		if value, ok, err := lookupIdentifier(e.opeType, *p); err != nil {
+			return fail(err, p, k)
+		} else if ok {
+			return applyStep(value, args, keywords, p, k)
 		}
-		if firstNum > secondNum {
-			return true, TYPE_BOOLEAN, nil
-		} else {
-			return false, TYPE_BOOLEAN, nil
+		return fail(unboundError(e.opeType), p, k)
+	})
+}
+
+/*
+Call a procedure value with evaluated arguments, used by function invocation and
+by builtins receiving procedures like map
+*/
+func applyProcedure(proc interface{}, args []interface{}, p Params) (interface{}, TypeEnum, error) {
+	return applyWithKeywords(proc, args, nil, p)
+}
+
+// call a procedure whose result is used as one value, e.g. the function given to map
+func applySingle(proc interface{}, args []interface{}, p Params) (interface{}, TypeEnum, error) {
+	return singleValue(applyProcedure(proc, args, p))
+}
+
+// evaluate an expression whose value is used as one value, e.g. an argument
+func evalSingle(e Exp, p Params) (interface{}, TypeEnum, error) {
+	return singleValue(e.Eval(p))
+}
+
+func singleValue(v interface{}, t TypeEnum, err error) (interface{}, TypeEnum, error) {
+	if mv, ok := v.(*multipleValues); ok && err == nil {
+		return nil, TYPE_ERROR, resultArityError("", "1", len(mv.values))
+	}
+	return v, t, err
+}
+
+func applyWithKeywords(proc interface{}, args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+	switch fv := proc.(type) {
+	case functionValue, caseLambdaValue, continuationValue:
+		return execute(p, func(k *kont) step { return applyStep(proc, args, keywords, &p, k) })
+	case builtinValue:
+		if len(keywords) == 0 {
+			return fv.fn(args, p)
 		}
-	case ">=":
-		firstNum, secondNum, t, err := getTwoNum(e, p)
-		if err != nil {
-			return nil, t, err
+		kb, ok := keywordBuiltins[fv.name]
+		for kw := range keywords {
+			if !ok || !kb.accepts(kw) {
+				return nil, TYPE_ERROR, unexpectedKeywordError(proc, kw)
+			}
 		}
-		if firstNum >= secondNum {
-			return true, TYPE_BOOLEAN, nil
-		} else {
-			return false, TYPE_BOOLEAN, nil
+		return kb.fn(args, keywords, p)
+	case *generatorValue:
+		for kw := range keywords {
+			return nil, TYPE_ERROR, unexpectedKeywordError(proc, kw)
 		}
-	case "=":
-		firstNum, secondNum, t, err := getTwoNum(e, p)
-		if err != nil {
-			return nil, t, err
+		return applyGenerator(fv, args, p)
+	case *parameter:
+		for kw := range keywords {
+			return nil, TYPE_ERROR, unexpectedKeywordError(proc, kw)
 		}
-		if firstNum == secondNum {
-			return true, TYPE_BOOLEAN, nil
-		} else {
-			return false, TYPE_BOOLEAN, nil
+		return applyParameter(fv, args, p)
+	default:
+		return nil, TYPE_ERROR, &RacketError{Kind: ERR_CONTRACT, Proc: "application", Message: "not a procedure;\n expected a procedure that can be applied to arguments",
+			Fields: []ErrorField{{"given", FormatValue(proc)}}}
+	}
+}
+
+// the first clause accepting the number of arguments
+func (cl caseLambdaValue) clause(args []interface{}) (functionValue, error) {
+	for _, clause := range cl.clauses {
+		if min, max := clause.arity(); len(args) >= min && (max < 0 || len(args) <= max) {
+			return clause, nil
 		}
-	case "<":
-		firstNum, secondNum, t, err := getTwoNum(e, p)
-		if err != nil {
-			return nil, t, err
+	}
+	var expected []string
+	for _, clause := range cl.clauses {
+		expected = append(expected, arityString(clause.arity()))
+	}
+	return functionValue{}, arityError(procedureName(cl), strings.Join(expected, " or "), len(args))
+}
+
+/*
+Bind the arguments into a new frame, the result is the scope of the body of the
+function. Default values are evaluated when the argument isn't given and can
+refer to previous arguments.
+*/
+func bindArguments(fv functionValue, args []interface{}, keywords map[string]interface{}, p Params) (Params, error) {
+	// match the input parameters
+	if min, max := fv.arity(); len(args) < min || (max >= 0 && len(args) > max) {
+		return p, arityError(procedureName(fv), arityString(min, max), len(args))
+	}
+	for kw := range keywords {
+		if !fv.hasKeyword(kw) {
+			return p, unexpectedKeywordError(fv, kw)
 		}
-		if firstNum < secondNum {
-			return true, TYPE_BOOLEAN, nil
+	}
+	// functions defined in a module see the definitions of their module
+	if fv.globals != nil {
+		p.MapIdentifier = fv.globals
+	}
+	// local variables of the function are the captured ones plus the arguments
+	argsMap := make(map[string]interface{})
+	for k, v := range fv.env {
+		argsMap[k] = v
+	}
+	p = p.withFrame(argsMap)
+	for i, name := range fv.args {
+		argsMap[name] = args[i]
+	}
+	for i, opt := range fv.optionals {
+		if j := len(fv.args) + i; j < len(args) {
+			argsMap[opt.name] = args[j]
+		} else if val, _, err := evalSingle(opt.defaultExp, p); err != nil {
+			return p, err
 		} else {
-			return false, TYPE_BOOLEAN, nil
+			argsMap[opt.name] = val
 		}
-	case "<=":
-		firstNum, secondNum, t, err := getTwoNum(e, p)
-		if err != nil {
-			return nil, t, err
+	}
+	if fv.rest != "" {
+		start := len(fv.args) + len(fv.optionals)
+		if start > len(args) {
+			start = len(args)
 		}
-		if firstNum <= secondNum {
-			return true, TYPE_BOOLEAN, nil
+		argsMap[fv.rest] = sliceToList(args[start:])
+	}
+	for _, kw := range fv.keywords {
+		if val, ok := keywords[kw.keyword]; ok {
+			argsMap[kw.name] = val
+		} else if kw.defaultExp == nil {
+			return p, &RacketError{Kind: ERR_CONTRACT, Proc: procedureName(fv), Message: "required keyword argument not supplied",
+				Fields: []ErrorField{{"required keyword", "#:" + kw.keyword}}}
+		} else if val, _, err := evalSingle(kw.defaultExp, p); err != nil {
+			return p, err
 		} else {
-			return false, TYPE_BOOLEAN, nil
+			argsMap[kw.name] = val
 		}
-	case "if":
-		if len(e.operands) != 3 {
-			return nil, TYPE_ERROR, fmt.Errorf("if statement should have three expressions")
+	}
+	return p, nil
+}
+
+// minimum and maximum number of positional arguments, the maximum is -1 with rest arguments
+func (fv functionValue) arity() (int, int) {
+	if fv.rest != "" {
+		return len(fv.args), -1
+	}
+	return len(fv.args), len(fv.args) + len(fv.optionals)
+}
+
+func (fv functionValue) hasKeyword(kw string) bool {
+	for _, arg := range fv.keywords {
+		if arg.keyword == kw {
+			return true
 		}
-		// check the first expression
-		if got, t, err := e.operands[0].Eval(p); err != nil {
-			return got, t, err
-		} else {
-			expression := false
-			switch v := got.(type) {
-			case bool:
-				if v {
-					expression = true
-				}
-			case float64:
-				expression = true
+	}
+	return false
+}
+
+func arityString(min, max int) string {
+	if min == max {
+		return fmt.Sprint(min)
+	} else if max < 0 {
+		return fmt.Sprintf("at least %d", min)
+	}
+	return fmt.Sprintf("%d to %d", min, max)
+}
+
+func unexpectedKeywordError(proc interface{}, kw string) error {
+	return &RacketError{Kind: ERR_CONTRACT, Proc: "application", Message: "procedure does not expect an argument with given keyword",
+		Fields: []ErrorField{{"procedure", procedureName(proc)}, {"given keyword", "#:" + kw}}}
+}
+
+func procedureName(proc interface{}) string {
+	switch fv := proc.(type) {
+	case functionValue:
+		if fv.name != "" {
+			return fv.name
+		}
+	case caseLambdaValue:
+		if fv.name != "" {
+			return fv.name
+		}
+	case builtinValue:
+		return fv.name
+	case *parameter:
+		return fv.name
+	}
+	return formatDatum(proc)
+}
+
+// local variables shadow global ones, which shadow builtins and then the prelude, whose loading can fail
+func lookupIdentifier(name string, p Params) (interface{}, bool, error) {
+	if val, ok := p.CallStack[len(p.CallStack)-1][name]; ok {
+		return val, true, nil
+	}
+	if val, ok := p.MapIdentifier[name]; ok {
+		return val, true, nil
+	}
+	if val, ok := builtins[name]; ok {
+		return val, true, nil
+	}
+	if operatorProcedures[name] {
+		return builtinValue{name: name, fn: func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			// reuse the evaluation of operators on already evaluated operands
+			root := newExpOperator(name)
+			for _, arg := range args {
+				root.operands = append(root.operands, newExpQuote(arg))
 			}
+			return root.Eval(p)
+		}}, true, nil
+	}
+	return preludeBinding(name, p)
+}
 
-			if expression {
-				// the first expression is true, get the second result
-				return e.operands[1].Eval(p)
-			} else {
-				// get the third result
-				return e.operands[2].Eval(p)
+// a copy of the innermost frame, for the variables of a new scope
+func (p Params) extendFrame() map[string]interface{} {
+	frame := make(map[string]interface{})
+	for k, v := range p.CallStack[len(p.CallStack)-1] {
+		frame[k] = v
+	}
+	return frame
+}
+
+/*
+The scope whose local variables are frame. Only the innermost frame is looked
+up and it has the enclosing variables, so the enclosing frames aren't kept and
+the steps of a continuation resumed later find the frames they had.
+*/
+func (p Params) withFrame(frame map[string]interface{}) Params {
+	p.CallStack = []map[string]interface{}{frame}
+	return p
+}
+
+// operators which are also procedures, and, or, if and define are syntax
+var operatorProcedures = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "not": true,
+	"=": true, ">": true, ">=": true, "<": true, "<=": true,
+}
+
+// a function body with several expressions is evaluated like begin
+func newBody(exps []Exp) Exp {
+	if len(exps) == 1 {
+		return exps[0]
+	}
+	return &ExpOperator{opeType: "begin", operands: exps}
+}
+
+// the quoted datum of an already parsed expression, e.g. (quote (a 1)) -> '(a 1)
+func expToDatum(e Exp) (interface{}, error) {
+	switch v := e.(type) {
+	case *ExpNum:
+		return v.val, nil
+	case *ExpBool:
+		return v.val, nil
+	case *ExpIdentifier:
+		return symbol(v.val), nil
+	case *ExpKeyword:
+		return keyword(v.val[2:]), nil
+	case *ExpQuote:
+		// strings, characters, regexps and vectors are parsed as quoted data too
+		switch v.val.(type) {
+		case string, char, *regexpValue, *vector:
+			return v.val, nil
+		}
+		return sliceToList([]interface{}{symbol("quote"), v.val}), nil
+	case *ExpMatch:
+		return expToDatum(v.ExpOperator)
+	case *ExpOperator:
+		var elements []interface{}
+		for _, element := range listElements(v) {
+			datum, err := expToDatum(element)
+			if err != nil {
+				return nil, err
 			}
+			elements = append(elements, datum)
 		}
-	case "define":
-		if len(e.operands) != 2 {
-			return nil, TYPE_ERROR, fmt.Errorf("define statement should have an identifier and an expression")
+		// (a . b)
+		if n := len(elements); n >= 3 && elements[n-2] == symbol(".") {
+			var tail = elements[n-1]
+			for i := n - 3; i >= 0; i-- {
+				tail = &pair{car: elements[i], cdr: tail}
+			}
+			return tail, nil
 		}
-		// get identifier
-		switch v := e.operands[0].(type) {
-		case *ExpIdentifier:
-			if expression, t, err := e.operands[1].Eval(p); err != nil {
-				return expression, t, err
-			} else {
-				p.MapIdentifier[v.val] = expression
+		return sliceToList(elements), nil
+	}
+	return nil, syntaxError("quote", "bad syntax")
+}
+
+// `(1 ,x ,@l) is the datum with x evaluated and the elements of the list l spliced
+func evalQuasiquote(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) != 1 {
+		return nil, TYPE_ERROR, syntaxError("quasiquote", "bad syntax")
+	}
+	if datum, err := quasiquoteDatum(e.operands[0], 1, p); err != nil {
+		return nil, TYPE_ERROR, err
+	} else {
+		return datum, typeOf(datum), nil
+	}
+}
+
+// the unquoted expressions are evaluated at depth 1, nested quasiquotes are kept as data
+func quasiquoteDatum(e Exp, depth int, p Params) (interface{}, error) {
+	if m, ok := e.(*ExpMatch); ok {
+		e = m.ExpOperator
+	}
+	op, ok := e.(*ExpOperator)
+	if !ok || (op.proc == nil && op.opeType == "") {
+		return expToDatum(e)
+	}
+	if op.proc == nil && len(op.operands) == 1 {
+		nested := depth
+		switch op.opeType {
+		case "unquote", "unquote-splicing":
+			nested--
+		case "quasiquote":
+			nested++
+		}
+		if nested == 0 && op.opeType == "unquote" {
+			v, _, err := evalSingle(op.operands[0], p)
+			return v, err
+		} else if nested == 0 {
+			return nil, syntaxError("unquote-splicing", "invalid context within quasiquote")
+		} else if nested != depth {
+			inner, err := quasiquoteDatum(op.operands[0], nested, p)
+			if err != nil {
+				return nil, err
 			}
-		case *ExpOperator:
-			// the first operator token after define is function
-			// value of map for function should be (args, function body)
-			argsNum := len(v.operands)
-			args := make([]string, argsNum)
-			for i := 0; i < argsNum; i++ {
-				args[i] = v.operands[i].(*ExpIdentifier).val
+			return sliceToList([]interface{}{symbol(op.opeType), inner}), nil
+		}
+	}
+	elements := listElements(op)
+	var values []interface{}
+	var tail interface{} = null
+	for i := 0; i < len(elements); i++ {
+		if id, ok := elements[i].(*ExpIdentifier); ok && (id.val == "." || id.val == "unquote") && i == len(elements)-2 {
+			// `(a . b), `(a . ,b) is read as `(a unquote b)
+			last := elements[i+1]
+			if id.val == "unquote" {
+				last = &ExpOperator{opeType: "unquote", operands: []Exp{last}}
 			}
-			p.MapIdentifier[v.opeType] = functionValue{args: args, body: e.operands[1]}
-		default:
-			// type is not identifier
-			return nil, TYPE_ERROR, fmt.Errorf("define statement should followed by an identifier")
+			var err error
+			if tail, err = quasiquoteDatum(last, depth, p); err != nil {
+				return nil, err
+			}
+			break
 		}
-		return nil, TYPE_DEFINE, nil
-	default:
-		// function invocation will fall into here
-		// (fib 2)
-		argsNum := len(e.operands)
-		args := make([]interface{}, argsNum)
-		for i := 0; i < argsNum; i++ {
-			// expressions are bound to function arguments
-			args[i], _, _ = e.operands[i].Eval(p)
+		if splice, ok := elements[i].(*ExpOperator); ok && depth == 1 && splice.proc == nil &&
+			splice.opeType == "unquote-splicing" && len(splice.operands) == 1 {
+			v, _, err := evalSingle(splice.operands[0], p)
+			if err != nil {
+				return nil, err
+			}
+			l, ok := listToSlice(v)
+			if !ok {
+				return nil, contractError("unquote-splicing", "list?", v)
+			}
+			values = append(values, l...)
+			continue
 		}
-		// check function name exist in environmnet
-		if value, ok := p.MapIdentifier[e.opeType]; ok {
-			// the identifier is function not variable
-			if fv, ok := value.(functionValue); ok {
-				// match the input parameters
-				if len(fv.args) != argsNum {
-					return nil, TYPE_ERROR, fmt.Errorf("arity mismatch")
-				}
-				// before we modify the argsMap, put the existing argsMap into stack
+		v, err := quasiquoteDatum(elements[i], depth, p)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	for i := len(values) - 1; i >= 0; i-- {
+		tail = &pair{car: values[i], cdr: tail}
+	}
+	return tail, nil
+}
+
+// elements of a parenthesized list, e.g. the arguments (a [b 1] . rest)
+func listElements(e *ExpOperator) []Exp {
+	if e.proc != nil {
+		return append([]Exp{e.proc}, e.operands...)
+	} else if e.opeType == "" {
+		return nil
+	}
+	return append([]Exp{newExpIdentifier(e.opeType)}, e.operands...)
+}
 
-				argsMap := make(map[string]interface{})
-				for i := 0; i < argsNum; i++ {
-					argsMap[fv.args[i]] = args[i]
+// arguments of lambda, a single identifier takes all the arguments as a list: (lambda args ...)
+func formalsToParams(form string, formals Exp) ([]Exp, error) {
+	switch v := formals.(type) {
+	case *ExpIdentifier:
+		return []Exp{newExpIdentifier("."), v}, nil
+	case *ExpOperator:
+		return listElements(v), nil
+	}
+	return nil, syntaxError(form, "expected a list of arguments")
+}
+
+/*
+Build a function from its arguments and body, arguments can be required a,
+optional [b 10], keyword #:scale [scale 1] or #:scale scale and rest . rest
+*/
+func newFunction(form string, name string, params []Exp, body []Exp, p Params) (functionValue, error) {
+	fv := functionValue{name: name, body: newBody(body), env: p.CallStack[len(p.CallStack)-1], globals: p.MapIdentifier}
+	for i := 0; i < len(params); i++ {
+		switch param := params[i].(type) {
+		case *ExpIdentifier:
+			if param.val != "." && len(fv.optionals) > 0 {
+				return fv, syntaxError(form, "default-value expression missing for %s", param.val)
+			} else if param.val != "." {
+				fv.args = append(fv.args, param.val)
+				continue
+			}
+			// the rest argument is the last one
+			if i != len(params)-2 {
+				return fv, syntaxError(form, "illegal use of `.`")
+			}
+			if rest, ok := params[i+1].(*ExpIdentifier); !ok || rest.val == "." {
+				return fv, syntaxError(form, "not an identifier: %s", strings.TrimSpace(params[i+1].Print()))
+			} else {
+				fv.rest = rest.val
+			}
+			i++
+		case *ExpOperator:
+			if opt, err := optionalParam(form, param); err != nil {
+				return fv, err
+			} else {
+				fv.optionals = append(fv.optionals, opt)
+			}
+		case *ExpKeyword:
+			if i+1 >= len(params) {
+				return fv, syntaxError(form, "missing argument identifier after keyword %s", param.val)
+			}
+			i++
+			kw := keywordArg{keyword: param.val[2:]}
+			if id, ok := params[i].(*ExpIdentifier); ok && id.val != "." {
+				kw.name = id.val
+			} else if opt, ok := params[i].(*ExpOperator); ok {
+				if arg, err := optionalParam(form, opt); err != nil {
+					return fv, err
+				} else {
+					kw.name, kw.defaultExp = arg.name, arg.defaultExp
 				}
-				p.CallStack = append(p.CallStack, argsMap)
-				// execute the function body
-				res, t, err := fv.body.Eval(p)
-				// pop up the upmost local variable
-				p.CallStack = p.CallStack[:len(p.CallStack)-1]
-				return res, t, err
 			} else {
-				return nil, TYPE_ERROR, fmt.Errorf("application: not a procedure")
+				return fv, syntaxError(form, "not an identifier: %s", strings.TrimSpace(params[i].Print()))
 			}
-		} else {
-			return nil, TYPE_ERROR, fmt.Errorf("%s undifined", e.opeType)
+			if fv.hasKeyword(kw.keyword) {
+				return fv, syntaxError(form, "duplicate keyword %s", param.val)
+			}
+			fv.keywords = append(fv.keywords, kw)
+		default:
+			return fv, syntaxError(form, "not an identifier: %s", strings.TrimSpace(param.Print()))
 		}
 	}
+	return fv, nil
 }
 
-/*
-for comparison operators like =, >=, >, <=, <, there operands are supposed to be numbers.
-so get these two numbers otherwie return error.
-*/
-func getTwoNum(e *ExpOperator, p Params) (float64, float64, TypeEnum, error) {
-	var firstNum, secondNum float64
-	if len(e.operands) != 2 {
-		return 0, 0, TYPE_ERROR, fmt.Errorf("arithmetic comparison should have two operands")
-	}
-	if got, t, err := e.operands[0].Eval(p); err != nil {
-		return 0, 0, t, err
-	} else if t == TYPE_FLOAT64 {
-		firstNum = got.(float64)
-	} else {
-		return 0, 0, t, fmt.Errorf("operand for arithmetic comparison should be number")
+// [b 10]: argument name and its default value
+func optionalParam(form string, param *ExpOperator) (optionalArg, error) {
+	if param.proc != nil || param.opeType == "" || len(param.operands) != 1 {
+		return optionalArg{}, syntaxError(form, "bad argument sequence: %s", strings.TrimSpace(param.Print()))
 	}
-	if got, t, err := e.operands[1].Eval(p); err != nil {
-		return 0, 0, t, err
-	} else if t == TYPE_FLOAT64 {
-		secondNum = got.(float64)
-	} else {
-		return 0, 0, t, fmt.Errorf("operand for arithmetic comparison should be number")
+	return optionalArg{name: param.opeType, defaultExp: param.operands[0]}, nil
+}
+
+// (+ 1 2 3), (- 9) -> -9 and (/ 9) -> 1/9, exact when all the operands are
+func arithmetic(op string, nums []interface{}) (interface{}, TypeEnum, error) {
+	var acc interface{} = int64(0)
+	if op == "*" || op == "/" {
+		acc = int64(1)
+	}
+	if len(nums) > 1 && (op == "-" || op == "/") {
+		acc, nums = nums[0], nums[1:]
+	}
+	for _, n := range nums {
+		switch op {
+		case "+":
+			acc = numAdd(acc, n)
+		case "-":
+			acc = numSub(acc, n)
+		case "*":
+			acc = numMul(acc, n)
+		case "/":
+			var err error
+			if acc, err = numDiv("/", acc, n); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+		}
+	}
+	return acc, TYPE_FLOAT64, nil
+}
+
+// (< a b c ...) is true when every number is in order with the next one
+func compareNums(op string, nums []interface{}) bool {
+	for i := 1; i < len(nums); i++ {
+		if !numCompare(op, nums[i-1], nums[i]) {
+			return false
+		}
 	}
-	return firstNum, secondNum, TYPE_FLOAT64, nil
+	return true
 }
@@ -5,64 +5,65 @@ import (
 )
 
 func TestEvaluator(t *testing.T) {
-	var want float64
+	p := Params{MapIdentifier: make(map[string]interface{}), CallStack: make([]map[string]interface{}, 1), modules: newModuleRegistry()}
+	var want interface{}
 	// (+ 2 3)
 	var tokens = []Token{tokenLP, tokenAdd, token2, token3, tokenRP}
 	root, _ := Parse(tokens)
-	want = 5
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(5)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected  evaluated result is", want, " but got", result)
 	}
 	// (* 2 3 (+ 2))
 	tokens = []Token{tokenLP, tokenMUL, token2, token3, tokenLP, tokenAdd, token2, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	want = 12
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(12)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected  evaluated result is", want, " but got", result)
 	}
 	// (/ 2 (* 2 3) (+ 2))
 	tokens = []Token{tokenLP, tokenDIV, token2, tokenLP, tokenMUL, token2, token3, tokenRP, tokenLP, tokenAdd, token2, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	want = 0.16666666666666666
-	if result, _, _ := root.Eval(); result.(float64) != want {
-		t.Error("expected evaluated result is", want, " but got", result)
+	// exact division gives a fraction
+	if result, _, _ := root.Eval(p); FormatValue(result) != "1/6" {
+		t.Error("expected evaluated result is 1/6 but got", FormatValue(result))
 	}
 	// (+)
 	tokens = []Token{tokenLP, tokenAdd, tokenRP}
 	root, _ = Parse(tokens)
-	want = 0
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(0)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected evaluated result is", want, " but got", result)
 	}
 	// (*)
 	tokens = []Token{tokenLP, tokenMUL, tokenRP}
 	root, _ = Parse(tokens)
-	want = 1
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(1)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected evaluated result is", want, " but got", result)
 	}
 	// (/ 2 (- 3 3))  -> divide by 0
 	tokens = []Token{tokenLP, tokenDIV, token2, tokenLP, tokenSub, token3, token3, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(division by 0) doesn't show up for expression (/ 2 (- 3 3))")
 	}
 	// (not 4)
 	tokens = []Token{tokenLP, tokenNOT, token4, tokenRP}
 	root, _ = Parse(tokens)
-	if result, _, _ := root.Eval(); result.(bool) != false {
+	if result, _, _ := root.Eval(p); result.(bool) != false {
 		t.Error("expected evaluated result is false but got", result)
 	}
 	// (and false (/ 4 0))
 	tokens = []Token{tokenLP, tokenAND, tokenFalse, tokenLP, tokenDIV, token4, token0, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	if result, _, _ := root.Eval(); result.(bool) != false {
+	if result, _, _ := root.Eval(p); result.(bool) != false {
 		t.Error("expected evaluated result is false but got", result)
 	}
 	// (or (not true) (<= 2 3))
 	tokens = []Token{tokenLP, tokenOR, tokenLP, tokenNOT, tokenTrue, tokenRP, tokenLP, tokenLessEqual, token2, token3, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	if result, _, _ := root.Eval(); result.(bool) != true {
+	if result, _, _ := root.Eval(p); result.(bool) != true {
 		t.Error("expected evaluated result is true but got", result)
 	}
 	// (if (and (>= 1 2) (= 3 4)) (/ 1 0) (or true false))
@@ -70,33 +71,34 @@ func TestEvaluator(t *testing.T) {
 		tokenEqual, token3, token4, tokenRP, tokenRP, tokenLP, tokenDIV, token1, token0, tokenRP, tokenLP, tokenOR, tokenTrue,
 		tokenFalse, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	if result, _, _ := root.Eval(); result.(bool) != true {
+	if result, _, _ := root.Eval(p); result.(bool) != true {
 		t.Error("expected evaluated result is true but got", result)
 	}
 	// (if 4 (/ 1 0) (or true false))
 	tokens = []Token{tokenLP, tokenIf, token4, tokenLP, tokenDIV, token1, token0, tokenRP, tokenLP, tokenOR, tokenTrue,
 		tokenFalse, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(division by 0) doesn't show up for expression (if 4 (/ 1 0) (or true false))")
 	}
 	// (>= 4 true)
 	tokens = []Token{tokenLP, tokenLargeEqual, token4, tokenTrue, tokenRP}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(division by 0) doesn't show up for expression (>= 4 true)")
 	}
 }
 
 func TestVariableAndFunctionEvaluator(t *testing.T) {
-	var want float64
+	p := Params{MapIdentifier: make(map[string]interface{}), CallStack: make([]map[string]interface{}, 1), modules: newModuleRegistry()}
+	var want interface{}
 	// (define x (+ 1 2))
 	var tokens = []Token{tokenLP, tokenDefine, tokenIdentifierX, tokenLP, tokenAdd, token1, token2, tokenRP, tokenRP}
 	root, _ := Parse(tokens)
-	root.Eval()
+	root.Eval(p)
 	root, _ = Parse([]Token{tokenIdentifierX})
-	want = 3
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(3)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected  evaluated result is", want, " but got", result)
 	}
 
@@ -106,32 +108,83 @@ func TestVariableAndFunctionEvaluator(t *testing.T) {
 		token1, tokenRP, tokenRP, tokenLP, tokenIdentifierFib, tokenLP, tokenSub, tokenIdentifierX, token2, tokenRP, tokenRP,
 		tokenRP, tokenRP, tokenRP}
 	root, _ = Parse(tokens)
-	root.Eval()
+	root.Eval(p)
 	root, _ = Parse([]Token{tokenLP, tokenIdentifierFib, token4, tokenRP})
-	want = 3
-	if result, _, _ := root.Eval(); result.(float64) != want {
+	want = int64(3)
+	if result, _, _ := root.Eval(p); result != want {
 		t.Error("expected  evaluated result is", want, " but got", result)
 	}
 
 	// (x)
 	tokens = []Token{tokenLP, tokenIdentifierX, tokenRP}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(application: not a procedure) doesn't show up for expression (x)")
 	}
 
 	// z
 	tokens = []Token{tokenIdentifierY}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(yL undefined) doesn't show up for expression y")
 	}
 
 	// (fib 2 3)
 	tokens = []Token{tokenLP, tokenIdentifierFib, token2, token3, tokenRP}
 	root, _ = Parse(tokens)
-	if _, _, err := root.Eval(); err == nil {
+	if _, _, err := root.Eval(p); err == nil {
 		t.Error("expected evaluation error(fib: arity mismatch) doesn't show up for expression (fib 2 3)")
 	}
 
 }
+
+func TestFunctionArguments(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (f a . rest) (list a rest))")
+	evalLine(p, "(define (g a [b (* a 2)]) (+ a b))")
+	evalLine(p, "(define (h x #:scale [s 2] #:add k) (+ (* x s) k))")
+	evalLine(p, "(define c (case-lambda [(x) x] [(x y) (+ x y)] [(x y . r) r]))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(f 1 2 3)", "'(1 (2 3))"},
+		{"(f 1)", "'(1 ())"},
+		{"((lambda args args) 1 2)", "'(1 2)"},
+		{"(g 1)", "3"},
+		{"(g 1 5)", "6"},
+		{"(h 3 #:add 1)", "7"},
+		{"(h 3 #:add 1 #:scale 10)", "31"},
+		{"(c 1)", "1"},
+		{"(c 1 2)", "3"},
+		{"(c 1 2 3 4)", "'(3 4)"},
+		{"(apply g '(1 1))", "2"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errCases := []struct {
+		line string
+		want string
+	}{
+		{"(f)", "f: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: at least 1\n  given: 0"},
+		{"(g 1 2 3)", "g: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 1 to 2\n  given: 3"},
+		{"(h 3)", "h: required keyword argument not supplied\n  required keyword: #:add"},
+		{"(h 3 #:add 1 #:foo 2)", "application: procedure does not expect an argument with given keyword\n  procedure: h\n  given keyword: #:foo"},
+		{"(c)", "c: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 1 or 2 or at least 2\n  given: 0"},
+		{"(define (k [a 1] b) b)", "define: default-value expression missing for b"},
+		{"(define (k a . b c) b)", "define: illegal use of `.`"},
+	}
+	for _, c := range errCases {
+		if _, _, err := evalLine(p, c.line); err == nil {
+			t.Error("expected evaluation error doesn't show up for expression", c.line)
+		} else if err.Error() != c.want {
+			t.Error("expected evaluation error of", c.line, "is", c.want, " but got", err)
+		}
+	}
+}
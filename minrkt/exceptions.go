@@ -0,0 +1,202 @@
+package minrkt
+
+import (
+	"errors"
+	"strings"
+)
+
+// value of exceptions, created by error or when evaluation fails and the error is caught by with-handlers
+type exnValue struct {
+	kind    ErrorKind
+	message string
+}
+
+// the value seen by exception handlers: the raised value or an exception describing the error
+func raisedValue(err error) interface{} {
+	var re *RacketError
+	if !errors.As(err, &re) {
+		return exnValue{kind: ERR_FAIL, message: err.Error()}
+	}
+	if re.Value != nil {
+		return re.Value
+	}
+	return exnValue{kind: re.Kind, message: re.Error()}
+}
+
+// (raise v) returns this error, exceptions keep their kind and message
+func raiseError(v interface{}) *RacketError {
+	if exn, ok := v.(exnValue); ok {
+		return &RacketError{Kind: exn.kind, Message: exn.message, Value: v}
+	}
+	if kind, message, ok := exnStruct(v); ok {
+		return &RacketError{Kind: kind, Message: message, Value: v}
+	}
+	return &RacketError{Value: v}
+}
+
+// kind and message of instances of structs defined with exn or its subtypes as parent
+func exnStruct(v interface{}) (ErrorKind, string, bool) {
+	s, ok := v.(*structValue)
+	if !ok || s.stype.exnKind == "" {
+		return "", "", false
+	}
+	message, _ := s.fields[0].(string)
+	return s.stype.exnKind, message, true
+}
+
+// exn:fail:contract:arity is a exn:fail:contract, which is a exn:fail, which is a exn
+func isKindOf(kind ErrorKind, parent ErrorKind) bool {
+	return kind == parent || strings.HasPrefix(string(kind), string(parent)+":")
+}
+
+func init() {
+	defineBuiltin("raise", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the optional second argument is racket's barrier flag, it has no effect here
+		if err := checkArity("raise", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return nil, TYPE_ERROR, raiseError(args[0])
+	})
+	defineBuiltin("error", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (error 'who "format ~a" v ...), (error "message" v ...) or (error 'who)
+		if err := checkArity("error", args, 1, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var message string
+		switch first := args[0].(type) {
+		case symbol:
+			if len(args) == 1 {
+				message = string(first)
+			} else if format, ok := args[1].(string); !ok {
+				return nil, TYPE_ERROR, contractError("error", "string?", args[1])
+			} else if formatted, err := racketFormat("error", format, args[2:]); err != nil {
+				return nil, TYPE_ERROR, err
+			} else {
+				message = string(first) + ": " + formatted
+			}
+		case string:
+			message = first
+			for _, v := range args[1:] {
+				message += " " + FormatValue(v)
+			}
+		default:
+			return nil, TYPE_ERROR, contractError("error", "(or/c symbol? string?)", args[0])
+		}
+		return nil, TYPE_ERROR, raiseError(exnValue{kind: ERR_FAIL, message: message})
+	})
+	defineBuiltin("raise-argument-error", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (raise-argument-error 'who "expected?" v) raises the contract violations of builtins
+		if err := checkArity("raise-argument-error", args, 3, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		who, ok := args[0].(symbol)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("raise-argument-error", "symbol?", args[0])
+		}
+		expected, ok := args[1].(string)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("raise-argument-error", "string?", args[1])
+		}
+		return nil, TYPE_ERROR, contractError(string(who), expected, args[2])
+	})
+	defineBuiltin("exn-message", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("exn-message", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if exn, ok := args[0].(exnValue); ok {
+			return exn.message, TYPE_STRING, nil
+		}
+		if _, message, ok := exnStruct(args[0]); ok {
+			return message, TYPE_STRING, nil
+		}
+		return nil, TYPE_ERROR, contractError("exn-message", "exn?", args[0])
+	})
+	// exn?, exn:fail?, exn:fail:contract? ...
+	for _, kind := range exnKinds {
+		parent := kind
+		name := string(kind) + "?"
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			if exn, ok := args[0].(exnValue); ok {
+				return isKindOf(exn.kind, parent), TYPE_BOOLEAN, nil
+			}
+			kind, _, ok := exnStruct(args[0])
+			return ok && isKindOf(kind, parent), TYPE_BOOLEAN, nil
+		})
+	}
+	defineNative("dynamic-wind", func(args []interface{}, p *Params, k *kont) step {
+		// (dynamic-wind pre-thunk value-thunk post-thunk): post-thunk runs even when value-thunk fails,
+		// and jumping back into value-thunk with a continuation runs pre-thunk again
+		if err := checkArity("dynamic-wind", args, 3, 3); err != nil {
+			return fail(err, p, k)
+		}
+		return applyStep(args[0], nil, nil, p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+			w := &kont{next: k, mark: &windFrame{pre: args[0], post: args[2], p: p}}
+			w.resume = func(v interface{}, t TypeEnum, k *kont) step {
+				return applyStep(args[2], nil, nil, p, push(k, func(_ interface{}, _ TypeEnum, k *kont) step {
+					return ret(v, t, k)
+				}))
+			}
+			return applyStep(args[1], nil, nil, p, w)
+		}))
+	})
+}
+
+// the frame of the value thunk of dynamic-wind, its thunks run when a jump or an error leaves or enters it
+type windFrame struct {
+	pre, post interface{}
+	p         *Params
+}
+
+// call the thunk in the continuation k, below the frame
+func (w *windFrame) run(thunk interface{}, k *kont) error {
+	_, _, err := applyProcedure(thunk, nil, w.p.at(k))
+	return err
+}
+
+// the frame of the body of with-handlers
+type handlersFrame struct {
+	preds, handlers []interface{}
+	p               *Params
+}
+
+/*
+(with-handlers ([pred handler] ...) body ...): when the body fails, the handler of the
+first predicate accepting the raised value is called with it outside of the body,
+otherwise the error goes on. Jumps to continuations aren't errors, they go through.
+*/
+func evalWithHandlers(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 2 {
+		return fail(syntaxError("with-handlers", "bad syntax"), p, k)
+	}
+	clauses, ok := e.operands[0].(*ExpOperator)
+	if !ok || (clauses.proc == nil && clauses.opeType != "") {
+		return fail(syntaxError("with-handlers", "expected a list of handlers"), p, k)
+	}
+	// predicates and handlers are evaluated before the body
+	var preds, handlers []interface{}
+	for _, c := range listElements(clauses) {
+		clause, ok := c.(*ExpOperator)
+		if !ok || len(clause.operands) != 1 {
+			return fail(syntaxError("with-handlers", "bad handler: %s", strings.TrimSpace(c.Print())), p, k)
+		}
+		var predExp Exp = clause.proc
+		if predExp == nil {
+			predExp = newExpIdentifier(clause.opeType)
+		}
+		pred, _, err := predExp.Eval(p.at(k))
+		if err != nil {
+			return fail(err, p, k)
+		}
+		handler, _, err := clause.operands[0].Eval(p.at(k))
+		if err != nil {
+			return fail(err, p, k)
+		}
+		preds = append(preds, pred)
+		handlers = append(handlers, handler)
+	}
+	h := &kont{next: k, mark: &handlersFrame{preds: preds, handlers: handlers, p: p}}
+	return eval(newBody(e.operands[1:]), p, h)
+}
@@ -0,0 +1,57 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExceptions(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (safe-div a b) (with-handlers ([exn:fail:contract:divide-by-zero? (lambda (e) 'infinity)]) (/ a b)))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{`(with-handlers ([exn:fail? exn-message]) (error "bad thing" 1 'a "s"))`, `"bad thing 1 'a \"s\""`},
+		{`(with-handlers ([exn:fail? exn-message]) (error 'parse "bad token ~a at ~s" 'x "line 1"))`, `"parse: bad token x at \"line 1\""`},
+		{`(with-handlers ([exn:fail? exn-message]) (error 'urk))`, `"urk"`},
+		{"(safe-div 4 2)", "2"},
+		{"(safe-div 4 0)", "'infinity"},
+		{"(with-handlers ([exn:fail:contract? exn-message]) (car 1))", `"car: contract violation\n  expected: pair?\n  given: 1"`},
+		{"(with-handlers ([exn:fail:contract:arity? (lambda (e) 'arity)]) (safe-div 1))", "'arity"},
+		{"(with-handlers ([exn:fail:syntax? (lambda (e) 'syntax)]) (if 1 2))", "'syntax"},
+		{"(with-handlers ([exn:fail:contract:variable? (lambda (e) 'unbound)]) undefined-thing)", "'unbound"},
+		// raise accepts any value, the first matching predicate wins
+		{"(with-handlers ([string? (lambda (e) 'string)] [symbol? (lambda (e) e)]) (raise 'oops))", "'oops"},
+		{"(with-handlers ([(lambda (e) #t) (lambda (e) (list 'caught e))]) (+ 1 (raise 42)))", "'(caught 42)"},
+		{"(with-handlers ([exn? (lambda (e) 'exn)] [(lambda (e) #t) (lambda (e) 'other)]) (raise 42))", "'other"},
+		// raised exceptions can be caught again by an outer handler
+		{"(with-handlers ([exn:fail? exn-message]) (with-handlers ([string? (lambda (e) e)]) (error \"inner\")))", `"inner"`},
+		{"(with-handlers ([exn:fail? exn-message]) (with-handlers ([exn:fail? raise]) (error \"again\")))", `"again"`},
+		{"(with-handlers () 1 2)", "2"},
+		{"(dynamic-wind (lambda () 1) (lambda () 2) (lambda () 3))", "2"},
+		{"(with-handlers ([exn:fail? exn-message]) (dynamic-wind (lambda () 0) (lambda () (car '())) (lambda () (error \"post ran\"))))", `"post ran"`},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	// uncaught exceptions reach the caller as a RacketError
+	_, _, err := evalLine(p, "(with-handlers ([string? (lambda (e) e)]) (raise 42))")
+	var re *RacketError
+	if !errors.As(err, &re) || re.Value != int64(42) || err.Error() != "uncaught exception: 42" {
+		t.Error("expected uncaught exception: 42 but got", err)
+	}
+	_, _, err = evalLine(p, "(error 'f \"~a\")")
+	if !errors.As(err, &re) || re.Kind != ERR_CONTRACT {
+		t.Error("expected format error for missing arguments but got", err)
+	}
+	_, _, err = evalLine(p, "(error 'f \"failed ~a\" 1)")
+	if !errors.As(err, &re) || re.Kind != ERR_FAIL || err.Error() != "f: failed 1" {
+		t.Error("expected f: failed 1 but got", err)
+	}
+}
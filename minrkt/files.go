@@ -0,0 +1,257 @@
+package minrkt
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilePolicy decides which files the programs of an Interpreter can read and write
+type FilePolicy struct {
+	// directory containing the accessible files, the initial current-directory of the programs;
+	// symbolic links are followed before checking paths, any file is accessible when Root is empty
+	Root  string
+	Read  bool
+	Write bool
+}
+
+// AllowAllFiles lets programs read and write any file like the REPL does
+var AllowAllFiles = FilePolicy{Read: true, Write: true}
+
+// the path to open, relative paths are resolved against dir; an error when the policy refuses to access it,
+// nil policies refuse nothing
+func (policy *FilePolicy) resolve(proc string, path string, dir string, write bool) (string, error) {
+	full := path
+	if dir != "" && !filepath.IsAbs(path) {
+		full = filepath.Join(dir, path)
+	}
+	if policy == nil {
+		return full, nil
+	}
+	access, allowed := "read", policy.Read
+	if write {
+		access, allowed = "write", policy.Write
+	}
+	if !allowed {
+		return "", newRacketError(ERR_FILESYSTEM, proc, "`%s' access denied for %s", access, path)
+	}
+	if policy.Root == "" {
+		return full, nil
+	}
+	root, err := filepath.EvalSymlinks(policy.Root)
+	if err != nil {
+		root = policy.Root
+	}
+	// the file which is opened is the real one, a link inside Root can't give access to the files outside
+	real, err := realPath(full)
+	if err != nil {
+		return "", newRacketError(ERR_FILESYSTEM, proc, "`%s' access denied for %s", access, path)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newRacketError(ERR_FILESYSTEM, proc, "`%s' access denied for %s", access, path)
+	}
+	return real, nil
+}
+
+// the path without symbolic links, the part of it which doesn't exist yet is kept as it is, e.g. a file
+// being created; links to missing files are errors since creating their target could escape Root
+func realPath(full string) (string, error) {
+	rest := ""
+	for dir := full; ; {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if _, lerr := os.Lstat(dir); lerr == nil || !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+func pathArg(proc string, arg interface{}) (string, error) {
+	path, ok := arg.(string)
+	if !ok || path == "" {
+		return "", contractError(proc, "path-string?", arg)
+	}
+	return path, nil
+}
+
+// e.g. open-input-file: cannot open input file, path: data.txt, system error: no such file or directory
+func fileError(proc string, message string, path string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &RacketError{Kind: ERR_FILESYSTEM, Proc: proc, Message: message,
+		Fields: []ErrorField{{"path", path}, {"system error", err.Error()}}}
+}
+
+func openInputFile(proc string, arg interface{}, p Params) (*inputPort, error) {
+	path, err := pathArg(proc, arg)
+	if err != nil {
+		return nil, err
+	}
+	full, err := p.files.resolve(proc, path, currentDirectory(p), false)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, fileError(proc, "cannot open input file", path, err)
+	}
+	return newInputPort(path, f), nil
+}
+
+// #:exists is 'error by default like racket, 'append, 'truncate and 'replace overwrite existing files
+func openOutputFile(proc string, arg interface{}, keywords map[string]interface{}, p Params) (*outputPort, error) {
+	path, err := pathArg(proc, arg)
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	switch exists := keywords["exists"]; exists {
+	case nil, symbol("error"):
+		flags |= os.O_EXCL
+	case symbol("append"):
+		flags |= os.O_APPEND
+	case symbol("truncate"), symbol("replace"), symbol("truncate/replace"):
+		flags |= os.O_TRUNC
+	default:
+		return nil, contractError(proc, "(or/c 'error 'append 'truncate 'replace 'truncate/replace)", exists)
+	}
+	full, err := p.files.resolve(proc, path, currentDirectory(p), true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(full, flags, 0o666)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, &RacketError{Kind: ERR_FILESYSTEM, Proc: proc, Message: "file exists", Fields: []ErrorField{{"path", path}}}
+	} else if err != nil {
+		return nil, fileError(proc, "cannot open output file", path, err)
+	}
+	return &outputPort{name: path, w: f, closer: f}, nil
+}
+
+// the result of a procedure using a port, the port is closed even when it fails
+func withPort(port interface{ close() error }, call func() (interface{}, TypeEnum, error)) (interface{}, TypeEnum, error) {
+	res, t, err := call()
+	if closeErr := port.close(); err == nil && closeErr != nil {
+		return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "close-port", "error closing stream port\n  system error: %v", closeErr)
+	}
+	return res, t, err
+}
+
+func init() {
+	defineBuiltin("open-input-file", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("open-input-file", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := openInputFile("open-input-file", args[0], p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return port, TYPE_PORT, nil
+	})
+	defineKeywordBuiltin("open-output-file", []string{"exists"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("open-output-file", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := openOutputFile("open-output-file", args[0], keywords, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return port, TYPE_PORT, nil
+	})
+	defineBuiltin("call-with-input-file", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (call-with-input-file path proc) calls proc with the port of the file
+		if err := checkArity("call-with-input-file", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := openInputFile("call-with-input-file", args[0], p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return withPort(port, func() (interface{}, TypeEnum, error) {
+			return applyProcedure(args[1], []interface{}{port}, p)
+		})
+	})
+	defineKeywordBuiltin("with-output-to-file", []string{"exists"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (with-output-to-file path thunk #:exists 'replace) sends what the thunk prints to the file
+		if err := checkArity("with-output-to-file", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := openOutputFile("with-output-to-file", args[0], keywords, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return withPort(port, func() (interface{}, TypeEnum, error) {
+			return applyProcedure(args[1], nil, p.parameterize(outputPortParameter, port))
+		})
+	})
+	defineBuiltin("file-exists?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("file-exists?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		path, err := pathArg("file-exists?", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		full, err := p.files.resolve("file-exists?", path, currentDirectory(p), false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		info, err := os.Stat(full)
+		return err == nil && !info.IsDir(), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("directory-exists?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("directory-exists?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		path, err := pathArg("directory-exists?", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		full, err := p.files.resolve("directory-exists?", path, currentDirectory(p), false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		info, err := os.Stat(full)
+		return err == nil && info.IsDir(), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("directory-list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (directory-list [path]) names of the files in the directory, sorted
+		if err := checkArity("directory-list", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		path := "."
+		if len(args) == 1 {
+			var err error
+			if path, err = pathArg("directory-list", args[0]); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+		}
+		full, err := p.files.resolve("directory-list", path, currentDirectory(p), false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		entries, err := os.ReadDir(full)
+		if err != nil {
+			return nil, TYPE_ERROR, fileError("directory-list", "could not open directory", path, err)
+		}
+		// os.ReadDir sorts the entries by name
+		names := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		return sliceToList(names), TYPE_LIST, nil
+	})
+}
@@ -0,0 +1,132 @@
+package minrkt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("1 2\n3\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	in := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true, Write: true}))
+	in.Eval("(define (sum-port port) (let ([x (read port)]) (if (eof-object? x) 0 (+ x (sum-port port)))))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(file-exists? \"data.txt\")", "#t"},
+		{"(file-exists? \"sub\")", "#f"},
+		{"(directory-exists? \"sub\")", "#t"},
+		{"(file-exists? \"missing.txt\")", "#f"},
+		{"(call-with-input-file \"data.txt\" sum-port)", "6"},
+		{"(read-line (open-input-file \"data.txt\"))", "\"1 2\""},
+		{"(with-output-to-file \"out.txt\" (lambda () (display \"hello\") 'done))", "'done"},
+		{"(call-with-input-file \"out.txt\" read-line)", "\"hello\""},
+		{"(with-output-to-file \"out.txt\" (lambda () (display \" again\")) #:exists 'append)", ""},
+		{"(call-with-input-file \"out.txt\" read-line)", "\"hello again\""},
+		{"(with-output-to-file \"out.txt\" (lambda () (display \"new\")) #:exists 'replace)", ""},
+		{"(call-with-input-file \"out.txt\" read-line)", "\"new\""},
+		{"(let ([out (open-output-file \"port.txt\")]) (write 'x out) (close-output-port out) (file-exists? \"port.txt\"))", "#t"},
+		{"(directory-list)", "'(\"data.txt\" \"out.txt\" \"port.txt\" \"sub\")"},
+		{"(directory-list \"sub\")", "'()"},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	readOnly := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true}))
+	errorCases := []struct {
+		in   *Interpreter
+		line string
+		want string
+	}{
+		{in, "(open-input-file \"missing.txt\")", "open-input-file: cannot open input file\n  path: missing.txt\n  system error: no such file or directory"},
+		{in, "(with-output-to-file \"out.txt\" (lambda () 1))", "with-output-to-file: file exists\n  path: out.txt"},
+		{in, "(with-output-to-file \"out.txt\" (lambda () 1) #:exists 'keep)", "with-output-to-file: contract violation\n  expected: (or/c 'error 'append 'truncate 'replace 'truncate/replace)\n  given: 'keep"},
+		{in, "(with-output-to-file \"out.txt\" (lambda () 1) #:mode 'text)", "application: procedure does not expect an argument with given keyword\n  procedure: with-output-to-file\n  given keyword: #:mode"},
+		{in, "(open-input-file \"../data.txt\")", "open-input-file: `read' access denied for ../data.txt"},
+		{in, "(file-exists? " + strconv.Quote(filepath.Join(filepath.Dir(dir), "x")) + ")", "file-exists?: `read' access denied for " + filepath.Join(filepath.Dir(dir), "x")},
+		{in, "(open-input-file 5)", "open-input-file: contract violation\n  expected: path-string?\n  given: 5"},
+		{readOnly, "(open-output-file \"new.txt\")", "open-output-file: `write' access denied for new.txt"},
+		{NewInterpreter(), "(file-exists? \"data.txt\")", "file-exists?: `read' access denied for data.txt"},
+	}
+	for _, c := range errorCases {
+		_, _, err := c.in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+	if v, _, err := in.Eval("(with-handlers ([exn:fail:filesystem? (lambda (e) 'denied)]) (open-input-file \"/\"))"); err != nil || v != symbol("denied") {
+		t.Error("expected file errors to be exn:fail:filesystem but got", v, err)
+	}
+}
+
+func TestFiles_Symlinks(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "data.txt"), []byte("data\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"escape":   outside,
+		"secret":   filepath.Join(outside, "secret.txt"),
+		"dangling": filepath.Join(outside, "created.txt"),
+		"inside":   filepath.Join(dir, "sub"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("symbolic links aren't supported:", err)
+		}
+	}
+	in := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true, Write: true}))
+	// links to the files inside Root can be followed
+	if v, _, err := in.Eval("(call-with-input-file \"inside/data.txt\" read-line)"); err != nil || v != "data" {
+		t.Error("expected to read the file through a link inside the root but got", v, err)
+	}
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(call-with-input-file \"escape/secret.txt\" read-line)", "call-with-input-file: `read' access denied for escape/secret.txt"},
+		{"(call-with-input-file \"secret\" read-line)", "call-with-input-file: `read' access denied for secret"},
+		{"(file-exists? \"escape/missing.txt\")", "file-exists?: `read' access denied for escape/missing.txt"},
+		{"(with-output-to-file \"escape/new.txt\" (lambda () (display 1)))", "with-output-to-file: `write' access denied for escape/new.txt"},
+		{"(with-output-to-file \"dangling\" (lambda () (display 1)))", "with-output-to-file: `write' access denied for dangling"},
+		{"(directory-list \"escape\")", "directory-list: `read' access denied for escape"},
+		{"(current-directory \"escape\")", "current-directory: `read' access denied for escape"},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+	for _, created := range []string{"new.txt", "created.txt"} {
+		if _, err := os.Stat(filepath.Join(outside, created)); err == nil {
+			t.Error("expected", created, "not to be created outside of the root")
+		}
+	}
+}
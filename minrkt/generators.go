@@ -0,0 +1,146 @@
+package minrkt
+
+import (
+	"runtime"
+)
+
+/*
+A generator runs its body in its own goroutine, only one of the caller and the
+generator runs at a time: calling the generator resumes the body until the next
+yield, whose values are the result of the call. Once the body returns, every call
+returns its result.
+
+A suspended generator which can't be called any more is stopped when it's
+garbage collected, its yield unwinds the body so that the goroutine ends.
+*/
+type generatorValue struct {
+	run *generatorRun
+}
+
+// state of a generator, referenced by its goroutine, which mustn't keep the generatorValue alive
+type generatorRun struct {
+	proc interface{}
+	// fresh, suspended, running or done
+	state string
+	// arguments of the call resuming the generator, they are the result of yield
+	resume chan []interface{}
+	// values of yield, and the result of the body at the end
+	results chan generatorResult
+	// bindings of parameterize of the call resuming the generator, set before the body runs
+	parameters *parameterization
+	// closed when the generator is garbage collected
+	stop  chan struct{}
+	final interface{}
+}
+
+type generatorResult struct {
+	value interface{}
+	err   error
+	done  bool
+}
+
+// (generator formals body ...)
+func evalGenerator(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) < 2 {
+		return nil, TYPE_ERROR, syntaxError("generator", "bad syntax")
+	}
+	params, err := formalsToParams("generator", e.operands[0])
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	fv, err := newFunction("generator", "", params, e.operands[1:], p)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	fv.line, fv.col = e.line, e.col
+	run := &generatorRun{proc: fv, state: "fresh", resume: make(chan []interface{}),
+		results: make(chan generatorResult), stop: make(chan struct{})}
+	g := &generatorValue{run: run}
+	runtime.SetFinalizer(g, func(g *generatorValue) { close(g.run.stop) })
+	return g, TYPE_PROCEDURE, nil
+}
+
+func applyGenerator(g *generatorValue, args []interface{}, p Params) (interface{}, TypeEnum, error) {
+	run := g.run
+	switch run.state {
+	case "running":
+		return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "generator", "cannot resume a running generator")
+	case "done":
+		return run.final, typeOf(run.final), nil
+	case "fresh":
+		run.state = "running"
+		run.parameters = p.parameters
+		// the variables and the continuation of the caller can hold the generator, e.g. (let ([g (generator ...)]) (g)),
+		// the body only needs the environment captured by its procedure and runs below a frame of its own
+		inner := p
+		inner.CallStack = make([]map[string]interface{}, 1)
+		inner.cont = &kont{}
+		inner.generator = run
+		inner.parameters = &parameterization{resumed: run}
+		go func() {
+			res, _, err := applyProcedure(run.proc, args, inner)
+			select {
+			case run.results <- generatorResult{value: res, err: err, done: true}:
+			case <-run.stop:
+			}
+		}()
+	case "suspended":
+		run.state = "running"
+		run.parameters = p.parameters
+		run.resume <- args
+	}
+	r := <-run.results
+	run.state = "suspended"
+	if r.done {
+		run.state, run.final = "done", r.value
+		if r.err != nil {
+			run.final = void
+		}
+	}
+	// g is used until the result is received
+	runtime.KeepAlive(g)
+	if r.err != nil {
+		return nil, TYPE_ERROR, r.err
+	}
+	return r.value, typeOf(r.value), nil
+}
+
+func init() {
+	defineBuiltin("yield", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (yield v ...) returns the values from the call of the generator
+		run := p.generator
+		if run == nil {
+			return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "yield", "must be called in the context of a generator")
+		}
+		yielded, _ := newValues(args)
+		run.results <- generatorResult{value: yielded}
+		select {
+		case resumed := <-run.resume:
+			if len(resumed) == 0 {
+				return void, TYPE_VOID, nil
+			}
+			v, t := newValues(resumed)
+			return v, t, nil
+		case <-run.stop:
+			// like a jump out of the generator, dynamic-wind post thunks are run, no frame is the target
+			return nil, TYPE_ERROR, &continuationJump{}
+		}
+	})
+	defineBuiltin("generator?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("generator?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*generatorValue)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("generator-state", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("generator-state", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		g, ok := args[0].(*generatorValue)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("generator-state", "generator?", args[0])
+		}
+		return symbol(g.run.state), TYPE_SYMBOL, nil
+	})
+}
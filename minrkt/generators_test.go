@@ -0,0 +1,92 @@
+package minrkt
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define g (generator () (yield 1) (yield 2) 'done))")
+	evalLine(p, "(define (yield-all l) (for-each yield l))")
+	evalLine(p, "(define from-list (generator (l) (yield-all l) 'end))")
+	evalLine(p, "(define echo (generator (x) (echo-loop x)))")
+	evalLine(p, "(define (echo-loop x) (echo-loop (yield x)))")
+	evalLine(p, "(define (naturals) (generator () (define (loop n) (yield n) (loop (+ n 1))) (loop 0)))")
+	evalLine(p, "(define nat (naturals))")
+	evalLine(p, "(define level (make-parameter 1))")
+	evalLine(p, "(define (yield-levels) (yield (level)) (yield-levels))")
+	evalLine(p, "(define levels (generator () (yield-levels)))")
+	evalLine(p, "(define scoped (generator () (parameterize ([level 2]) (yield (level))) (yield (level))))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(generator-state g)", "'fresh"},
+		{"(g)", "1"},
+		{"(generator-state g)", "'suspended"},
+		{"(g)", "2"},
+		{"(g)", "'done"},
+		{"(generator-state g)", "'done"},
+		{"(g)", "'done"},
+		// yield works in functions called by the generator
+		{"(from-list '(a b))", "'a"},
+		{"(from-list)", "'b"},
+		{"(from-list)", "'end"},
+		// the arguments of the call are the result of yield
+		{"(echo 1)", "1"},
+		{"(echo 2)", "2"},
+		{"(list (nat) (nat) (nat))", "'(0 1 2)"},
+		// the body sees the parameterization of the call resuming it, below its own parameterize
+		{"(parameterize ([level 50]) (levels))", "50"},
+		{"(levels)", "1"},
+		{"(parameterize ([level 7]) (levels))", "7"},
+		{"(parameterize ([level 9]) (scoped))", "2"},
+		{"(scoped)", "1"},
+		{"(generator? nat)", "#t"},
+		{"(procedure? nat)", "#t"},
+		{"(generator? car)", "#f"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	if _, _, err := evalLine(p, "(yield 1)"); err == nil || err.Error() != "yield: must be called in the context of a generator" {
+		t.Error("expected a yield error but got", err)
+	}
+	evalLine(p, "(define self (generator () (self)))")
+	if _, _, err := evalLine(p, "(self)"); err == nil || err.Error() != "generator: cannot resume a running generator" {
+		t.Error("expected a running generator error but got", err)
+	}
+	evalLine(p, "(define bad (generator () (car 1)))")
+	if _, _, err := evalLine(p, "(bad)"); err == nil {
+		t.Error("expected evaluation error doesn't show up for (bad)")
+	}
+}
+
+func TestGenerators_Collected(t *testing.T) {
+	p := newTestParams()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		evalLine(p, "((generator () (yield 1) (yield 2)))")
+		// generators bound in the frame of the first call
+		evalLine(p, "(let ([g (generator () (yield 1) (yield 2))]) (g))")
+		evalLine(p, "(define (use) (define g (generator () (yield 1) (yield 2))) (list (g) (let ([h g]) (h))))")
+		evalLine(p, "(use)")
+	}
+	evalLine(p, "(define g #f)")
+	// suspended generators which can't be called any more stop their goroutine
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Error("expected", before, "goroutines after the generators are collected but got", n)
+	}
+}
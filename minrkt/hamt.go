@@ -0,0 +1,168 @@
+package minrkt
+
+import (
+	"math/bits"
+)
+
+/*
+Persistent hash array mapped trie storing the entries of hash tables. Each node
+uses 5 bits of the hash of the key to choose its child, the nodes are never
+modified: set and remove copy the nodes on the path to the key and share the
+others with the previous trie.
+*/
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+type hashEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+type hamtNode struct {
+	// bit i is set when there is a child for the 5 bits i
+	bitmap uint32
+	// *hamtNode or *hamtLeaf, in the order of their bits
+	children []interface{}
+}
+
+// keys with the same hash, there are several entries only when hashes collide
+type hamtLeaf struct {
+	hash    uint64
+	entries []hashEntry
+}
+
+var emptyHamt = &hamtNode{}
+
+// position of the child for the bits of hash at shift, and whether it exists
+func (n *hamtNode) child(hash uint64, shift uint) (uint32, int, bool) {
+	bit := uint32(1) << ((hash >> shift) & hamtMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1)), n.bitmap&bit != 0
+}
+
+func (n *hamtNode) get(hash uint64, key interface{}, same func(a, b interface{}) bool) (interface{}, bool) {
+	for shift := uint(0); ; shift += hamtBits {
+		_, i, ok := n.child(hash, shift)
+		if !ok {
+			return nil, false
+		}
+		switch c := n.children[i].(type) {
+		case *hamtNode:
+			n = c
+		case *hamtLeaf:
+			if c.hash == hash {
+				for _, e := range c.entries {
+					if same(e.key, key) {
+						return e.value, true
+					}
+				}
+			}
+			return nil, false
+		}
+	}
+}
+
+// new trie where key is bound to value, added is false when the key was already there
+func (n *hamtNode) set(hash uint64, shift uint, key, value interface{}, same func(a, b interface{}) bool) (*hamtNode, bool) {
+	bit, i, ok := n.child(hash, shift)
+	if !ok {
+		children := make([]interface{}, len(n.children)+1)
+		copy(children, n.children[:i])
+		children[i] = &hamtLeaf{hash: hash, entries: []hashEntry{{key, value}}}
+		copy(children[i+1:], n.children[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, children: children}, true
+	}
+	var newChild interface{}
+	added := true
+	switch c := n.children[i].(type) {
+	case *hamtNode:
+		newChild, added = c.set(hash, shift+hamtBits, key, value, same)
+	case *hamtLeaf:
+		if c.hash == hash {
+			entries := append([]hashEntry{}, c.entries...)
+			for j, e := range entries {
+				if same(e.key, key) {
+					entries[j].value = value
+					added = false
+					break
+				}
+			}
+			if added {
+				entries = append(entries, hashEntry{key, value})
+			}
+			newChild = &hamtLeaf{hash: hash, entries: entries}
+		} else {
+			// the leaf and the key go in a new node using the next bits of their hashes
+			bit, _, _ := emptyHamt.child(c.hash, shift+hamtBits)
+			sub := &hamtNode{bitmap: bit, children: []interface{}{c}}
+			newChild, _ = sub.set(hash, shift+hamtBits, key, value, same)
+		}
+	}
+	children := append([]interface{}{}, n.children...)
+	children[i] = newChild
+	return &hamtNode{bitmap: n.bitmap, children: children}, added
+}
+
+// new trie without the key, removed is false when the key wasn't there
+func (n *hamtNode) remove(hash uint64, shift uint, key interface{}, same func(a, b interface{}) bool) (*hamtNode, bool) {
+	bit, i, ok := n.child(hash, shift)
+	if !ok {
+		return n, false
+	}
+	var newChild interface{}
+	switch c := n.children[i].(type) {
+	case *hamtNode:
+		sub, removed := c.remove(hash, shift+hamtBits, key, same)
+		if !removed {
+			return n, false
+		}
+		if len(sub.children) == 1 {
+			// a single leaf moves up
+			if leaf, ok := sub.children[0].(*hamtLeaf); ok {
+				newChild = leaf
+				break
+			}
+		}
+		if len(sub.children) > 0 {
+			newChild = sub
+		}
+	case *hamtLeaf:
+		j := -1
+		if c.hash == hash {
+			for k, e := range c.entries {
+				if same(e.key, key) {
+					j = k
+					break
+				}
+			}
+		}
+		if j < 0 {
+			return n, false
+		}
+		if len(c.entries) > 1 {
+			entries := append(append([]hashEntry{}, c.entries[:j]...), c.entries[j+1:]...)
+			newChild = &hamtLeaf{hash: hash, entries: entries}
+		}
+	}
+	if newChild == nil {
+		children := append(append([]interface{}{}, n.children[:i]...), n.children[i+1:]...)
+		return &hamtNode{bitmap: n.bitmap &^ bit, children: children}, true
+	}
+	children := append([]interface{}{}, n.children...)
+	children[i] = newChild
+	return &hamtNode{bitmap: n.bitmap, children: children}, true
+}
+
+// entries in the order of their hashes
+func (n *hamtNode) entries(result []hashEntry) []hashEntry {
+	for _, child := range n.children {
+		switch c := child.(type) {
+		case *hamtNode:
+			result = c.entries(result)
+		case *hamtLeaf:
+			result = append(result, c.entries...)
+		}
+	}
+	return result
+}
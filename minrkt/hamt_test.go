@@ -0,0 +1,63 @@
+package minrkt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHamt(t *testing.T) {
+	same := func(a, b interface{}) bool { return a == b }
+	// the hash of the keys is their value modulo 7, so that they collide
+	hashOf := func(k int) uint64 { return uint64(k % 7) }
+	root := emptyHamt
+	for i := 0; i < 100; i++ {
+		var added bool
+		if root, added = root.set(hashOf(i), 0, i, fmt.Sprint(i), same); !added {
+			t.Error("expected key", i, "to be added")
+		}
+	}
+	old := root
+	root, added := root.set(hashOf(5), 0, 5, "five", same)
+	if added {
+		t.Error("expected key 5 to be replaced")
+	}
+	if v, ok := root.get(hashOf(5), 5, same); !ok || v != "five" {
+		t.Error("expected five but got", v)
+	}
+	// the previous trie isn't modified
+	if v, ok := old.get(hashOf(5), 5, same); !ok || v != "5" {
+		t.Error("expected 5 in the previous trie but got", v)
+	}
+	for i := 0; i < 100; i += 2 {
+		var removed bool
+		if root, removed = root.remove(hashOf(i), 0, i, same); !removed {
+			t.Error("expected key", i, "to be removed")
+		}
+	}
+	if _, removed := root.remove(hashOf(2), 0, 2, same); removed {
+		t.Error("expected key 2 to be already removed")
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := root.get(hashOf(i), i, same); ok != (i%2 == 1) {
+			t.Error("expected key", i, "in the trie:", i%2 == 1)
+		}
+	}
+	if n := len(root.entries(nil)); n != 50 {
+		t.Error("expected 50 entries but got", n)
+	}
+
+	// distinct hashes sharing their first bits
+	root = emptyHamt
+	for i := uint64(0); i < 64; i++ {
+		root, _ = root.set(i<<40|1, 0, i, i, same)
+	}
+	for i := uint64(0); i < 64; i++ {
+		if v, ok := root.get(i<<40|1, i, same); !ok || v != i {
+			t.Error("expected", i, "but got", v)
+		}
+		root, _ = root.remove(i<<40|1, 0, i, same)
+	}
+	if len(root.children) != 0 {
+		t.Error("expected an empty trie but got", root.entries(nil))
+	}
+}
@@ -0,0 +1,450 @@
+package minrkt
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+/*
+Hash table, keys are compared with equal? by hash and make-hash, or with eq? by
+hasheq and make-hasheq. Immutable tables are never modified: hash-set returns a
+new table sharing most of its trie with the previous one.
+*/
+type hashTable struct {
+	// equal, eqv or eq
+	kind    string
+	mutable bool
+	root    *hamtNode
+	count   int
+}
+
+func newHashTable(kind string, mutable bool) *hashTable {
+	return &hashTable{kind: kind, mutable: mutable, root: emptyHamt}
+}
+
+func (h *hashTable) sameKey(a, b interface{}) bool {
+	if h.kind == "equal" {
+		return isEqual(a, b)
+	}
+	return isEq(a, b)
+}
+
+func (h *hashTable) hashKey(key interface{}) uint64 {
+	hasher := fnv.New64a()
+	budget := maxHashedValues
+	writeHash(hasher, key, h.kind == "equal", &budget)
+	return hasher.Sum64()
+}
+
+func (h *hashTable) ref(key interface{}) (interface{}, bool) {
+	return h.root.get(h.hashKey(key), key, h.sameKey)
+}
+
+// the table with key bound to value, h isn't modified
+func (h *hashTable) with(key, value interface{}) *hashTable {
+	root, added := h.root.set(h.hashKey(key), 0, key, value, h.sameKey)
+	t := *h
+	t.root = root
+	if added {
+		t.count++
+	}
+	return &t
+}
+
+func (h *hashTable) without(key interface{}) *hashTable {
+	root, removed := h.root.remove(h.hashKey(key), 0, key, h.sameKey)
+	t := *h
+	t.root = root
+	if removed {
+		t.count--
+	}
+	return &t
+}
+
+// at most this many values of a nested key are hashed, keys differing after them collide
+const maxHashedValues = 64
+
+// the hash of equal keys is the same, eq? keys which aren't numbers or symbols are hashed by address
+func writeHash(w interface{ Write([]byte) (int, error) }, v interface{}, equal bool, budget *int) {
+	if *budget <= 0 {
+		return
+	}
+	*budget--
+	var buf [8]byte
+	switch x := v.(type) {
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(x))
+		w.Write(append([]byte{'i'}, buf[:]...))
+	case float64:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(x))
+		w.Write(append([]byte{'n'}, buf[:]...))
+	case *big.Int, *big.Rat:
+		w.Write([]byte("b" + formatNumber(x)))
+	case bool:
+		if x {
+			w.Write([]byte{'t'})
+		} else {
+			w.Write([]byte{'f'})
+		}
+	case string:
+		w.Write([]byte("s" + x + "\x00"))
+	case symbol:
+		w.Write([]byte("y" + string(x) + "\x00"))
+	case char:
+		w.Write([]byte("c" + string(rune(x))))
+	case *regexpValue:
+		if !equal {
+			writeAddress(w, x)
+			return
+		}
+		w.Write([]byte("x" + formatRegexp(x) + "\x00"))
+	case keyword:
+		w.Write([]byte("k" + string(x) + "\x00"))
+	case emptyList:
+		w.Write([]byte{'e'})
+	case *pair:
+		if !equal {
+			writeAddress(w, x)
+			return
+		}
+		w.Write([]byte{'p'})
+		writeHash(w, x.car, equal, budget)
+		writeHash(w, x.cdr, equal, budget)
+	case *vector:
+		if !equal {
+			writeAddress(w, x)
+			return
+		}
+		w.Write([]byte{'v'})
+		for _, element := range x.elements {
+			writeHash(w, element, equal, budget)
+		}
+	case *structValue:
+		if !equal || !x.stype.transparent {
+			writeAddress(w, x)
+			return
+		}
+		w.Write([]byte("r" + x.stype.name + "\x00"))
+		for _, field := range x.fields {
+			writeHash(w, field, equal, budget)
+		}
+	case *hashTable:
+		// the order of the entries isn't the same for equal tables
+		if !equal {
+			writeAddress(w, x)
+			return
+		}
+		binary.LittleEndian.PutUint64(buf[:], uint64(x.count))
+		w.Write(append([]byte{'h'}, buf[:]...))
+	default:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+			writeAddress(w, v)
+		}
+	}
+}
+
+func writeAddress(w interface{ Write([]byte) (int, error) }, v interface{}) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(reflect.ValueOf(v).Pointer()))
+	w.Write(append([]byte{'a'}, buf[:]...))
+}
+
+// hashes are equal when they have the same kind and equal values for the same keys
+func hashTablesEqual(a, b *hashTable, seen map[equalVisit]bool) bool {
+	if a.kind != b.kind || a.mutable != b.mutable || a.count != b.count {
+		return false
+	}
+	for _, e := range a.root.entries(nil) {
+		if v, ok := b.ref(e.key); !ok || !equalWith(e.value, v, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// #hash((a . 1) (b . 2))
+func (pr *printer) formatHash(h *hashTable) string {
+	var sb strings.Builder
+	sb.WriteString("#hash")
+	if h.kind != "equal" {
+		sb.WriteString(h.kind)
+	}
+	sb.WriteString("(")
+	for i, e := range h.root.entries(nil) {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString("(" + pr.format(e.key) + " . " + pr.format(e.value) + ")")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// the entries of a hash used as a sequence are two values, the key and the value
+type hashIterator struct {
+	entries []hashEntry
+	i       int
+}
+
+func (it *hashIterator) next(p Params) (interface{}, bool, error) {
+	if it.i >= len(it.entries) {
+		return nil, false, nil
+	}
+	it.i++
+	return it.entries[it.i-1], true, nil
+}
+
+func hashArg(proc string, arg interface{}) (*hashTable, error) {
+	h, ok := arg.(*hashTable)
+	if !ok {
+		return nil, contractError(proc, "hash?", arg)
+	}
+	return h, nil
+}
+
+// hash-set! and hash-remove! need a mutable table, hash-set and hash-remove an immutable one
+func hashArgMutable(proc string, arg interface{}, mutable bool) (*hashTable, error) {
+	h, ok := arg.(*hashTable)
+	if mutable && (!ok || !h.mutable) {
+		return nil, contractError(proc, "(and/c hash? (not/c immutable?))", arg)
+	}
+	if !mutable && (!ok || h.mutable) {
+		return nil, contractError(proc, "(and/c hash? immutable?)", arg)
+	}
+	return h, nil
+}
+
+// value of the key, or the result of failure which is a thunk or a value
+func hashRef(proc string, h *hashTable, key interface{}, failure []interface{}, p Params) (interface{}, error) {
+	if v, ok := h.ref(key); ok {
+		return v, nil
+	}
+	if len(failure) == 0 {
+		return nil, &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "no value found for key",
+			Fields: []ErrorField{{"key", FormatValue(key)}}}
+	}
+	if isProcedure(failure[0]) {
+		v, _, err := applyProcedure(failure[0], nil, p)
+		return v, err
+	}
+	return failure[0], nil
+}
+
+func init() {
+	// (make-hash) or (make-hash '((a . 1))) from an association list
+	makeHash := func(name, kind string) {
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 0, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			h := newHashTable(kind, true)
+			if len(args) == 1 {
+				assocs, ok := listToSlice(args[0])
+				if !ok {
+					return nil, TYPE_ERROR, contractError(name, "(listof pair?)", args[0])
+				}
+				for _, a := range assocs {
+					entry, ok := a.(*pair)
+					if !ok {
+						return nil, TYPE_ERROR, contractError(name, "(listof pair?)", args[0])
+					}
+					h = h.with(entry.car, entry.cdr)
+				}
+			}
+			return h, TYPE_HASH, nil
+		})
+	}
+	makeHash("make-hash", "equal")
+	makeHash("make-hasheqv", "eqv")
+	makeHash("make-hasheq", "eq")
+	// (hash k v ...) is immutable
+	immutableHash := func(name, kind string) {
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if len(args)%2 != 0 {
+				return nil, TYPE_ERROR, newRacketError(ERR_CONTRACT, name, "key does not have a value (i.e., an odd number of arguments were provided)")
+			}
+			h := newHashTable(kind, false)
+			for i := 0; i < len(args); i += 2 {
+				h = h.with(args[i], args[i+1])
+			}
+			return h, TYPE_HASH, nil
+		})
+	}
+	immutableHash("hash", "equal")
+	immutableHash("hasheqv", "eqv")
+	immutableHash("hasheq", "eq")
+	defineBuiltin("hash?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*hashTable)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("hash-ref", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (hash-ref h key [failure])
+		if err := checkArity("hash-ref", args, 2, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArg("hash-ref", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := hashRef("hash-ref", h, args[1], args[2:], p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return v, typeOf(v), nil
+	})
+	defineBuiltin("hash-has-key?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-has-key?", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArg("hash-has-key?", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := h.ref(args[1])
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("hash-set!", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-set!", args, 3, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArgMutable("hash-set!", args[0], true)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		*h = *h.with(args[1], args[2])
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("hash-set", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-set", args, 3, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArgMutable("hash-set", args[0], false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return h.with(args[1], args[2]), TYPE_HASH, nil
+	})
+	defineBuiltin("hash-remove!", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-remove!", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArgMutable("hash-remove!", args[0], true)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		*h = *h.without(args[1])
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("hash-remove", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-remove", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArgMutable("hash-remove", args[0], false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return h.without(args[1]), TYPE_HASH, nil
+	})
+	// (hash-update h key updater [failure]) sets key to (updater (hash-ref h key failure))
+	hashUpdate := func(name string, mutable bool) {
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 3, 4); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			h, err := hashArgMutable(name, args[0], mutable)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			old, err := hashRef(name, h, args[1], args[3:], p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			updated, _, err := applySingle(args[2], []interface{}{old}, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			if mutable {
+				*h = *h.with(args[1], updated)
+				return void, TYPE_VOID, nil
+			}
+			return h.with(args[1], updated), TYPE_HASH, nil
+		})
+	}
+	hashUpdate("hash-update!", true)
+	hashUpdate("hash-update", false)
+	defineBuiltin("hash-count", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("hash-count", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		h, err := hashArg("hash-count", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return int64(h.count), TYPE_FLOAT64, nil
+	})
+	// hash-keys, hash-values and hash->list
+	hashList := func(name string, element func(e hashEntry) interface{}) {
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			h, err := hashArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			var elements []interface{}
+			for _, e := range h.root.entries(nil) {
+				elements = append(elements, element(e))
+			}
+			return sliceToList(elements), TYPE_LIST, nil
+		})
+	}
+	hashList("hash-keys", func(e hashEntry) interface{} { return e.key })
+	hashList("hash-values", func(e hashEntry) interface{} { return e.value })
+	hashList("hash->list", func(e hashEntry) interface{} { return &pair{e.key, e.value} })
+	hashList("in-hash-keys", func(e hashEntry) interface{} { return e.key })
+	hashList("in-hash-values", func(e hashEntry) interface{} { return e.value })
+	hashList("in-hash-pairs", func(e hashEntry) interface{} { return &pair{e.key, e.value} })
+	// (hash-map h proc) and (hash-for-each h proc) call (proc key value)
+	hashMap := func(name string) {
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 2, 2); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			h, err := hashArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			var results []interface{}
+			for _, e := range h.root.entries(nil) {
+				got, _, err := applySingle(args[1], []interface{}{e.key, e.value}, p)
+				if err != nil {
+					return nil, TYPE_ERROR, err
+				}
+				results = append(results, got)
+			}
+			if name == "hash-for-each" {
+				return void, TYPE_VOID, nil
+			}
+			return sliceToList(results), TYPE_LIST, nil
+		})
+	}
+	hashMap("hash-map")
+	hashMap("hash-for-each")
+	defineBuiltin("in-hash", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("in-hash", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if _, err := hashArg("in-hash", args[0]); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return args[0], TYPE_HASH, nil
+	})
+}
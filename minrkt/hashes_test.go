@@ -0,0 +1,86 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHashes(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define h (make-hash))")
+	evalLine(p, "(define im (hash 'a 1 \"b\" 2 '(c d) 3))")
+	evalLine(p, "(define eqh (make-hasheq))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(begin (hash-set! h 'x 1) (hash-set! h '(1 2) 'list) (hash-count h))", "2"},
+		{"(hash-ref h 'x)", "1"},
+		// keys are compared with equal?
+		{"(hash-ref h (list 1 2))", "'list"},
+		{"(hash-ref im \"b\")", "2"},
+		{"(hash-ref im '(c d))", "3"},
+		{"(hash-ref im 'z 0)", "0"},
+		{"(hash-ref im 'z (lambda () 'missing))", "'missing"},
+		{"(hash-has-key? im 'a)", "#t"},
+		{"(hash-has-key? im 'z)", "#f"},
+		{"(hash-count (hash-set im 'z 26))", "4"},
+		// hash-set doesn't change the table
+		{"(hash-count im)", "3"},
+		{"(hash-count (hash-remove im 'a))", "2"},
+		{"(hash-ref (hash-update im 'a add-one) 'a)", "2"},
+		{"(hash-ref (hash-update im 'n add-one 10) 'n)", "11"},
+		{"(begin (hash-update! h 'x add-one) (hash-ref h 'x))", "2"},
+		{"(begin (hash-remove! h 'x) (hash-has-key? h 'x))", "#f"},
+		{"(sort (hash-values (hash 'a 1 'b 2 'c 3)) <)", "'(1 2 3)"},
+		{"(length (hash-keys im))", "3"},
+		{"(hash->list (hash 'a 1))", "'((a . 1))"},
+		{"(hash 'a 1)", "'#hash((a . 1))"},
+		{"(hasheq 'a 1)", "'#hasheq((a . 1))"},
+		{"(make-hash '((k . v)))", "'#hash((k . v))"},
+		{"(immutable? im)", "#t"},
+		{"(immutable? h)", "#f"},
+		{"(hash? im)", "#t"},
+		// eq? tables compare lists by identity
+		{"(begin (hash-set! eqh '(1) 1) (hash-ref eqh '(1) #f))", "#f"},
+		{"(begin (hash-set! eqh 'k 1) (hash-ref eqh 'k #f))", "1"},
+		{"(sort (for/list ([(k v) (hash 'a 1 'b 2)]) v) <)", "'(1 2)"},
+		{"(sort (for/list ([(k v) (in-hash (hash 'a 1 'b 2))]) v) <)", "'(1 2)"},
+		{"(sort (for/list ([v (in-hash-values (hash 'a 1 'b 2))]) v) <)", "'(1 2)"},
+		{"(sort (hash-map (hash 'a 1 'b 2) (lambda (k v) v)) <)", "'(1 2)"},
+	}
+	evalLine(p, "(define (add-one x) (+ x 1))")
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	a, _, _ := evalLine(p, "(hash 'a '(1) 'b 2)")
+	b, _, _ := evalLine(p, "(hash-set (hash 'b 2) 'a (list 1))")
+	if !isEqual(a, b) {
+		t.Error("expected hashes with equal entries to be equal")
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(hash-ref im 'z)", "hash-ref: no value found for key\n  key: 'z"},
+		{"(hash-set! (hash 'a 1) 'a 1)", "hash-set!: contract violation\n  expected: (and/c hash? (not/c immutable?))\n  given: '#hash((a . 1))"},
+		{"(hash-set h 'a 1)", "hash-set: contract violation\n  expected: (and/c hash? immutable?)\n  given: '#hash(((1 2) . list))"},
+		{"(hash 'a)", "hash: key does not have a value (i.e., an odd number of arguments were provided)"},
+		{"(for ([x (hash 'a 1)]) x)", "for: result arity mismatch;\n expected number of values not received\n  expected: 1\n  received: 2"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package minrkt
+
+import (
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// Interpreter evaluates expressions with its own global definitions, for programs embedding mini-racket
+type Interpreter struct {
+	params Params
+}
+
+// Option configures an Interpreter created by NewInterpreter
+type Option func(*Interpreter)
+
+// WithOutput sends what programs print with display, write and printf to w instead of the standard output
+func WithOutput(w io.Writer) Option {
+	return func(in *Interpreter) {
+		in.SetOutput(w)
+	}
+}
+
+// WithInput makes r the current input port of read-line, read-char and read
+func WithInput(r io.Reader) Option {
+	return func(in *Interpreter) {
+		in.params.cell(inputPortParameter).value = newInputPort("stdin", r)
+	}
+}
+
+// WithFilePolicy lets programs access the files allowed by policy, by default they can't open any file
+func WithFilePolicy(policy FilePolicy) Option {
+	return func(in *Interpreter) {
+		if policy.Root != "" {
+			if root, err := filepath.Abs(policy.Root); err == nil {
+				policy.Root = root
+			}
+			in.params.cell(currentDirectoryParameter).value = policy.Root
+		}
+		in.params.files = &policy
+	}
+}
+
+// WithoutPrelude gives programs a bare environment where only the builtins written in go are defined
+func WithoutPrelude() Option {
+	return func(in *Interpreter) {
+		in.params.noPrelude = true
+	}
+}
+
+// WithRandomSeed seeds the generator of random, so that the programs give the same numbers at each run
+func WithRandomSeed(seed int64) Option {
+	return func(in *Interpreter) {
+		in.SetRandomSeed(seed)
+	}
+}
+
+// NewInterpreter creates an interpreter whose generator of random is seeded with the time, unless WithRandomSeed is given
+func NewInterpreter(options ...Option) *Interpreter {
+	in := &Interpreter{params: Params{MapIdentifier: make(map[string]interface{}), CallStack: make([]map[string]interface{}, 1),
+		files: &FilePolicy{}, modules: newModuleRegistry()}}
+	// the builtin parameters of a session have their own values, changing them doesn't change the ones of other sessions
+	in.params = in.params.parameterize(outputPortParameter, stdoutPort).parameterize(inputPortParameter, stdinPort).
+		parameterize(currentDirectoryParameter, workingDirectory()).parameterize(jsonNullParameter, symbol("null")).
+		parameterize(randomParameter, newRandomGenerator(time.Now().UnixNano()))
+	for _, option := range options {
+		option(in)
+	}
+	return in
+}
+
+// SetOutput changes the current output port of the programs evaluated afterwards
+func (in *Interpreter) SetOutput(w io.Writer) {
+	in.params.cell(outputPortParameter).value = &outputPort{name: "stdout", w: w}
+}
+
+// SetRandomSeed replaces the current generator of random by a new one seeded with seed
+func (in *Interpreter) SetRandomSeed(seed int64) {
+	in.params.cell(randomParameter).value = newRandomGenerator(seed)
+}
+
+// Eval tokenizes, parses and evaluates one expression
+func (in *Interpreter) Eval(line string) (interface{}, TypeEnum, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	root, err := Parse(tokens)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	return in.EvalExp(root)
+}
+
+// EvalExp evaluates an expression returned by Parse
+func (in *Interpreter) EvalExp(root Exp) (interface{}, TypeEnum, error) {
+	return root.Eval(in.params)
+}
@@ -0,0 +1,323 @@
+package minrkt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+The json library module, bound by (require json). JSON values are jsexprs:
+objects are immutable hasheq tables with symbol keys, arrays are lists,
+strings, booleans and numbers are themselves, integers being exact, and null
+is the value of (json-null), 'null unless it is changed or a #:null keyword
+argument is given.
+*/
+
+// the value of null when no #:null keyword argument is given
+var jsonNullParameter = newParameter("json-null", symbol("null"), nil)
+
+// the #:null keyword argument or (json-null)
+func jsonNull(keywords map[string]interface{}, p Params) interface{} {
+	if null, ok := keywords["null"]; ok {
+		return null
+	}
+	return p.parameterValue(jsonNullParameter)
+}
+
+// FromJSON converts a value decoded by encoding/json into a jsexpr: maps become hashes with symbol keys,
+// slices lists and nil 'null. json.Number integers become exact numbers, float64 are inexact.
+func FromJSON(v interface{}) (interface{}, error) {
+	return fromJSON(v, symbol("null"))
+}
+
+func fromJSON(v interface{}, null interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return null, nil
+	case bool, string, float64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case json.Number:
+		if !strings.ContainsAny(string(x), ".eE") {
+			if n, ok := new(big.Int).SetString(string(x), 10); ok {
+				return normalizeInt(n), nil
+			}
+		}
+		return x.Float64()
+	case []interface{}:
+		elements := make([]interface{}, len(x))
+		for i, element := range x {
+			var err error
+			if elements[i], err = fromJSON(element, null); err != nil {
+				return nil, err
+			}
+		}
+		return sliceToList(elements), nil
+	case map[string]interface{}:
+		h := newHashTable("eq", false)
+		for key, value := range x {
+			converted, err := fromJSON(value, null)
+			if err != nil {
+				return nil, err
+			}
+			h = h.with(symbol(key), converted)
+		}
+		return h, nil
+	}
+	return nil, fmt.Errorf("FromJSON: unsupported type %T", v)
+}
+
+// ToJSON converts a jsexpr into a value encoding/json can marshal: hashes become maps, lists slices
+// and 'null nil.
+func ToJSON(v interface{}) (interface{}, error) {
+	return toJSON("ToJSON", v, symbol("null"))
+}
+
+func toJSON(proc string, v interface{}, null interface{}) (interface{}, error) {
+	if isEqual(v, null) {
+		return nil, nil
+	}
+	switch x := v.(type) {
+	case bool, string, int64, *big.Int:
+		return x, nil
+	case float64:
+		if isRational(x) {
+			return x, nil
+		}
+	case emptyList, *pair:
+		l, ok := listToSlice(x)
+		if !ok {
+			break
+		}
+		elements := make([]interface{}, len(l))
+		for i, element := range l {
+			var err error
+			if elements[i], err = toJSON(proc, element, null); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	case *hashTable:
+		object := make(map[string]interface{}, x.count)
+		for _, e := range x.root.entries(nil) {
+			key, ok := e.key.(symbol)
+			if !ok {
+				return nil, contractError(proc, "jsexpr?", v)
+			}
+			value, err := toJSON(proc, e.value, null)
+			if err != nil {
+				return nil, err
+			}
+			object[string(key)] = value
+		}
+		return object, nil
+	}
+	return nil, contractError(proc, "jsexpr?", v)
+}
+
+// the JSON text of a jsexpr, inexact integers keep their decimal point like racket's 1.0
+func writeJSON(sb *strings.Builder, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(x))
+	case int64, float64, *big.Int:
+		sb.WriteString(formatNumber(x))
+	case string:
+		sb.WriteString(jsonString(x))
+	case []interface{}:
+		sb.WriteByte('[')
+		for i, element := range x {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			writeJSON(sb, element)
+		}
+		sb.WriteByte(']')
+	case map[string]interface{}:
+		// the keys are sorted so that the text doesn't depend on the order of the hash
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		sb.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(jsonString(key))
+			sb.WriteByte(':')
+			writeJSON(sb, x[key])
+		}
+		sb.WriteByte('}')
+	}
+}
+
+// strings are written with their unicode characters, only quotes, backslashes and control characters are escaped
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func jsexprString(proc string, v interface{}, null interface{}) (string, error) {
+	converted, err := toJSON(proc, v, null)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	writeJSON(&sb, converted)
+	return sb.String(), nil
+}
+
+// decode the JSON text, which is a single value
+func parseJSON(proc string, text string, null interface{}) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err == io.EOF {
+		return eof, nil
+	} else if err != nil {
+		return nil, newRacketError(ERR_FAIL, proc, "bad input: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, newRacketError(ERR_FAIL, proc, "found garbage after JSON value")
+	}
+	return fromJSON(v, null)
+}
+
+/*
+Text of the next JSON value of the port, so that read-json doesn't read the
+input which follows, and false at the end of the input.
+*/
+func (port *inputPort) jsonText(proc string) (string, bool, error) {
+	var sb strings.Builder
+	depth := 0
+	inString, escaped := false, false
+	for {
+		r, _, err := port.r.ReadRune()
+		if err == io.EOF {
+			return sb.String(), sb.Len() > 0, nil
+		} else if err != nil {
+			return "", false, readError(proc, err)
+		}
+		if sb.Len() == 0 && unicode.IsSpace(r) {
+			continue
+		}
+		if !inString && depth == 0 && sb.Len() > 0 && (unicode.IsSpace(r) || strings.ContainsRune(",[]{}\"", r)) {
+			// the end of a number or of true, false and null
+			port.r.UnreadRune()
+			return sb.String(), true, nil
+		}
+		sb.WriteRune(r)
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+				if depth == 0 {
+					return sb.String(), true, nil
+				}
+			}
+		case r == '"':
+			inString = true
+		case r == '{' || r == '[':
+			depth++
+		case r == '}' || r == ']':
+			if depth--; depth <= 0 {
+				return sb.String(), true, nil
+			}
+		}
+	}
+}
+
+func init() {
+	defineLibraryBuiltin("json", "jsexpr?", []string{"null"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("jsexpr?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, err := toJSON("jsexpr?", args[0], jsonNull(keywords, p))
+		return err == nil, TYPE_BOOLEAN, nil
+	})
+	defineLibraryBuiltin("json", "jsexpr->string", []string{"null"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("jsexpr->string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := jsexprString("jsexpr->string", args[0], jsonNull(keywords, p))
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return s, TYPE_STRING, nil
+	})
+	defineLibraryBuiltin("json", "write-json", []string{"null"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (write-json x [out])
+		if err := checkArity("write-json", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := portArg("write-json", args, 1, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := jsexprString("write-json", args[0], jsonNull(keywords, p))
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if err := port.write("write-json", s); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return void, TYPE_VOID, nil
+	})
+	defineLibraryBuiltin("json", "string->jsexpr", []string{"null"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("string->jsexpr", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string->jsexpr", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := parseJSON("string->jsexpr", s, jsonNull(keywords, p))
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return v, typeOf(v), nil
+	})
+	defineLibraryBuiltin("json", "read-json", []string{"null"}, func(args []interface{}, keywords map[string]interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (read-json [in]) reads the next value of the port, eof at the end of the input
+		if err := checkArity("read-json", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := inputPortArg("read-json", args, 0, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		text, ok, err := port.jsonText("read-json")
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		} else if !ok {
+			return eof, TYPE_EOF, nil
+		}
+		v, err := parseJSON("read-json", text, jsonNull(keywords, p))
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return v, typeOf(v), nil
+	})
+	libraries["json"]["json-null"] = jsonNullParameter
+}
@@ -0,0 +1,109 @@
+package minrkt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	in := NewInterpreter()
+	if _, _, err := in.Eval("(jsexpr->string 1)"); err == nil {
+		t.Error("expected the json library to be bound only by (require json)")
+	}
+	in.Eval("(require json)")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(string->jsexpr \"{\\\"a\\\": [1, 2.5, \\\"x\\\", true, null]}\")", "'#hasheq((a . (1 2.5 \"x\" #t null)))"},
+		{"(hash-ref (string->jsexpr \"{\\\"n\\\": {\\\"m\\\": 1e2}}\") 'n)", "'#hasheq((m . 100.0))"},
+		{"(string->jsexpr \"  null \")", "'null"},
+		{"(string->jsexpr \"null\" #:null #f)", "#f"},
+		{"(string->jsexpr \"\")", "#<eof>"},
+		{"(string->jsexpr \"\\\"λ\\\\n\\\"\")", "\"λ\\n\""},
+		{"(jsexpr->string (hasheq 'b (list 1 2.0 #f) 'a \"<λ>\"))", "\"{\\\"a\\\":\\\"<λ>\\\",\\\"b\\\":[1,2.0,false]}\""},
+		{"(jsexpr->string '(null))", "\"[null]\""},
+		{"(jsexpr->string '())", "\"[]\""},
+		{"(jsexpr->string (hash))", "\"{}\""},
+		{"(jsexpr->string (list #f 'nothing) #:null 'nothing)", "\"[false,null]\""},
+		{"(jsexpr? (hash 'a '(1 \"b\")))", "#t"},
+		{"(jsexpr? (hash \"a\" 1))", "#f"},
+		{"(jsexpr? (vector 1))", "#f"},
+		{"(jsexpr? (/ 1 0.0))", "#f"},
+		{"(jsexpr? 1/2)", "#f"},
+		{"(string->jsexpr \"[18446744073709551616]\")", "'(18446744073709551616)"},
+		{"(jsexpr->string (expt 2 64))", "\"18446744073709551616\""},
+		{"(begin (json-null 'nil) (string->jsexpr \"[null]\"))", "'(nil)"},
+		{"(begin (json-null 'null) (with-output-to-string (lambda () (write-json (list 1 \"a\")))))", "\"[1,\\\"a\\\"]\""},
+		{"(let ([in (open-input-string \"{\\\"a\\\": \\\"}\\\"} 12 [3]\")]) (list (read-json in) (read-json in) (read-json in) (read-json in)))", "'(#hasheq((a . \"}\")) 12 (3) #<eof>)"},
+		{"(let ([in (open-input-string \"true\\nrest\")]) (read-json in) (read-line in))", "\"\""},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(string->jsexpr \"{\\\"a\\\" 1}\")", "string->jsexpr: bad input: invalid character '1' after object key"},
+		{"(string->jsexpr \"1 2\")", "string->jsexpr: found garbage after JSON value"},
+		{"(jsexpr->string (vector 1))", "jsexpr->string: contract violation\n  expected: jsexpr?\n  given: '#(1)"},
+		{"(write-json (hash 1 2))", "write-json: contract violation\n  expected: jsexpr?\n  given: '#hash((1 . 2))"},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
+
+func TestJSONConversions(t *testing.T) {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`{"name": "api", "ports": [80, 443], "tls": null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	v, err := FromJSON(decoded)
+	if err != nil {
+		t.Fatal("unexpected conversion error:", err)
+	}
+	h, ok := v.(*hashTable)
+	if !ok {
+		t.Fatal("expected a hash but got", FormatValue(v))
+	}
+	if got, _ := h.ref(symbol("ports")); FormatValue(got) != "'(80.0 443.0)" {
+		t.Error("expected the numbers decoded as float64 to be inexact but got", FormatValue(got))
+	}
+	if got, _ := h.ref(symbol("tls")); got != symbol("null") {
+		t.Error("expected null to be 'null but got", FormatValue(got))
+	}
+	if v, _ := FromJSON(json.Number("7")); v != int64(7) {
+		t.Error("expected json.Number integers to be exact but got", FormatValue(v))
+	}
+	if _, err := FromJSON(struct{}{}); err == nil {
+		t.Error("expected an error for a type encoding/json doesn't decode")
+	}
+
+	in := NewInterpreter()
+	value, _, _ := in.Eval("(hasheq 'ports (list 80 443) 'ratio 0.5 'tls 'null)")
+	converted, err := ToJSON(value)
+	if err != nil {
+		t.Fatal("unexpected conversion error:", err)
+	}
+	text, err := json.Marshal(converted)
+	if err != nil || string(text) != `{"ports":[80,443],"ratio":0.5,"tls":null}` {
+		t.Error("expected the converted value to be marshalled by encoding/json but got", string(text), err)
+	}
+	if _, err := ToJSON(symbol("x")); err == nil {
+		t.Error("expected an error for a value which isn't a jsexpr")
+	}
+}
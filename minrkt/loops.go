@@ -0,0 +1,393 @@
+package minrkt
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// iteration over the elements of a sequence by the for loops
+type iterator interface {
+	// ok is false once there is no element left
+	next(p Params) (val interface{}, ok bool, err error)
+}
+
+type streamIterator struct {
+	proc   string
+	stream interface{}
+}
+
+func (it *streamIterator) next(p Params) (interface{}, bool, error) {
+	if streamEmpty(it.stream) {
+		return nil, false, nil
+	}
+	first, rest, err := streamNext(it.proc, it.stream, p)
+	if err != nil {
+		return nil, false, err
+	}
+	it.stream = rest
+	return first, true, nil
+}
+
+// strings are iterated by code point
+type stringIterator struct {
+	runes []rune
+	i     int
+}
+
+func (it *stringIterator) next(p Params) (interface{}, bool, error) {
+	if it.i >= len(it.runes) {
+		return nil, false, nil
+	}
+	it.i++
+	return char(it.runes[it.i-1]), true, nil
+}
+
+// sequences are streams, strings, vectors, hashes and exact non negative integers n, which are the range 0 to n-1
+func newIterator(proc string, seq interface{}) (iterator, error) {
+	switch v := seq.(type) {
+	case int64:
+		if v >= 0 {
+			return &streamIterator{proc, rangeStream{int64(0), v, int64(1)}}, nil
+		}
+	case string:
+		return &stringIterator{runes: []rune(v)}, nil
+	case *vector:
+		return &vectorIterator{v: v}, nil
+	case *hashTable:
+		return &hashIterator{entries: v.root.entries(nil)}, nil
+	}
+	if isStream(seq) {
+		return &streamIterator{proc, seq}, nil
+	}
+	return nil, contractError(proc, "sequence?", seq)
+}
+
+// one clause of a for loop, [x seq], [(k v) hash] or a #:when, #:unless or #:break guard
+type forClause struct {
+	names []string
+	seq   Exp
+	// when, unless or break
+	guard    string
+	guardExp Exp
+}
+
+// clauses iterated in parallel, the guards are checked for each of their elements
+type forLevel struct {
+	clauses []forClause
+	guards  []forClause
+}
+
+/*
+Parse ([x seq] ... #:when guard [y seq] ...), every clause of for* is nested in
+the previous one. Clauses of for are iterated in parallel, until a guard: the clauses
+after a guard are nested in the previous ones.
+*/
+func parseForClauses(form string, exp Exp, nested bool) ([]forLevel, error) {
+	list, ok := exp.(*ExpOperator)
+	if !ok {
+		return nil, syntaxError(form, "expected a list of for clauses")
+	}
+	elements := listElements(list)
+	levels := []forLevel{{}}
+	for i := 0; i < len(elements); i++ {
+		last := &levels[len(levels)-1]
+		switch c := elements[i].(type) {
+		case *ExpKeyword:
+			guard := c.val[2:]
+			if guard != "when" && guard != "unless" && guard != "break" {
+				return nil, syntaxError(form, "unexpected keyword %s", c.val)
+			}
+			if i+1 >= len(elements) {
+				return nil, syntaxError(form, "missing expression after %s", c.val)
+			}
+			last.guards = append(last.guards, forClause{guard: guard, guardExp: elements[i+1]})
+			i++
+		case *ExpOperator:
+			names, ok := forClauseNames(c)
+			if !ok {
+				return nil, syntaxError(form, "bad sequence binding clause: %s", strings.TrimSpace(c.Print()))
+			}
+			if len(last.guards) > 0 || (nested && len(last.clauses) > 0) {
+				levels = append(levels, forLevel{})
+				last = &levels[len(levels)-1]
+			}
+			last.clauses = append(last.clauses, forClause{names: names, seq: c.operands[0]})
+		default:
+			return nil, syntaxError(form, "bad sequence binding clause: %s", strings.TrimSpace(c.Print()))
+		}
+	}
+	return levels, nil
+}
+
+// identifiers bound by [x seq] or [(k v) seq]
+func forClauseNames(c *ExpOperator) ([]string, bool) {
+	if len(c.operands) != 1 {
+		return nil, false
+	}
+	if c.proc == nil {
+		return []string{c.opeType}, c.opeType != ""
+	}
+	ids, ok := c.proc.(*ExpOperator)
+	if !ok {
+		return nil, false
+	}
+	var names []string
+	for _, id := range listElements(ids) {
+		name, ok := id.(*ExpIdentifier)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name.val)
+	}
+	return names, true
+}
+
+// the elements of hashes are two values, the key and the value
+func bindForValues(form string, frame map[string]interface{}, names []string, val interface{}) error {
+	values := []interface{}{val}
+	if e, ok := val.(hashEntry); ok {
+		values = []interface{}{e.key, e.value}
+	}
+	if len(values) != len(names) {
+		return resultArityError(form, fmt.Sprint(len(names)), len(values))
+	}
+	for i, name := range names {
+		frame[name] = values[i]
+	}
+	return nil
+}
+
+/*
+Call body with a new frame binding the variables of each iteration, body
+returns false to stop the loop. The loop also stops at the end of the shortest
+sequence of a level, or when a #:break guard is true.
+*/
+func iterateFor(form string, levels []forLevel, p Params, body func(p Params) (bool, error)) (bool, error) {
+	if len(levels) == 0 {
+		return body(p)
+	}
+	level := levels[0]
+	iterators := make([]iterator, len(level.clauses))
+	for i, c := range level.clauses {
+		seq, _, err := evalSingle(c.seq, p)
+		if err != nil {
+			return false, err
+		}
+		if iterators[i], err = newIterator(form, seq); err != nil {
+			return false, err
+		}
+	}
+	for {
+		frame := p.extendFrame()
+		for i, it := range iterators {
+			val, ok, err := it.next(p)
+			if err != nil || !ok {
+				return err == nil, err
+			}
+			if err := bindForValues(form, frame, level.clauses[i].names, val); err != nil {
+				return false, err
+			}
+		}
+		inner := p.withFrame(frame)
+		skip := false
+		for _, g := range level.guards {
+			val, _, err := evalSingle(g.guardExp, inner)
+			if err != nil {
+				return false, err
+			}
+			if g.guard == "break" && isTrue(val) {
+				return false, nil
+			}
+			if (g.guard == "when" && !isTrue(val)) || (g.guard == "unless" && isTrue(val)) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		if cont, err := iterateFor(form, levels[1:], inner, body); err != nil || !cont {
+			return cont, err
+		}
+		// a level without sequence is evaluated once
+		if len(iterators) == 0 {
+			return true, nil
+		}
+	}
+}
+
+// for, for/list, for/sum, for/product, for/and, for/or, for/first, for/last, for/fold and their for* variants
+func evalFor(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	form := e.opeType
+	nested := strings.HasPrefix(form, "for*")
+	kind := strings.TrimPrefix(strings.TrimPrefix(form, "for*"), "for")
+	operands := e.operands
+	// (for/fold ([acc init] ...) (clauses) body ...), the body returns one value per accumulator
+	var accNames []string
+	var accs []interface{}
+	var acc interface{} = void
+	if kind == "/fold" {
+		if len(operands) < 3 {
+			return nil, TYPE_ERROR, syntaxError(form, "bad syntax")
+		}
+		list, ok := operands[0].(*ExpOperator)
+		if !ok {
+			return nil, TYPE_ERROR, syntaxError(form, "expected a list of accumulators: ([acc init] ...)")
+		}
+		for _, a := range listElements(list) {
+			binding, ok := a.(*ExpOperator)
+			if !ok || binding.proc != nil || binding.opeType == "" || len(binding.operands) != 1 {
+				return nil, TYPE_ERROR, syntaxError(form, "bad accumulator: %s", strings.TrimSpace(a.Print()))
+			}
+			init, t, err := evalSingle(binding.operands[0], p)
+			if err != nil {
+				return init, t, err
+			}
+			accNames, accs = append(accNames, binding.opeType), append(accs, init)
+		}
+		operands = operands[1:]
+	}
+	if len(operands) < 2 {
+		return nil, TYPE_ERROR, syntaxError(form, "bad syntax")
+	}
+	levels, err := parseForClauses(form, operands[0], nested)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	body := newBody(operands[1:])
+	var results []interface{}
+	switch kind {
+	case "/sum":
+		acc = int64(0)
+	case "/product":
+		acc = int64(1)
+	case "/and":
+		acc = true
+	case "/or", "/first", "/last":
+		acc = false
+	}
+	_, err = iterateFor(form, levels, p, func(inner Params) (bool, error) {
+		if kind == "/fold" {
+			frame := inner.extendFrame()
+			for i, name := range accNames {
+				frame[name] = accs[i]
+			}
+			inner = inner.withFrame(frame)
+			val, _, err := body.Eval(inner)
+			if err != nil {
+				return false, err
+			}
+			if values := ResultValues(val); len(values) != len(accNames) {
+				return false, resultArityError(form, fmt.Sprint(len(accNames)), len(values))
+			} else {
+				accs = values
+			}
+			return true, nil
+		}
+		val, _, err := evalSingle(body, inner)
+		if err != nil {
+			return false, err
+		}
+		switch kind {
+		case "/list":
+			results = append(results, val)
+		case "/sum", "/product":
+			if !isNumber(val) {
+				return false, contractError(form, "number?", val)
+			}
+			if kind == "/sum" {
+				acc = numAdd(acc, val)
+			} else {
+				acc = numMul(acc, val)
+			}
+		case "/and":
+			acc = val
+			return isTrue(val), nil
+		case "/or":
+			acc = val
+			return !isTrue(val), nil
+		case "/first":
+			acc = val
+			return false, nil
+		case "/last":
+			acc = val
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	if kind == "/list" {
+		return sliceToList(results), TYPE_LIST, nil
+	} else if kind == "/fold" {
+		v, t := newValues(accs)
+		return v, t, nil
+	}
+	return acc, typeOf(acc), nil
+}
+
+func init() {
+	defineBuiltin("in-list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("in-list", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if _, ok := listToSlice(args[0]); !ok {
+			return nil, TYPE_ERROR, contractError("in-list", "list?", args[0])
+		}
+		return args[0], typeOf(args[0]), nil
+	})
+	defineBuiltin("in-string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("in-string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if _, ok := args[0].(string); !ok {
+			return nil, TYPE_ERROR, contractError("in-string", "string?", args[0])
+		}
+		return args[0], TYPE_STRING, nil
+	})
+	defineBuiltin("in-naturals", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (in-naturals) or (in-naturals start) is infinite
+		if err := checkArity("in-naturals", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var start interface{} = int64(0)
+		if len(args) == 1 {
+			if _, err := naturalArg("in-naturals", args[0]); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			start = args[0]
+		}
+		return rangeStream{start, math.Inf(1), int64(1)}, TYPE_STREAM, nil
+	})
+	defineBuiltin("sequence?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("sequence?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, err := newIterator("sequence?", args[0])
+		return err == nil, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("sequence->list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("sequence->list", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		it, err := newIterator("sequence->list", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var values []interface{}
+		for {
+			val, ok, err := it.next(p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			if !ok {
+				return sliceToList(values), TYPE_LIST, nil
+			}
+			if err := bindForValues("sequence->list", map[string]interface{}{}, []string{"x"}, val); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			values = append(values, val)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package minrkt
+
+import (
+	"testing"
+)
+
+func TestForLoops(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define l '(1 2 3))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(for/list ([x l]) (* x x))", "'(1 4 9)"},
+		{"(for/list ([x (in-list l)] [i (in-naturals)]) (list i x))", "'((0 1) (1 2) (2 3))"},
+		{"(for/list ([i 3]) i)", "'(0 1 2)"},
+		{"(for/list ([i (in-range 1 10 3)]) i)", "'(1 4 7)"},
+		{"(for/list ([c \"héllo\"]) c)", "'(#\\h #\\é #\\l #\\l #\\o)"},
+		{"(for/list ([c (in-string \"ab\")]) c)", "'(#\\a #\\b)"},
+		{"(for/list ([x l] #:when (> x 1)) x)", "'(2 3)"},
+		{"(for/list ([x l] #:unless (= x 2)) x)", "'(1 3)"},
+		{"(for/list ([x (in-naturals)] #:break (> x 3)) x)", "'(0 1 2 3)"},
+		{"(for/sum ([x l]) x)", "6"},
+		{"(for/product ([x l]) x)", "6"},
+		{"(for/fold ([acc '()]) ([x l]) (cons x acc))", "'(3 2 1)"},
+		{"(for/fold ([sum 0]) ([x l] [y l]) (+ sum (* x y)))", "14"},
+		{"(for/and ([x l]) (> x 0))", "#t"},
+		{"(for/and ([x l]) (< x 2))", "#f"},
+		{"(for/and ([x '()]) #f)", "#t"},
+		{"(for/or ([x l]) (if (> x 1) x #f))", "2"},
+		{"(for/or ([x l]) (> x 5))", "#f"},
+		{"(for/first ([x l] #:when (> x 1)) x)", "2"},
+		{"(for/last ([x l]) x)", "3"},
+		{"(for ([x l]) x)", ""},
+		// parallel clauses stop at the shortest sequence
+		{"(for/list ([x l] [y '(a b)]) (list x y))", "'((1 a) (2 b))"},
+		{"(for*/list ([x '(1 2)] [y '(a b)]) (list x y))", "'((1 a) (1 b) (2 a) (2 b))"},
+		{"(for*/list ([x 3] [y x]) (list x y))", "'((1 0) (2 0) (2 1))"},
+		// clauses after a guard are nested
+		{"(for/list ([x '(1 2)] #:when #t [y '(a b)]) (list x y))", "'((1 a) (1 b) (2 a) (2 b))"},
+		{"(for*/sum ([x 3] [y 3] #:when (= x y)) 1)", "3"},
+		{"(for*/fold ([n 0]) ([x '((1 2) (3))] [y x]) (+ n y))", "6"},
+		// each iteration has its own binding
+		{"(map (lambda (f) (f)) (for/list ([i 3]) (lambda () i)))", "'(0 1 2)"},
+		{"(sequence->list (in-range 3))", "'(0 1 2)"},
+		{"(sequence? 'a)", "#f"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(for/list ([x 'a]) x)", "for/list: contract violation\n  expected: sequence?\n  given: 'a"},
+		{"(for/sum ([x '(1 a)]) x)", "for/sum: contract violation\n  expected: number?\n  given: 'a"},
+		{"(for/list (x) x)", "for/list: bad sequence binding clause: x"},
+		{"(for/list ([x l] #:until #t) x)", "for/list: unexpected keyword #:until"},
+		{"(in-list 1)", "in-list: contract violation\n  expected: list?\n  given: 1"},
+	}
+	for _, c := range errorCases {
+		if _, _, err := evalLine(p, c.line); err == nil {
+			t.Error("expected evaluation error doesn't show up for expression", c.line)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package minrkt
+
+import (
+	"errors"
+)
+
+/*
+Expressions are evaluated by a loop over steps rather than by go recursion: the
+work waiting for the value of the expression being evaluated, e.g. the rest of
+the arguments of a call, is a linked list of frames, the continuation. Frames
+are never modified once created, so call/cc only keeps the list to resume it
+later, as many times as needed, and calls in tail position don't add frames.
+
+Builtins written in go which call procedures, e.g. sort, start a nested loop
+whose first frame is a barrier linked to the frames of the builtin call: a
+continuation captured inside can jump out of it but can't be resumed once the
+builtin returned. The builtins used with continuations the most, like map,
+apply and dynamic-wind, are natives, steps of the loop which add no barrier.
+*/
+
+// a frame of a continuation, next is the rest of the continuation
+type kont struct {
+	next *kont
+	// receives the values of the expression the frame waits for, with the rest of the continuation,
+	// the values go on to next when it's nil. It never uses next itself, so that frames can be copied.
+	resume func(v interface{}, t TypeEnum, k *kont) step
+	// *evaluation for barriers, *windFrame, *handlersFrame or *promptFrame
+	mark interface{}
+}
+
+/*
+What the loop does next: evaluate exp in p, raise err or give the values
+val to the continuation k
+*/
+type step struct {
+	exp Exp
+	p   *Params
+	val interface{}
+	t   TypeEnum
+	err error
+	k   *kont
+}
+
+// a loop of steps, base is the barrier at the bottom of its frames
+type evaluation struct {
+	base *kont
+	// not nested in another evaluation, e.g. started by the REPL, its continuations can be resumed by the next ones
+	top bool
+}
+
+// a native builtin: instead of returning its result it's the step giving it to k
+type nativeFunc func(args []interface{}, p *Params, k *kont) step
+
+var natives = make(map[string]nativeFunc)
+
+// natives are builtins too, called by go code they run in a nested loop
+func defineNative(name string, fn nativeFunc) {
+	natives[name] = fn
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return execute(p, func(k *kont) step { return fn(args, &p, k) })
+	})
+}
+
+// evaluate the steps from start until values reach the base of the loop, the loop is nested in p.cont
+func execute(p Params, start func(k *kont) step) (interface{}, TypeEnum, error) {
+	ev := &evaluation{top: p.cont == nil}
+	ev.base = &kont{next: p.cont, mark: ev}
+	s := start(ev.base)
+	for {
+		switch {
+		case s.err != nil:
+			var ended bool
+			if s, ended = ev.raise(s); ended {
+				return nil, TYPE_ERROR, s.err
+			}
+		case s.exp != nil:
+			s = s.exp.compute(s.p, s.k)
+		case s.k == ev.base:
+			return s.val, s.t, nil
+		case s.k.resume == nil:
+			s.k = s.k.next
+		default:
+			s = s.k.resume(s.val, s.t, s.k.next)
+		}
+	}
+}
+
+func eval(e Exp, p *Params, k *kont) step {
+	return step{exp: e, p: p, k: k}
+}
+
+func ret(v interface{}, t TypeEnum, k *kont) step {
+	return step{val: v, t: t, k: k}
+}
+
+func fail(err error, p *Params, k *kont) step {
+	return step{err: err, p: p, k: k}
+}
+
+// the Params of go code called in the continuation k, its evaluations are nested in k
+func (p *Params) at(k *kont) Params {
+	inner := *p
+	inner.cont = k
+	return inner
+}
+
+// the step giving the result of go code to k, e.g. returnTo(p, k)(evalStruct(e, p.at(k)))
+func returnTo(p *Params, k *kont) func(v interface{}, t TypeEnum, err error) step {
+	return func(v interface{}, t TypeEnum, err error) step {
+		if err != nil {
+			return fail(err, p, k)
+		}
+		return ret(v, t, k)
+	}
+}
+
+func push(k *kont, resume func(v interface{}, t TypeEnum, k *kont) step) *kont {
+	return &kont{next: k, resume: resume}
+}
+
+/*
+The frame receiving one value, like evalSingle. Frames share the Params of
+the expression creating them, Params are never modified once used by a step.
+*/
+func single(p *Params, k *kont, then func(v interface{}, k *kont) step) *kont {
+	return push(k, func(v interface{}, t TypeEnum, k *kont) step {
+		if mv, ok := v.(*multipleValues); ok {
+			return fail(resultArityError("", "1", len(mv.values)), p, k)
+		}
+		return then(v, k)
+	})
+}
+
+// constants and variables are evaluated without a frame, no continuation can be captured by them
+func immediate(e Exp) bool {
+	switch e.(type) {
+	case *ExpNum, *ExpBool, *ExpQuote, *ExpIdentifier:
+		return true
+	}
+	return false
+}
+
+// evaluate e as one value, e.g. an argument, and continue with then
+func evalOne(e Exp, p *Params, k *kont, then func(v interface{}, k *kont) step) step {
+	if immediate(e) {
+		v, _, err := e.Eval(*p)
+		if err != nil {
+			return fail(err, p, k)
+		}
+		return then(v, k)
+	}
+	return eval(e, p, single(p, k, then))
+}
+
+/*
+Evaluate the expressions in order as one value each, check is called on every
+value when it's not nil, then is called with the values
+*/
+func evalEach(exps []Exp, p *Params, k *kont, check func(v interface{}) error, then func(vals []interface{}, k *kont) step) step {
+	return evalFrom(exps, make([]interface{}, 0, len(exps)), p, k, check, then)
+}
+
+/*
+evalEach once the values of the first expressions are known. The values are
+appended to vals, a frame resumed by a continuation copies the ones before it.
+*/
+func evalFrom(exps []Exp, vals []interface{}, p *Params, k *kont, check func(v interface{}) error, then func(vals []interface{}, k *kont) step) step {
+	for i := len(vals); i < len(exps); i++ {
+		if !immediate(exps[i]) {
+			return eval(exps[i], p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+				if mv, ok := v.(*multipleValues); ok {
+					return fail(resultArityError("", "1", len(mv.values)), p, k)
+				}
+				if check != nil {
+					if err := check(v); err != nil {
+						return fail(err, p, k)
+					}
+				}
+				resumed := make([]interface{}, i, len(exps))
+				copy(resumed, vals)
+				return evalFrom(exps, append(resumed, v), p, k, check, then)
+			}))
+		}
+		v, _, err := exps[i].Eval(*p)
+		if err == nil && check != nil {
+			err = check(v)
+		}
+		if err != nil {
+			return fail(err, p, k)
+		}
+		vals = append(vals, v)
+	}
+	return then(vals, k)
+}
+
+/*
+The step calling a procedure with evaluated arguments, the machine counterpart of
+applyWithKeywords: natives, functions and continuations continue with k, other
+builtins run as go code
+*/
+func applyStep(proc interface{}, args []interface{}, keywords map[string]interface{}, p *Params, k *kont) step {
+	switch fv := proc.(type) {
+	case functionValue:
+		return callStep(fv, args, keywords, p, k)
+	case caseLambdaValue:
+		for kw := range keywords {
+			return fail(unexpectedKeywordError(proc, kw), p, k)
+		}
+		clause, err := fv.clause(args)
+		if err != nil {
+			return fail(err, p, k)
+		}
+		return callStep(clause, args, nil, p, k)
+	case builtinValue:
+		if native, ok := natives[fv.name]; ok && len(keywords) == 0 {
+			return native(args, p, k)
+		}
+	case continuationValue:
+		for kw := range keywords {
+			return fail(unexpectedKeywordError(proc, kw), p, k)
+		}
+		v, t := newValues(args)
+		return fail(&continuationJump{target: fv.k, escape: fv.escape, arrive: func(k *kont) step { return ret(v, t, k) }}, p, k)
+	}
+	return returnTo(p, k)(applyWithKeywords(proc, args, keywords, p.at(k)))
+}
+
+// apply a procedure whose result is used as one value, like applySingle
+func applyOne(proc interface{}, args []interface{}, p *Params, k *kont, then func(v interface{}, k *kont) step) step {
+	return applyStep(proc, args, nil, p, single(p, k, then))
+}
+
+// push the function on the call stack and evaluate its body in tail position
+func callStep(fv functionValue, args []interface{}, keywords map[string]interface{}, p *Params, k *kont) step {
+	body := p.at(k)
+	frame := StackFrame{Name: fv.name, Line: fv.line, Col: fv.col}
+	if n := len(body.Frames); n > 0 && k == p.tail {
+		// the caller is done once the call returns, its frame is replaced so that a loop doesn't add frames
+		body.Frames = append(body.Frames[:n-1:n-1], frame)
+	} else {
+		body.Frames = append(body.Frames, frame)
+	}
+	body.tail = k
+	body, err := bindArguments(fv, args, keywords, body)
+	if err != nil {
+		// errors of the arguments are raised in the function, like the ones of its body
+		return fail(err, &body, k)
+	}
+	return eval(fv.body, &body, k)
+}
+
+/*
+Raise s.err from the frames s.k: a jump goes to its continuation, other errors
+to the closest with-handlers accepting them. Ended is true when the error
+reaches the base, it's then returned to the go code which started the loop.
+*/
+func (ev *evaluation) raise(s step) (next step, ended bool) {
+	var jump *continuationJump
+	if errors.As(s.err, &jump) {
+		return ev.jump(jump, s.k, s.p)
+	}
+	if len(s.p.Frames) > 0 {
+		attachContext(s.err, s.p.Frames)
+	}
+	for k := s.k; k != ev.base; k = k.next {
+		switch mark := k.mark.(type) {
+		case *windFrame:
+			if err := mark.run(mark.post, k.next); err != nil {
+				return fail(err, mark.p, k.next), false
+			}
+		case *handlersFrame:
+			raised := raisedValue(s.err)
+			for i, pred := range mark.preds {
+				if got, _, err := applyProcedure(pred, []interface{}{raised}, mark.p.at(k.next)); err != nil {
+					return fail(err, mark.p, k.next), false
+				} else if isTrue(got) {
+					return applyStep(mark.handlers[i], []interface{}{raised}, nil, mark.p, k.next), false
+				}
+			}
+		}
+	}
+	return s, true
+}
+
+/*
+Leave the frames from the current continuation down to the frames it has in
+common with the target, running the post thunks of dynamic-wind, then enter
+the frames of the target, running the pre thunks, and arrive. When the common
+frames are below the base, the jump is returned to the go code which started
+the loop, which raises it in the loop below.
+*/
+func (ev *evaluation) jump(j *continuationJump, from *kont, p *Params) (step, bool) {
+	inTarget := make(map[*kont]bool)
+	for k := j.target; k != nil; k = k.next {
+		inTarget[k] = true
+	}
+	var common *kont
+	inside := true
+	for k := from; k != nil; k = k.next {
+		if inTarget[k] {
+			common = k
+			break
+		} else if k == ev.base {
+			inside = false
+		}
+	}
+	target := j.target
+	if common == nil && ev.top {
+		// a continuation of a previous evaluation of the REPL continues this one instead, like racket's prompts
+		root := target
+		for root.next != nil {
+			root = root.next
+		}
+		if r, ok := root.mark.(*evaluation); !ok || !r.top || j.escape {
+			return fail(jumpError(j), p, from), false
+		}
+		target, common, inside = rebase(target, root, ev.base), ev.base, true
+	}
+	if !inside || common == nil {
+		if next, failed := ev.leave(from, ev.base); failed {
+			return next, false
+		}
+		return step{err: j}, true
+	}
+	if j.escape && common != target {
+		return fail(jumpError(j), p, from), false
+	}
+	var entered []*kont
+	for k := target; k != common; k = k.next {
+		if _, ok := k.mark.(*evaluation); ok {
+			return fail(jumpError(j), p, from), false
+		}
+		entered = append(entered, k)
+	}
+	if next, failed := ev.leave(from, common); failed {
+		return next, false
+	}
+	for i := len(entered) - 1; i >= 0; i-- {
+		if w, ok := entered[i].mark.(*windFrame); ok {
+			if err := w.run(w.pre, entered[i].next); err != nil {
+				return fail(err, w.p, entered[i].next), false
+			}
+		}
+	}
+	return j.arrive(target), false
+}
+
+// run the post thunks of the frames from k down to end, failed is true when one of them raised an error
+func (ev *evaluation) leave(k *kont, end *kont) (step, bool) {
+	for ; k != end; k = k.next {
+		if w, ok := k.mark.(*windFrame); ok {
+			if err := w.run(w.post, k.next); err != nil {
+				return fail(err, w.p, k.next), true
+			}
+		}
+	}
+	return step{}, false
+}
+
+func jumpError(j *continuationJump) error {
+	if j.escape {
+		return newRacketError(ERR_CONTRACT, "continuation application", "attempt to jump into an escape continuation")
+	}
+	return newRacketError(ERR_CONTRACT, "continuation application", "attempt to cross a continuation barrier")
+}
+
+// a copy of the frames of k above root, on top of base
+func rebase(k *kont, root *kont, base *kont) *kont {
+	var frames []*kont
+	for ; k != root; k = k.next {
+		frames = append(frames, k)
+	}
+	for i := len(frames) - 1; i >= 0; i-- {
+		copied := *frames[i]
+		copied.next = base
+		base = &copied
+	}
+	return base
+}
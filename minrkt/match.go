@@ -0,0 +1,829 @@
+package minrkt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+(match expr [pat body ...] ...) evaluates the body of the first clause whose
+pattern matches the value of expr, a clause can have a guard:
+[pat #:when cond body ...]. Patterns are
+
+	_ and identifiers, bound to the matched value
+	literals and 'datum, compared with equal?
+	(list pat ...), (list-rest pat ... rest), (cons pat pat) and (vector pat ...)
+	(struct-id pat ...), one pattern per field of the struct
+	(? pred pat ...), (and pat ...) and (or pat ...)
+	`qp, where the unquoted parts ,pat are patterns and the rest is data,
+	,@(list pat ...) and ,@'(datum ...) are spliced in the list around them
+
+In lists and vectors, pat ... or pat ..k matches zero or at least k elements,
+the variables of pat being bound to the lists of what they matched. A list has
+at most one ellipsis, (list x ... y ...) is an unsupported pattern.
+
+The clauses are compiled once into a decision tree: each pattern is a sequence
+of tests on parts of the value, e.g. "the cdr is a pair", a test shared by
+several clauses is only performed once and its outcome discards the clauses
+which need the opposite outcome or a test it excludes, e.g. null? once pair?
+succeeded on the same part of the value.
+*/
+
+// how a part of the value is obtained from its parent
+type matchAccess int
+
+const (
+	accessCar matchAccess = iota
+	accessCdr
+	accessField      // index-th field of a struct instance
+	accessVectorList // elements of a vector as a list
+	accessListTail   // the last index elements of a list
+)
+
+// part of the matched value, the value itself is the path 0
+type matchPath struct {
+	parent int
+	access matchAccess
+	index  int
+}
+
+type matchTestKind int
+
+const (
+	testPair matchTestKind = iota
+	testNull
+	testVector
+	testStruct  // instance of the struct type name with count fields
+	testEqual   // equal? to value
+	testPred    // (pred v) isn't #f
+	testSame    // equal? to the part other, for variables appearing twice
+	testSegment // list of at least count elements matching elem, followed by suffix elements
+)
+
+type matchTest struct {
+	path   int
+	kind   matchTestKind
+	value  interface{}
+	name   string
+	count  int
+	suffix int
+	pred   Exp
+	other  int
+	elem   *matchTree
+	// variables of elem, bound to lists by the segment
+	vars []string
+}
+
+// a variable is bound to a part of the value or to what a segment collected for it
+type matchBinding struct {
+	name    string
+	path    int
+	segment int
+}
+
+// a clause, or one alternative of the or patterns of a clause
+type matchRow struct {
+	tests    []int
+	bindings []matchBinding
+	guard    Exp
+	body     []Exp
+}
+
+// a test and where to continue, or a row whose tests succeeded
+type matchNode struct {
+	test   int
+	then   *matchNode
+	orElse *matchNode
+	row    *matchRow
+	// where to continue when the guard of the row fails
+	next *matchNode
+}
+
+type matchTree struct {
+	paths []matchPath
+	tests []*matchTest
+	root  *matchNode
+}
+
+// a sequence of tests binding variables, patterns with or have several
+type matchAlt struct {
+	tests    []int
+	bindings []matchBinding
+}
+
+type matchCompiler struct {
+	tree    *matchTree
+	pathIDs map[matchPath]int
+	testIDs map[string]int
+	// nodes already built for the rows left and their remaining tests, the tree is a DAG sharing them
+	nodes map[string]*matchNode
+}
+
+// ... and ___ match zero or more elements, ..k and __k at least k elements
+var ellipsisRe = regexp.MustCompile(`^(?:\.\.\.|___|\.\.([0-9]+)|__([0-9]+))$`)
+
+func ellipsisMin(e Exp) (int, bool) {
+	id, ok := e.(*ExpIdentifier)
+	if !ok {
+		return 0, false
+	}
+	m := ellipsisRe.FindStringSubmatch(id.val)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1] + m[2])
+	return n, true
+}
+
+func newMatchCompiler() *matchCompiler {
+	return &matchCompiler{
+		tree:    &matchTree{paths: []matchPath{{parent: -1}}},
+		pathIDs: make(map[matchPath]int),
+		testIDs: make(map[string]int),
+		nodes:   make(map[string]*matchNode),
+	}
+}
+
+func (c *matchCompiler) path(parent int, access matchAccess, index int) int {
+	mp := matchPath{parent: parent, access: access, index: index}
+	if id, ok := c.pathIDs[mp]; ok {
+		return id
+	}
+	c.tree.paths = append(c.tree.paths, mp)
+	c.pathIDs[mp] = len(c.tree.paths) - 1
+	return len(c.tree.paths) - 1
+}
+
+// tests are shared by the clauses when they test the same thing on the same part
+func (c *matchCompiler) test(t *matchTest, detail string) matchAlt {
+	key := fmt.Sprintf("%d %d %s", t.path, t.kind, detail)
+	id, ok := c.testIDs[key]
+	if !ok {
+		c.tree.tests = append(c.tree.tests, t)
+		id = len(c.tree.tests) - 1
+		c.testIDs[key] = id
+	}
+	return matchAlt{tests: []int{id}}
+}
+
+// the alternatives of a then those of b
+func matchSeq(a, b []matchAlt) []matchAlt {
+	var alts []matchAlt
+	for _, x := range a {
+		for _, y := range b {
+			alts = append(alts, matchAlt{
+				tests:    append(append([]int{}, x.tests...), y.tests...),
+				bindings: append(append([]matchBinding{}, x.bindings...), y.bindings...),
+			})
+		}
+	}
+	return alts
+}
+
+// text identifying an expression, used to share the tests of equal patterns
+func expKey(e Exp) string {
+	if datum, err := expToDatum(e); err == nil {
+		return FormatValue(datum)
+	}
+	return fmt.Sprintf("%p", e)
+}
+
+func patternError(pat Exp) error {
+	datum, err := expToDatum(pat)
+	if err != nil {
+		return syntaxError("match", "syntax error in pattern")
+	}
+	return syntaxError("match", "syntax error in pattern: %s", formatDatum(datum))
+}
+
+func (c *matchCompiler) compile(pat Exp, path int) ([]matchAlt, error) {
+	switch v := pat.(type) {
+	case *ExpIdentifier:
+		if _, ok := ellipsisMin(v); ok || v.val == "." {
+			return nil, syntaxError("match", "incorrect use of %s in pattern", v.val)
+		} else if v.val == "_" {
+			return []matchAlt{{}}, nil
+		}
+		return []matchAlt{{bindings: []matchBinding{{name: v.val, path: path, segment: -1}}}}, nil
+	case *ExpNum:
+		return []matchAlt{c.test(&matchTest{path: path, kind: testEqual, value: v.val}, FormatValue(v.val))}, nil
+	case *ExpBool:
+		return []matchAlt{c.test(&matchTest{path: path, kind: testEqual, value: v.val}, FormatValue(v.val))}, nil
+	case *ExpKeyword:
+		value := keyword(v.val[2:])
+		return []matchAlt{c.test(&matchTest{path: path, kind: testEqual, value: value}, FormatValue(value))}, nil
+	case *ExpQuote:
+		return c.compileDatum(v.val, path), nil
+	case *ExpOperator:
+		if v.proc != nil {
+			return nil, patternError(pat)
+		}
+		switch v.opeType {
+		case "":
+			return []matchAlt{c.test(&matchTest{path: path, kind: testNull}, "")}, nil
+		case "quote":
+			if len(v.operands) != 1 {
+				return nil, patternError(pat)
+			}
+			datum, err := expToDatum(v.operands[0])
+			if err != nil {
+				return nil, err
+			}
+			return c.compileDatum(datum, path), nil
+		case "quasiquote":
+			if len(v.operands) != 1 {
+				return nil, patternError(pat)
+			}
+			converted, err := quasiPattern(v.operands[0])
+			if err != nil {
+				return nil, err
+			}
+			return c.compile(converted, path)
+		case "list":
+			return c.compileList(v.operands, nil, path)
+		case "list-rest":
+			if len(v.operands) == 0 {
+				return nil, patternError(pat)
+			}
+			return c.compileList(v.operands[:len(v.operands)-1], v.operands[len(v.operands)-1], path)
+		case "cons":
+			if len(v.operands) != 2 {
+				return nil, patternError(pat)
+			}
+			return c.compileList(v.operands[:1], v.operands[1], path)
+		case "vector":
+			alts := []matchAlt{c.test(&matchTest{path: path, kind: testVector}, "")}
+			elements, err := c.compileList(v.operands, nil, c.path(path, accessVectorList, 0))
+			if err != nil {
+				return nil, err
+			}
+			return matchSeq(alts, elements), nil
+		case "?":
+			if len(v.operands) == 0 {
+				return nil, patternError(pat)
+			}
+			alts := []matchAlt{c.test(&matchTest{path: path, kind: testPred, pred: v.operands[0]}, expKey(v.operands[0]))}
+			return c.compileAnd(alts, v.operands[1:], path)
+		case "and":
+			return c.compileAnd([]matchAlt{{}}, v.operands, path)
+		case "or":
+			var alts []matchAlt
+			for _, operand := range v.operands {
+				alt, err := c.compile(operand, path)
+				if err != nil {
+					return nil, err
+				}
+				alts = append(alts, alt...)
+			}
+			return alts, nil
+		}
+		// (point x y) matches the instances of the struct point and its subtypes
+		alts := []matchAlt{c.test(&matchTest{path: path, kind: testStruct, name: v.opeType, count: len(v.operands)},
+			fmt.Sprintf("%s %d", v.opeType, len(v.operands)))}
+		for i, operand := range v.operands {
+			field, err := c.compile(operand, c.path(path, accessField, i))
+			if err != nil {
+				return nil, err
+			}
+			alts = matchSeq(alts, field)
+		}
+		return alts, nil
+	}
+	return nil, patternError(pat)
+}
+
+func (c *matchCompiler) compileAnd(alts []matchAlt, pats []Exp, path int) ([]matchAlt, error) {
+	for _, pat := range pats {
+		next, err := c.compile(pat, path)
+		if err != nil {
+			return nil, err
+		}
+		alts = matchSeq(alts, next)
+	}
+	return alts, nil
+}
+
+// 'datum is matched part by part, so that '(1 2) and (list 1 b) share their tests
+func (c *matchCompiler) compileDatum(datum interface{}, path int) []matchAlt {
+	switch v := datum.(type) {
+	case emptyList:
+		return []matchAlt{c.test(&matchTest{path: path, kind: testNull}, "")}
+	case *pair:
+		alts := []matchAlt{c.test(&matchTest{path: path, kind: testPair}, "")}
+		alts = matchSeq(alts, c.compileDatum(v.car, c.path(path, accessCar, 0)))
+		return matchSeq(alts, c.compileDatum(v.cdr, c.path(path, accessCdr, 0)))
+	}
+	return []matchAlt{c.test(&matchTest{path: path, kind: testEqual, value: datum}, FormatValue(datum))}
+}
+
+// elements followed by the tail, '() when tail is nil
+func (c *matchCompiler) compileList(elements []Exp, tail Exp, path int) ([]matchAlt, error) {
+	ellipsis := -1
+	for i, element := range elements {
+		if _, ok := ellipsisMin(element); !ok {
+			continue
+		} else if ellipsis >= 0 && i > ellipsis+1 && tail == nil {
+			// the elements of each repetition would depend on the others, e.g. (list x ... y ...)
+			return nil, syntaxError("match", "unsupported pattern: more than one ellipsis in a list")
+		} else if i == 0 || ellipsis >= 0 || tail != nil {
+			return nil, syntaxError("match", "incorrect use of %s in pattern", element.(*ExpIdentifier).val)
+		}
+		ellipsis = i
+	}
+	if ellipsis >= 0 {
+		prefix, repeated, suffix := elements[:ellipsis-1], elements[ellipsis-1], elements[ellipsis+1:]
+		min, _ := ellipsisMin(elements[ellipsis])
+		return c.compileSegment(prefix, repeated, min, suffix, path)
+	}
+	if len(elements) == 0 {
+		if tail == nil {
+			return []matchAlt{c.test(&matchTest{path: path, kind: testNull}, "")}, nil
+		}
+		return c.compile(tail, path)
+	}
+	alts := []matchAlt{c.test(&matchTest{path: path, kind: testPair}, "")}
+	first, err := c.compile(elements[0], c.path(path, accessCar, 0))
+	if err != nil {
+		return nil, err
+	}
+	rest, err := c.compileList(elements[1:], tail, c.path(path, accessCdr, 0))
+	if err != nil {
+		return nil, err
+	}
+	return matchSeq(matchSeq(alts, first), rest), nil
+}
+
+// prefix elements, then at least min elements matching repeated, then the suffix elements
+func (c *matchCompiler) compileSegment(prefix []Exp, repeated Exp, min int, suffix []Exp, path int) ([]matchAlt, error) {
+	if len(prefix) > 0 {
+		alts := []matchAlt{c.test(&matchTest{path: path, kind: testPair}, "")}
+		first, err := c.compile(prefix[0], c.path(path, accessCar, 0))
+		if err != nil {
+			return nil, err
+		}
+		rest, err := c.compileSegment(prefix[1:], repeated, min, suffix, c.path(path, accessCdr, 0))
+		if err != nil {
+			return nil, err
+		}
+		return matchSeq(matchSeq(alts, first), rest), nil
+	}
+	elem, vars, err := compileMatchTree([]Exp{repeated}, nil)
+	if err != nil {
+		return nil, err
+	}
+	segment := c.test(&matchTest{path: path, kind: testSegment, count: min, suffix: len(suffix), elem: elem, vars: vars},
+		fmt.Sprintf("%s %d %d", expKey(repeated), min, len(suffix)))
+	for _, name := range vars {
+		segment.bindings = append(segment.bindings, matchBinding{name: name, segment: segment.tests[0]})
+	}
+	rest, err := c.compileList(suffix, nil, c.path(path, accessListTail, len(suffix)))
+	if err != nil {
+		return nil, err
+	}
+	return matchSeq([]matchAlt{segment}, rest), nil
+}
+
+// the pattern written as the quasi-pattern qp: ,pat is pat and the rest is quoted
+func quasiPattern(qp Exp) (Exp, error) {
+	if _, ok := ellipsisMin(qp); ok {
+		return qp, nil
+	}
+	if m, ok := qp.(*ExpMatch); ok {
+		qp = m.ExpOperator
+	}
+	op, ok := qp.(*ExpOperator)
+	if !ok || (op.proc == nil && op.opeType == "") {
+		datum, err := expToDatum(qp)
+		if err != nil {
+			return nil, err
+		}
+		return newExpQuote(datum), nil
+	}
+	if op.proc == nil && len(op.operands) == 1 {
+		switch op.opeType {
+		case "unquote":
+			return op.operands[0], nil
+		case "unquote-splicing":
+			return nil, syntaxError("match", "unsupported pattern: ,@ outside of a list")
+		}
+	}
+	elements := listElements(op)
+	form := "list"
+	if n := len(elements); n >= 2 {
+		if id, ok := elements[n-2].(*ExpIdentifier); ok && (id.val == "." || id.val == "unquote") {
+			// `(a . b), `(a . ,rest) is read as `(a unquote rest)
+			last := elements[n-1]
+			if id.val == "unquote" {
+				last = &ExpOperator{opeType: "unquote", operands: []Exp{last}}
+			}
+			form = "list-rest"
+			elements = append(elements[:n-2:n-2], last)
+		}
+	}
+	converted := &ExpOperator{opeType: form}
+	for _, element := range elements {
+		if spliced, ok, err := splicedPatterns(element); err != nil {
+			return nil, err
+		} else if ok {
+			converted.operands = append(converted.operands, spliced...)
+			continue
+		}
+		pat, err := quasiPattern(element)
+		if err != nil {
+			return nil, err
+		}
+		converted.operands = append(converted.operands, pat)
+	}
+	return converted, nil
+}
+
+// the elements of ,@(list pat ...) or ,@'(datum ...), which are part of the list around them
+func splicedPatterns(e Exp) ([]Exp, bool, error) {
+	op, ok := e.(*ExpOperator)
+	if !ok || op.proc != nil || op.opeType != "unquote-splicing" || len(op.operands) != 1 {
+		return nil, false, nil
+	}
+	switch v := op.operands[0].(type) {
+	case *ExpOperator:
+		if v.proc == nil && v.opeType == "list" {
+			return v.operands, true, nil
+		}
+	case *ExpQuote:
+		if data, ok := listToSlice(v.val); ok {
+			pats := make([]Exp, len(data))
+			for i, datum := range data {
+				pats[i] = newExpQuote(datum)
+			}
+			return pats, true, nil
+		}
+	}
+	return nil, false, syntaxError("match", "unsupported pattern: ,@ must be followed by (list pat ...) or a quoted list")
+}
+
+/*
+Decision tree of the clauses, the variables bound by the first row are returned
+for the segments, whose tree has a single pattern
+*/
+func compileMatchTree(pats []Exp, clauses [][]Exp) (*matchTree, []string, error) {
+	c := newMatchCompiler()
+	var rows []*matchRow
+	for i, pat := range pats {
+		alts, err := c.compile(pat, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, alt := range alts {
+			row := &matchRow{}
+			if clauses != nil {
+				row.guard, row.body = clauseGuard(clauses[i])
+			}
+			// a variable appearing twice must match equal values
+			bound := make(map[string]int)
+			row.tests = alt.tests
+			for _, b := range alt.bindings {
+				if path, ok := bound[b.name]; ok && b.segment < 0 && path >= 0 {
+					same := c.test(&matchTest{path: b.path, kind: testSame, other: path}, strconv.Itoa(path))
+					row.tests = append(append([]int{}, row.tests...), same.tests...)
+					continue
+				}
+				if b.segment < 0 {
+					bound[b.name] = b.path
+				} else {
+					bound[b.name] = -1
+				}
+				row.bindings = append(row.bindings, b)
+			}
+			rows = append(rows, row)
+		}
+	}
+	var vars []string
+	if len(rows) > 0 {
+		for _, b := range rows[0].bindings {
+			vars = append(vars, b.name)
+		}
+	}
+	candidates := make([]matchCandidate, len(rows))
+	for i, row := range rows {
+		candidates[i] = matchCandidate{row: row, tests: row.tests}
+	}
+	c.tree.root = c.build(candidates, map[int]bool{})
+	return c.tree, vars, nil
+}
+
+// a row and its tests whose outcome isn't known yet
+type matchCandidate struct {
+	row   *matchRow
+	tests []int
+}
+
+func (c *matchCompiler) build(candidates []matchCandidate, known map[int]bool) *matchNode {
+	var live []matchCandidate
+	for _, candidate := range candidates {
+		remaining := []int{}
+		possible := true
+		for _, t := range candidate.tests {
+			if outcome, ok := known[t]; !ok {
+				remaining = append(remaining, t)
+			} else if !outcome {
+				possible = false
+				break
+			}
+		}
+		if possible {
+			live = append(live, matchCandidate{row: candidate.row, tests: remaining})
+		}
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	// the outcomes known for the tests which aren't left don't change the node, e.g. after each failing
+	// clause the same rows are left, whichever test failed
+	var key strings.Builder
+	for _, candidate := range live {
+		fmt.Fprintf(&key, "%p%v", candidate.row, candidate.tests)
+	}
+	if node, ok := c.nodes[key.String()]; ok {
+		return node
+	}
+	var node *matchNode
+	if len(live[0].tests) == 0 {
+		node = &matchNode{test: -1, row: live[0].row}
+		if live[0].row.guard != nil {
+			node.next = c.build(live[1:], known)
+		}
+	} else {
+		// the first test of the first clause, its parts are accessible once the previous tests succeeded
+		t := live[0].tests[0]
+		node = &matchNode{test: t, then: c.build(live, c.learn(known, t, true)), orElse: c.build(live, c.learn(known, t, false))}
+	}
+	c.nodes[key.String()] = node
+	return node
+}
+
+func (c *matchCompiler) learn(known map[int]bool, t int, outcome bool) map[int]bool {
+	learned := make(map[int]bool, len(known)+1)
+	for k, v := range known {
+		learned[k] = v
+	}
+	learned[t] = outcome
+	if outcome {
+		for u := range c.tree.tests {
+			if _, ok := learned[u]; !ok && excludes(c.tree.tests[t], c.tree.tests[u]) {
+				learned[u] = false
+			}
+		}
+	}
+	return learned
+}
+
+// whether a value can't pass both tests, only the tests of the kind of value are compared
+func excludes(a, b *matchTest) bool {
+	if a.path != b.path || a.kind > testEqual || b.kind > testEqual {
+		return false
+	}
+	if a.kind == b.kind {
+		return a.kind == testEqual && !isEqual(a.value, b.value)
+	}
+	if a.kind == testStruct || b.kind == testStruct {
+		return true
+	}
+	// literals are never pairs or null, but can be vectors
+	if a.kind == testEqual || b.kind == testEqual {
+		literal, other := a, b
+		if b.kind == testEqual {
+			literal, other = b, a
+		}
+		_, isVector := literal.value.(*vector)
+		return other.kind != testVector || !isVector
+	}
+	return true
+}
+
+// [pat body ...] or [pat #:when cond body ...]
+func clauseGuard(clause []Exp) (Exp, []Exp) {
+	if len(clause) >= 3 {
+		if kw, ok := clause[1].(*ExpKeyword); ok && kw.val == "#:when" {
+			return clause[2], clause[3:]
+		}
+	}
+	return nil, clause[1:]
+}
+
+// the parts of a value computed while it is matched
+type matchState struct {
+	tree     *matchTree
+	values   []interface{}
+	ready    []bool
+	segments map[int]map[string]interface{}
+	p        Params
+}
+
+func newMatchState(tree *matchTree, v interface{}, p Params) *matchState {
+	s := &matchState{tree: tree, values: make([]interface{}, len(tree.paths)), ready: make([]bool, len(tree.paths)),
+		segments: make(map[int]map[string]interface{}), p: p}
+	s.values[0], s.ready[0] = v, true
+	return s
+}
+
+// the tests preceding the access ensure that the parent has the right type
+func (s *matchState) value(path int) interface{} {
+	if s.ready[path] {
+		return s.values[path]
+	}
+	mp := s.tree.paths[path]
+	parent := s.value(mp.parent)
+	var v interface{}
+	switch mp.access {
+	case accessCar:
+		v = parent.(*pair).car
+	case accessCdr:
+		v = parent.(*pair).cdr
+	case accessField:
+		v = parent.(*structValue).fields[mp.index]
+	case accessVectorList:
+		v = sliceToList(parent.(*vector).elements)
+	case accessListTail:
+		l, _ := listToSlice(parent)
+		v = sliceToList(l[len(l)-mp.index:])
+	}
+	s.values[path], s.ready[path] = v, true
+	return v
+}
+
+func (s *matchState) perform(id int) (bool, error) {
+	t := s.tree.tests[id]
+	v := s.value(t.path)
+	switch t.kind {
+	case testPair:
+		_, ok := v.(*pair)
+		return ok, nil
+	case testNull:
+		_, ok := v.(emptyList)
+		return ok, nil
+	case testVector:
+		_, ok := v.(*vector)
+		return ok, nil
+	case testStruct:
+		found, ok, err := lookupIdentifier("struct:"+t.name, s.p)
+		if err != nil {
+			return false, err
+		}
+		st, isType := found.(*structType)
+		if !ok || !isType {
+			return false, syntaxError("match", "syntax error in pattern: %s is not a structure type", t.name)
+		} else if st.fieldCount() != t.count {
+			return false, syntaxError("match", "wrong number for fields for structure %s: expected %d but got %d",
+				t.name, st.fieldCount(), t.count)
+		}
+		sv, ok := v.(*structValue)
+		return ok && sv.stype.isA(st), nil
+	case testEqual:
+		return isEqual(v, t.value), nil
+	case testPred:
+		pred, _, err := t.pred.Eval(s.p)
+		if err != nil {
+			return false, err
+		}
+		res, _, err := applySingle(pred, []interface{}{v}, s.p)
+		if err != nil {
+			return false, err
+		}
+		return isTrue(res), nil
+	case testSame:
+		return isEqual(v, s.value(t.other)), nil
+	case testSegment:
+		l, ok := listToSlice(v)
+		if !ok || len(l) < t.count+t.suffix {
+			return false, nil
+		}
+		collected := make(map[string][]interface{}, len(t.vars))
+		for _, element := range l[:len(l)-t.suffix] {
+			_, bindings, err := t.elem.find(element, s.p)
+			if err != nil || bindings == nil {
+				return false, err
+			}
+			for _, name := range t.vars {
+				if value, ok := bindings[name]; ok {
+					collected[name] = append(collected[name], value)
+				} else {
+					collected[name] = append(collected[name], false)
+				}
+			}
+		}
+		lists := make(map[string]interface{}, len(t.vars))
+		for _, name := range t.vars {
+			lists[name] = sliceToList(collected[name])
+		}
+		s.segments[id] = lists
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *matchState) bindings(row *matchRow) map[string]interface{} {
+	bindings := make(map[string]interface{}, len(row.bindings))
+	for _, b := range row.bindings {
+		if b.segment >= 0 {
+			bindings[b.name] = s.segments[b.segment][b.name]
+		} else {
+			bindings[b.name] = s.value(b.path)
+		}
+	}
+	return bindings
+}
+
+// the first row matching v whose guard holds and its variables, nil bindings when no row matches
+func (tree *matchTree) find(v interface{}, p Params) (*matchRow, map[string]interface{}, error) {
+	s := newMatchState(tree, v, p)
+	node := tree.root
+	for node != nil {
+		if node.row == nil {
+			ok, err := s.perform(node.test)
+			if err != nil {
+				return nil, nil, err
+			} else if ok {
+				node = node.then
+			} else {
+				node = node.orElse
+			}
+			continue
+		}
+		bindings := s.bindings(node.row)
+		if node.row.guard == nil {
+			return node.row, bindings, nil
+		}
+		if ok, _, err := node.row.guard.Eval(matchScope(p, bindings)); err != nil {
+			return nil, nil, err
+		} else if isTrue(ok) {
+			return node.row, bindings, nil
+		}
+		node = node.next
+	}
+	return nil, nil, nil
+}
+
+func matchScope(p Params, bindings map[string]interface{}) Params {
+	frame := p.extendFrame()
+	for k, v := range bindings {
+		frame[k] = v
+	}
+	return p.withFrame(frame)
+}
+
+func compileMatch(e *ExpOperator) (*matchTree, error) {
+	var pats []Exp
+	var clauses [][]Exp
+	for _, c := range e.operands[1:] {
+		clause, ok := c.(*ExpOperator)
+		if !ok {
+			return nil, syntaxError("match", "expected a clause with a pattern and a body")
+		}
+		elements := listElements(clause)
+		if len(elements) < 2 {
+			return nil, syntaxError("match", "expected a clause with a pattern and a body")
+		} else if _, body := clauseGuard(elements); len(body) == 0 {
+			return nil, syntaxError("match", "expected a clause with a pattern and a body")
+		}
+		pats = append(pats, elements[0])
+		clauses = append(clauses, elements)
+	}
+	tree, _, err := compileMatchTree(pats, clauses)
+	return tree, err
+}
+
+// match expression, its clauses are compiled to a decision tree when it's first evaluated
+type ExpMatch struct {
+	*ExpOperator
+	once sync.Once
+	tree *matchTree
+	err  error
+}
+
+func (e *ExpMatch) Eval(p Params) (interface{}, TypeEnum, error) {
+	return execute(p, func(k *kont) step { return eval(e, &p, k) })
+}
+
+// (match expr [pat body ...] ...), the body of the matching clause is in tail position
+func (e *ExpMatch) compute(p *Params, k *kont) step {
+	if len(e.operands) == 0 {
+		return fail(syntaxError("match", "bad syntax"), p, k)
+	}
+	e.once.Do(func() { e.tree, e.err = compileMatch(e.ExpOperator) })
+	if e.err != nil {
+		return fail(e.err, p, k)
+	}
+	return eval(e.operands[0], p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+		// the predicates and guards are go code nested in k
+		row, bindings, err := e.tree.find(v, p.at(k))
+		if err != nil {
+			return fail(err, p, k)
+		} else if row == nil {
+			return fail(newRacketError(ERR_FAIL, "match", "no matching clause for %s", FormatValue(v)), p, k)
+		}
+		body := matchScope(*p, bindings)
+		return eval(newBody(row.body), &body, k)
+	}))
+}
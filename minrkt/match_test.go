@@ -0,0 +1,139 @@
+package minrkt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(struct point (x y) #:transparent)")
+	evalLine(p, "(struct point3 point (z))")
+	evalLine(p, "(define calls (make-vector 1 0))")
+	evalLine(p, "(define (big? x) (vector-set! calls 0 (+ (vector-ref calls 0) 1)) (> x 10))")
+	evalLine(p, "(define (classify v) (match v [(? number? (? big?)) 'big] [(? number? (? big?) (? even?)) 'unreachable] [(? number?) 'small] [_ 'other]))")
+	evalLine(p, "(define (len l) (match l ['() 0] [(cons _ rest) (+ 1 (len rest))]))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(match 1 [1 'one] [2 'two])", "'one"},
+		{"(match \"b\" [\"a\" 1] [\"b\" 2])", "2"},
+		{"(match 'x ['y 1] ['x 2])", "2"},
+		{"(match #\\a [#\\a 'a])", "'a"},
+		{"(match 5 [x (* x x)])", "25"},
+		{"(match '(1 2) [_ 'anything])", "'anything"},
+		{"(match '(1 2 3) [(list a b c) (+ a b c)])", "6"},
+		{"(match '(1 2 3) [`(1 ,@(list a b)) (list a b)])", "'(2 3)"},
+		{"(match '(0 1 2 3) [`(0 ,@(list a ...)) a])", "'(1 2 3)"},
+		{"(match '(1 2 3) [`(,@'(1 2) ,x) x])", "3"},
+		// match expressions are nodes of their own, quoting them gives the list
+		{"(quote (match 1 [_ 2]))", "'(match 1 (_ 2))"},
+		{"`(match ,(+ 1 2))", "'(match 3)"},
+		{"(match '(1 2) [(list a b c) 'three] [(list a b) 'two])", "'two"},
+		{"(match '(1 2 3) [(cons h t) (list h t)])", "'(1 (2 3))"},
+		{"(match '(1 2 3 4) [(list-rest a b rest) rest])", "'(3 4)"},
+		{"(match '(1 (2 3)) [(list a (list b c)) (list c b a)])", "'(3 2 1)"},
+		{"(match '(1 2 3) [(list x ...) x])", "'(1 2 3)"},
+		{"(match '(1 2 3 4) [(list a b ... c) (list a b c)])", "'(1 (2 3) 4)"},
+		{"(match '((a 1) (b 2)) [(list (list k v) ...) (list k v)])", "'((a b) (1 2))"},
+		{"(match '() [(list x ..1) 'some] [(list x ...) x])", "'()"},
+		{"(match '(1 a) [(list (? number?) ...) 'numbers] [_ 'mixed])", "'mixed"},
+		{"(match '((1 2) (3)) [(list (list x ...) ...) x])", "'((1 2) (3))"},
+		{"(match (vector 1 2 3) [(vector a b c) (+ a b c)])", "6"},
+		{"(match (vector 1 2 3) [(vector a rest ...) rest])", "'(2 3)"},
+		{"(match '(1 2) [(vector a b) 'vector] [(list a b) 'list])", "'list"},
+		{"(match (point 1 2) [(point x y) (list x y)])", "'(1 2)"},
+		{"(match (point3 1 2 3) [(point3 x y z) z])", "3"},
+		{"(match (point3 1 2 3) [(point x y) 'point])", "'point"},
+		{"(match 7 [(? string?) 'string] [(? number? n) (+ n 1)])", "8"},
+		{"(match 4 [(and n (? even?)) (list 'even n)])", "'(even 4)"},
+		{"(match 'b [(or 'a 'b) 'ab] [_ 'other])", "'ab"},
+		{"(match '(2 x) [(or (list 1 v) (list 2 v)) v])", "'x"},
+		{"(match '(1 (2 3)) [`(1 (,a ,b)) (+ a b)])", "5"},
+		{"(match '(add 1 2) [`(add ,x ,y) (+ x y)] [`(neg ,x) (- x)])", "3"},
+		{"(match '(1 . 2) [`(,a . ,b) (list b a)])", "'(2 1)"},
+		{"(match 5 [x #:when (> x 10) 'big] [x 'small])", "'small"},
+		{"(match '(1 1) [(list a b) #:when (= a b) 'same] [_ 'different])", "'same"},
+		{"(match '(1 2) [(list a a) 'same] [_ 'different])", "'different"},
+		{"(match '(3 3) [(list a a) a] [_ 'different])", "3"},
+		{"(len '(a b c))", "3"},
+		{"(begin (vector-set! calls 0 0) (list (classify 5) (classify 20) (classify \"a\") (vector-ref calls 0)))", "'(small big other 2)"},
+		{"(let ([x 1]) (match 2 [y (+ x y)]))", "3"},
+		{"`(1 ,(+ 1 1) ,@(list 3 4))", "'(1 2 3 4)"},
+		{"`(a `(b ,(c ,(+ 1 2))))", "'(a (quasiquote (b (unquote (c 3)))))"},
+		{"`(1 . ,(+ 1 1))", "'(1 . 2)"},
+		{"`x", "'x"},
+		{"`(\"s\" #\\a)", "'(\"s\" #\\a)"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(match 3 [1 'one] [2 'two])", "match: no matching clause for 3"},
+		{"(match '(1 2) [(list a) a])", "match: no matching clause for '(1 2)"},
+		{"(match 1 [(list ... a) a])", "match: incorrect use of ... in pattern"},
+		{"(match 1 [((f) a) a])", "match: syntax error in pattern: ((f) a)"},
+		{"(match (point 1 2) [(point x) x])", "match: wrong number for fields for structure point: expected 2 but got 1"},
+		{"(match 1 [x])", "match: expected a clause with a pattern and a body"},
+		{"(match '(1 2) [`(1 ,@r) r])", "match: unsupported pattern: ,@ must be followed by (list pat ...) or a quoted list"},
+		{"(match '(1 2) [`,@(list a) a])", "match: unsupported pattern: ,@ outside of a list"},
+		{"(match '(1 2 3 4) [(list x ... y ...) x])", "match: unsupported pattern: more than one ellipsis in a list"},
+		{"(match '(1 2 3 4) [`(,x ... 3 ,y ...) x])", "match: unsupported pattern: more than one ellipsis in a list"},
+		{"`(1 ,@2)", "unquote-splicing: contract violation\n  expected: list?\n  given: 2"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+	if _, _, err := evalLine(p, "(list 1 ,2)"); err == nil || err.Error() != "unquote: not in quasiquote" {
+		t.Error("expected an unquote error but got", err)
+	}
+}
+
+func TestMatch_ManyClauses(t *testing.T) {
+	p := newTestParams()
+	// each clause fails on one of three tests, the rows left are the same whichever failed
+	var b strings.Builder
+	b.WriteString("(match '(1 2 30)")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&b, " [(list (? (lambda (x) (> x %d))) (? (lambda (y) (< y %d))) %d) %d]", i-1, i+3, i, i)
+	}
+	b.WriteString(" [_ 'none])")
+	done := make(chan struct{})
+	var got string
+	var err error
+	go func() {
+		var result interface{}
+		result, _, err = evalLine(p, b.String())
+		got = FormatValue(result)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a match with 30 clauses to be compiled in time")
+	}
+	if err != nil || got != "'none" {
+		t.Error("expected evaluated result of the match is 'none but got", got, err)
+	}
+	if result, _, err := evalLine(p, "(match '(1 2 0) [(list (? odd?) (? even?) 1) 'a] [(list (? odd?) (? even?) 0) 'b])"); err != nil || FormatValue(result) != "'b" {
+		t.Error("expected evaluated result of the match is 'b but got", FormatValue(result), err)
+	}
+}
@@ -0,0 +1,374 @@
+package minrkt
+
+import (
+	"math"
+	"math/big"
+)
+
+/*
+Racket gives complex numbers for e.g. (sqrt -4) or (log -1), which aren't
+supported: these calls raise a contract error rather than returning +nan.0
+like go's math package.
+*/
+
+func complexError(proc string, given interface{}) *RacketError {
+	return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "complex numbers are not supported",
+		Fields: []ErrorField{{"given", FormatValue(given)}}}
+}
+
+func isNegative(n interface{}) bool {
+	return numCompare("<", n, int64(0))
+}
+
+// one argument numeric procedures, the argument satisfies the predicate named by expected
+func defineNumeric(name string, expected string, fn func(n interface{}) (interface{}, error)) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(name, args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if !isNumber(args[0]) || (expected == "integer?" && !isInteger(args[0])) ||
+			(expected == "rational?" && !isRational(args[0])) {
+			return nil, TYPE_ERROR, contractError(name, expected, args[0])
+		}
+		result, err := fn(args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return result, typeOf(result), nil
+	})
+}
+
+// procedures of go's math package, exact only for the exact argument giving an exact result, e.g. (exp 0) -> 1
+func defineInexact(name string, exactArg int64, exactResult int64, fn func(float64) float64) {
+	defineNumeric(name, "number?", func(n interface{}) (interface{}, error) {
+		if n == exactArg {
+			return exactResult, nil
+		}
+		return fn(toFloat(n)), nil
+	})
+}
+
+// the rounding procedures keep exact integers as they are, exact fractions are rounded by exact
+func defineRounding(name string, fn func(float64) float64, exact func(r *big.Rat) *big.Int) {
+	defineNumeric(name, "rational?", func(n interface{}) (interface{}, error) {
+		if r, ok := n.(*big.Rat); ok {
+			return normalizeInt(exact(r)), nil
+		} else if isExact(n) {
+			return n, nil
+		}
+		return fn(n.(float64)), nil
+	})
+}
+
+// the denominator of a big.Rat is positive, the euclidean division rounds toward -inf
+func ratFloor(r *big.Rat) *big.Int {
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+func ratCeiling(r *big.Rat) *big.Int {
+	return new(big.Int).Neg(ratFloor(new(big.Rat).Neg(r)))
+}
+
+func ratTruncate(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// halfway cases go to the even neighbour like math.RoundToEven
+func ratRound(r *big.Rat) *big.Int {
+	half := new(big.Rat).Add(r, big.NewRat(1, 2))
+	n := ratFloor(half)
+	if half.IsInt() && n.Bit(0) == 1 {
+		n.Sub(n, big.NewInt(1))
+	}
+	return n
+}
+
+func isRational(v interface{}) bool {
+	if f, ok := v.(float64); ok {
+		return !math.IsInf(f, 0) && !math.IsNaN(f)
+	}
+	return isExact(v)
+}
+
+func isOdd(n interface{}) bool {
+	switch x := n.(type) {
+	case int64:
+		return x%2 != 0
+	case *big.Int:
+		return x.Bit(0) == 1
+	}
+	return math.Mod(toFloat(n), 2) != 0
+}
+
+func numAbs(n interface{}) interface{} {
+	if numCompare("<", n, int64(0)) {
+		return numSub(int64(0), n)
+	}
+	return n
+}
+
+// (expt 2 100) and (expt 2 -1) -> 1/2 are exact, (expt 2 0.5) isn't
+func numExpt(base, power interface{}) (interface{}, error) {
+	if power == int64(0) {
+		return int64(1), nil
+	}
+	if e, ok := power.(int64); ok && isExact(base) {
+		if base == int64(0) && e < 0 {
+			return nil, divideByZeroError("expt")
+		}
+		var result interface{} = int64(1)
+		var square = base
+		for n := e; n != 0; n /= 2 {
+			if n%2 != 0 {
+				result = numMul(result, square)
+			}
+			if n/2 != 0 {
+				square = numMul(square, square)
+			}
+		}
+		if e < 0 {
+			return numDiv("expt", int64(1), result)
+		}
+		return result, nil
+	}
+	if f := toFloat(power); isNegative(base) && !isInteger(power) && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		// e.g. (expt -8 1/3)
+		return nil, complexError("expt", base)
+	}
+	return math.Pow(toFloat(base), toFloat(power)), nil
+}
+
+// exact square root of exact numbers whose numerator and denominator are perfect squares, e.g. (sqrt 16) -> 4
+func numSqrt(n interface{}) interface{} {
+	if isExact(n) && numCompare(">=", n, int64(0)) {
+		r := toRat(n)
+		num, den := new(big.Int).Sqrt(r.Num()), new(big.Int).Sqrt(r.Denom())
+		if root := new(big.Rat).SetFrac(num, den); new(big.Rat).Mul(root, root).Cmp(r) == 0 {
+			return normalizeRat(root)
+		}
+	}
+	return math.Sqrt(toFloat(n))
+}
+
+func gcd(a, b interface{}) interface{} {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok && x != math.MinInt64 && y != math.MinInt64 {
+			for y != 0 {
+				x, y = y, x%y
+			}
+			return numAbs(x)
+		}
+	}
+	if isExact(a) && isExact(b) {
+		x, y := toRat(a).Num(), toRat(b).Num()
+		return normalizeInt(new(big.Int).GCD(nil, nil, new(big.Int).Abs(x), new(big.Int).Abs(y)))
+	}
+	x, y := toFloat(a), toFloat(b)
+	for y != 0 {
+		x, y = y, math.Mod(x, y)
+	}
+	return math.Abs(x)
+}
+
+func lcm(a, b interface{}) interface{} {
+	if numCompare("=", a, int64(0)) || numCompare("=", b, int64(0)) {
+		return numMul(a, b)
+	}
+	quotient, _ := numDiv("lcm", a, gcd(a, b))
+	return numAbs(numMul(quotient, b))
+}
+
+// (gcd a b ...) and (lcm a b ...) are inexact when one of the arguments is
+func defineDivisors(name string, identity int64, combine func(a, b interface{}) interface{}) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		var acc interface{} = identity
+		for _, arg := range args {
+			if !isInteger(arg) {
+				return nil, TYPE_ERROR, contractError(name, "rational?", arg)
+			}
+			acc = combine(acc, arg)
+		}
+		return acc, TYPE_FLOAT64, nil
+	})
+}
+
+func init() {
+	builtins["pi"] = math.Pi
+	defineNumeric("abs", "real?", func(n interface{}) (interface{}, error) {
+		return numAbs(n), nil
+	})
+	defineNumeric("add1", "number?", func(n interface{}) (interface{}, error) {
+		return numAdd(n, int64(1)), nil
+	})
+	defineNumeric("sub1", "number?", func(n interface{}) (interface{}, error) {
+		return numSub(n, int64(1)), nil
+	})
+	for name, better := range map[string]string{"max": ">", "min": "<"} {
+		name, better := name, better
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			// the result is inexact when one of the arguments is, e.g. (max 1 2.0) -> 2.0
+			if err := checkArity(name, args, 1, -1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			exact := true
+			for _, arg := range args {
+				if !isNumber(arg) {
+					return nil, TYPE_ERROR, contractError(name, "real?", arg)
+				}
+				exact = exact && isExact(arg)
+			}
+			result := args[0]
+			for _, arg := range args[1:] {
+				if numCompare(better, arg, result) || math.IsNaN(toFloat(arg)) {
+					result = arg
+				}
+			}
+			if !exact {
+				result = toFloat(result)
+			}
+			return result, TYPE_FLOAT64, nil
+		})
+	}
+	defineBuiltin("expt", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("expt", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if !isNumber(arg) {
+				return nil, TYPE_ERROR, contractError("expt", "number?", arg)
+			}
+		}
+		result, err := numExpt(args[0], args[1])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return result, TYPE_FLOAT64, nil
+	})
+	defineInexact("exp", 0, 1, math.Exp)
+	defineBuiltin("log", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (log z) is the natural logarithm, (log z b) the logarithm in base b
+		if err := checkArity("log", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if !isNumber(arg) {
+				return nil, TYPE_ERROR, contractError("log", "number?", arg)
+			}
+		}
+		if args[0] == int64(0) {
+			return nil, TYPE_ERROR, &RacketError{Kind: ERR_DIVIDE_BY_ZERO, Proc: "log", Message: "undefined for 0"}
+		}
+		if args[0] == int64(1) {
+			return int64(0), TYPE_FLOAT64, nil
+		}
+		for _, arg := range args {
+			if isNegative(arg) {
+				return nil, TYPE_ERROR, complexError("log", arg)
+			}
+		}
+		result := math.Log(toFloat(args[0]))
+		if len(args) == 2 {
+			if args[1] == int64(1) {
+				return nil, TYPE_ERROR, &RacketError{Kind: ERR_DIVIDE_BY_ZERO, Proc: "log", Message: "undefined for base 1"}
+			}
+			result /= math.Log(toFloat(args[1]))
+		}
+		return result, TYPE_FLOAT64, nil
+	})
+	defineNumeric("sqrt", "number?", func(n interface{}) (interface{}, error) {
+		if isNegative(n) {
+			return nil, complexError("sqrt", n)
+		}
+		return numSqrt(n), nil
+	})
+	defineRounding("floor", math.Floor, ratFloor)
+	defineRounding("ceiling", math.Ceil, ratCeiling)
+	// halfway cases go to the even neighbour, (round 2.5) -> 2.0
+	defineRounding("round", math.RoundToEven, ratRound)
+	defineRounding("truncate", math.Trunc, ratTruncate)
+	defineNumeric("exact-round", "rational?", func(n interface{}) (interface{}, error) {
+		if r, ok := n.(*big.Rat); ok {
+			return normalizeInt(ratRound(r)), nil
+		} else if isExact(n) {
+			return n, nil
+		}
+		return normalizeRat(new(big.Rat).SetFloat64(math.RoundToEven(n.(float64)))), nil
+	})
+	defineInexact("sin", 0, 0, math.Sin)
+	defineInexact("cos", 0, 1, math.Cos)
+	defineInexact("tan", 0, 0, math.Tan)
+	defineBuiltin("atan", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (atan y x) is the angle of the point (x, y), in the right quadrant
+		if err := checkArity("atan", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if !isNumber(arg) {
+				return nil, TYPE_ERROR, contractError("atan", "number?", arg)
+			}
+		}
+		if len(args) == 1 {
+			if args[0] == int64(0) {
+				return int64(0), TYPE_FLOAT64, nil
+			}
+			return math.Atan(toFloat(args[0])), TYPE_FLOAT64, nil
+		}
+		if args[0] == int64(0) && args[1] == int64(0) {
+			return nil, TYPE_ERROR, &RacketError{Kind: ERR_DIVIDE_BY_ZERO, Proc: "atan", Message: "undefined for 0 and 0"}
+		}
+		if args[0] == int64(0) && numCompare(">", args[1], int64(0)) && isExact(args[1]) {
+			return int64(0), TYPE_FLOAT64, nil
+		}
+		return math.Atan2(toFloat(args[0]), toFloat(args[1])), TYPE_FLOAT64, nil
+	})
+	defineDivisors("gcd", 0, gcd)
+	defineDivisors("lcm", 1, lcm)
+	defineNumeric("exact->inexact", "number?", func(n interface{}) (interface{}, error) {
+		return toFloat(n), nil
+	})
+	defineNumeric("inexact->exact", "rational?", func(n interface{}) (interface{}, error) {
+		// floats are binary fractions, (inexact->exact 0.5) -> 1/2
+		if f, ok := n.(float64); ok {
+			return normalizeRat(new(big.Rat).SetFloat64(f)), nil
+		}
+		return n, nil
+	})
+
+	predicates := map[string]func(v interface{}) bool{
+		"number?":        isNumber,
+		"real?":          isNumber,
+		"integer?":       isInteger,
+		"exact-integer?": isExactInteger,
+		"exact-nonnegative-integer?": func(v interface{}) bool {
+			return isExactInteger(v) && numCompare(">=", v, int64(0))
+		},
+	}
+	for name, pred := range predicates {
+		name, pred := name, pred
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return pred(args[0]), TYPE_BOOLEAN, nil
+		})
+	}
+	numberPredicates := []struct {
+		name     string
+		expected string
+		pred     func(n interface{}) bool
+	}{
+		{"exact?", "number?", isExact},
+		{"inexact?", "number?", func(n interface{}) bool { return !isExact(n) }},
+		{"zero?", "number?", func(n interface{}) bool { return numCompare("=", n, int64(0)) }},
+		{"positive?", "real?", func(n interface{}) bool { return numCompare(">", n, int64(0)) }},
+		{"negative?", "real?", func(n interface{}) bool { return numCompare("<", n, int64(0)) }},
+		{"even?", "integer?", func(n interface{}) bool { return !isOdd(n) }},
+		{"odd?", "integer?", isOdd},
+	}
+	for _, np := range numberPredicates {
+		pred := np.pred
+		defineNumeric(np.name, np.expected, func(n interface{}) (interface{}, error) {
+			return pred(n), nil
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	p := newTestParams()
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(+ 1 2)", "3"},
+		{"(+ 1 2.0)", "3.0"},
+		{"(* 1.5 2)", "3.0"},
+		{"(/ 6 3)", "2"},
+		{"(/ 1 2)", "1/2"},
+		{"(/ 1 0.0)", "+inf.0"},
+		{"(- 0.0 (/ 1 0.0))", "-inf.0"},
+		{"(* 4294967296 4294967296)", "18446744073709551616"},
+		{"(= 1 1.0)", "#t"},
+		{"(eqv? 1 1.0)", "#f"},
+		{"(abs -3)", "3"},
+		{"(abs -3.5)", "3.5"},
+		{"(max 1 3 2)", "3"},
+		{"(max 1 2.0)", "2.0"},
+		{"(min 1 2.0)", "1.0"},
+		{"(expt 2 10)", "1024"},
+		{"(expt 2 -1)", "1/2"},
+		{"(expt 4 0.5)", "2.0"},
+		{"(expt 2.0 0)", "1"},
+		{"(expt -8 2.0)", "64.0"},
+		// the special and the exponent forms of inexact numbers read back as they are printed
+		{"(list +inf.0 -inf.0 +nan.0)", "'(+inf.0 -inf.0 +nan.0)"},
+		{"(list 1e10 1.5e-3 1e+21 .5 -.5)", "'(10000000000.0 0.0015 1e+21 0.5 -0.5)"},
+		{"(exact? 1e3)", "#f"},
+		{"(list (string->number \"+inf.0\") (string->number \"1e400\") (string->number \".5\"))", "'(+inf.0 +inf.0 0.5)"},
+		{"(exp 0)", "1"},
+		{"(exp 1)", "2.718281828459045"},
+		{"(log 1)", "0"},
+		{"(log 100 10)", "2.0"},
+		{"(sqrt 16)", "4"},
+		{"(sqrt 15)", "3.872983346207417"},
+		{"(sqrt 16.0)", "4.0"},
+		{"(sqrt 9223372030926249001)", "3037000499"},
+		{"(floor 2.5)", "2.0"},
+		{"(floor -2.5)", "-3.0"},
+		{"(ceiling 2.5)", "3.0"},
+		{"(truncate -2.5)", "-2.0"},
+		{"(round 2.5)", "2.0"},
+		{"(round 3.5)", "4.0"},
+		{"(round -2.5)", "-2.0"},
+		{"(round 7)", "7"},
+		{"(exact-round 2.5)", "2"},
+		{"(exact-round 2.6)", "3"},
+		{"(sin 0)", "0"},
+		{"(cos 0)", "1"},
+		{"(cos 0.0)", "1.0"},
+		{"(atan 1 1)", "0.7853981633974483"},
+		{"(atan 1 -1)", "2.356194490192345"},
+		{"(* 4 (atan 1))", "3.141592653589793"},
+		{"pi", "3.141592653589793"},
+		{"(gcd 12 18)", "6"},
+		{"(gcd -12 18 8)", "2"},
+		{"(gcd)", "0"},
+		{"(gcd 12.0 18)", "6.0"},
+		{"(lcm 4 6)", "12"},
+		{"(lcm -4 6 10)", "60"},
+		{"(lcm)", "1"},
+		{"(number? 1.5)", "#t"},
+		{"(number? 'a)", "#f"},
+		{"(integer? 2.0)", "#t"},
+		{"(integer? 2.5)", "#f"},
+		{"(integer? (/ 1 0.0))", "#f"},
+		{"(exact? 2)", "#t"},
+		{"(inexact? 2.0)", "#t"},
+		{"(exact->inexact 2)", "2.0"},
+		{"(inexact->exact 2.0)", "2"},
+		{"(zero? 0.0)", "#t"},
+		{"(positive? -1)", "#f"},
+		{"(negative? -1)", "#t"},
+		{"(even? 4)", "#t"},
+		{"(odd? 4.0)", "#f"},
+		{"(odd? -3)", "#t"},
+		{"(add1 1)", "2"},
+		{"(sub1 1.5)", "0.5"},
+		{"(string->number \"10\")", "10"},
+		{"(string->number \"1e3\")", "1000.0"},
+		{"(number->string 2.0)", "\"2.0\""},
+		{"(for/sum ([i 4]) i)", "6"},
+		{"(range 0 1 0.5)", "'(0 0.5)"},
+		// exact rationals and integers of any size
+		{"(/ 1 3)", "1/3"},
+		{"(exact? (/ 1 3))", "#t"},
+		{"(+ (/ 1 3) (/ 2 3))", "1"},
+		{"(* 3 1/3)", "1"},
+		{"(- 1/2)", "-1/2"},
+		{"(/ 4 -6)", "-2/3"},
+		{"(+ 1/3 0.5)", "0.8333333333333333"},
+		{"(< 1/3 0.34 1/2)", "#t"},
+		{"(= 1/2 0.5)", "#t"},
+		{"(integer? 1/2)", "#f"},
+		{"(list (floor -7/2) (ceiling -7/2) (truncate -7/2) (round -7/2) (round 5/2) (exact-round 7/3))", "'(-4 -3 -3 -4 2 2)"},
+		{"(sqrt 9/4)", "3/2"},
+		{"(expt 2/3 2)", "4/9"},
+		{"(expt 2 64)", "18446744073709551616"},
+		{"(expt -2 -3)", "-1/8"},
+		{"(* 99999999999 99999999999)", "9999999999800000000001"},
+		{"(- (expt 2 64) (expt 2 64))", "0"},
+		{"(exact-integer? (- (expt 2 64) 1))", "#t"},
+		{"(even? (expt 2 64))", "#t"},
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(- -9223372036854775808 1)", "-9223372036854775809"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"(exact->inexact (expt 2 64))", "18446744073709552000.0"},
+		{"(exact->inexact 1/4)", "0.25"},
+		{"(inexact->exact 0.25)", "1/4"},
+		{"(gcd (expt 2 70) (expt 6 30))", "1073741824"},
+		{"(sqrt (expt 10 40))", "100000000000000000000"},
+		{"(string->number \"-6/4\")", "-3/2"},
+		{"(string->number \"1/0\")", "#f"},
+		{"(number->string (expt 3 50))", "\"717897987691852588770249\""},
+		{"(list (eqv? (expt 2 64) (expt 2 64)) (equal? 1/2 (/ 2 4)) (eqv? 1/2 0.5))", "'(#t #t #f)"},
+		{"(hash-ref (hash (expt 2 64) 'big 1/2 'half) (* (expt 2 32) (expt 2 32)))", "'big"},
+		{"(hash-ref (hash (expt 2 64) 'big 1/2 'half) (/ 3 6))", "'half"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(/ 1.5 0)", "/: division by zero"},
+		{"(log 0)", "log: undefined for 0"},
+		{"(atan 0 0)", "atan: undefined for 0 and 0"},
+		{"(expt 0 -1)", "expt: division by zero"},
+		{"(sqrt -4)", "sqrt: complex numbers are not supported\n  given: -4"},
+		{"(log -1)", "log: complex numbers are not supported\n  given: -1"},
+		{"(log 8 -2)", "log: complex numbers are not supported\n  given: -2"},
+		{"(expt -8 1/3)", "expt: complex numbers are not supported\n  given: -8"},
+		{"(expt -8.0 0.5)", "expt: complex numbers are not supported\n  given: -8.0"},
+		{"(abs 'a)", "abs: contract violation\n  expected: real?\n  given: 'a"},
+		{"(even? 1.5)", "even?: contract violation\n  expected: integer?\n  given: 1.5"},
+		{"(exact-round (/ 1 0.0))", "exact-round: contract violation\n  expected: rational?\n  given: +inf.0"},
+		{"(max)", "max: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: at least 1\n  given: 0"},
+		{"(vector-ref (vector 1) 0.0)", "vector-ref: contract violation\n  expected: exact-nonnegative-integer?\n  given: 0.0"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package minrkt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// a file loaded by require, its forms are evaluated once per registry
+type module struct {
+	path string
+	// definitions of the module, separate from the ones of the session and of other modules
+	globals map[string]interface{}
+	// names bound by require, all-defined-out doesn't export them
+	imported map[string]bool
+	// bindings given by provide
+	exports map[string]interface{}
+	// set while the forms are evaluated, requiring the module again is a cycle
+	loading bool
+	// module which required it first, for the paths of cycle errors
+	parent *module
+}
+
+type moduleRegistry struct {
+	// instantiated modules by absolute path
+	modules map[string]*module
+}
+
+func newModuleRegistry() *moduleRegistry {
+	return &moduleRegistry{modules: make(map[string]*module)}
+}
+
+// racket and racket/... name the builtins and the prelude, requiring them changes nothing
+func isRacketLibrary(name string) bool {
+	return name == "racket" || strings.HasPrefix(name, "racket/")
+}
+
+// builtins of the library modules written in go, e.g. json, only (require json) binds them
+var libraries = make(map[string]map[string]interface{})
+
+func defineLibraryBuiltin(lib string, name string, accepted []string, fn keywordBuiltinFunc) {
+	if accepted != nil {
+		keywordBuiltins[name] = keywordBuiltin{accepted, fn}
+	}
+	if libraries[lib] == nil {
+		libraries[lib] = make(map[string]interface{})
+	}
+	libraries[lib][name] = builtinValue{name: name, fn: func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return fn(args, nil, p)
+	}}
+}
+
+// absolute path of the module "util.rkt", relative to the requiring module or to the current directory
+func resolveModulePath(path string, p Params) (string, error) {
+	if p.module != nil && !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.module.path), path)
+	}
+	full, err := p.files.resolve("require", path, currentDirectory(p), false)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(full)
+}
+
+// e.g. a.rkt -> b.rkt -> a.rkt when b.rkt requires a.rkt while a.rkt is loading
+func cycleError(path string, from *module) error {
+	paths := []string{path}
+	for m := from; m != nil; m = m.parent {
+		paths = append([]string{m.path}, paths...)
+		if m.path == path {
+			break
+		}
+	}
+	return &RacketError{Kind: ERR_FAIL, Proc: "require", Message: "cycle in loading",
+		Fields: []ErrorField{{"at path", path}, {"paths", strings.Join(paths, " -> ")}}}
+}
+
+// the forms of a module file, the first line can be #lang racket/base
+func parseModule(path string, src string) ([]Exp, error) {
+	if strings.HasPrefix(src, "#lang") {
+		line := src
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			// the newline is kept so that the lines of the forms don't change
+			line, src = src[:i], src[i:]
+		} else {
+			src = ""
+		}
+		if lang := strings.TrimSpace(strings.TrimPrefix(line, "#lang")); lang != "racket/base" && lang != "racket" {
+			return nil, newRacketError(ERR_SYNTAX, "require", "unsupported module language: %s\n  in module: %s", lang, path)
+		}
+	}
+	tokens, err := Tokenize(src)
+	if err == nil {
+		var forms []Exp
+		if forms, err = ParseProgram(tokens); err == nil {
+			return forms, nil
+		}
+	}
+	return nil, newRacketError(ERR_SYNTAX, "require", "%v\n  in module: %s", err, path)
+}
+
+// instantiate the module at the absolute path, or reuse it when it was already required
+func loadModule(path string, p Params) (*module, error) {
+	registry := p.modules
+	if registry == nil {
+		// the modules belong to a session, Params built by hand have none to put them in
+		return nil, newRacketError(ERR_FAIL, "require", "no module registry in this session\n  module path: %s", path)
+	}
+	if m, ok := registry.modules[path]; ok {
+		if m.loading {
+			return nil, cycleError(path, p.module)
+		}
+		return m, nil
+	}
+	var src []byte
+	var err error
+	if isPreludePath(path) {
+		src, err = preludeFiles.ReadFile(path)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fileError("require", "cannot open module file", path, err)
+	}
+	forms, err := parseModule(path, string(src))
+	if err != nil {
+		return nil, err
+	}
+	m := &module{path: path, globals: make(map[string]interface{}), imported: make(map[string]bool), loading: true, parent: p.module}
+	registry.modules[path] = m
+	// the forms see the ports and the settings of the session, not its definitions nor its calls
+	mp := p
+	mp.MapIdentifier, mp.CallStack, mp.Frames = m.globals, make([]map[string]interface{}, 1), nil
+	mp.cont, mp.generator = nil, nil
+	mp.modules, mp.module = registry, m
+	var provides []*ExpOperator
+	for _, form := range forms {
+		if op, ok := form.(*ExpOperator); ok && op.opeType == "provide" {
+			provides = append(provides, op)
+			continue
+		}
+		if _, _, err := form.Eval(mp); err != nil {
+			// a module which failed is loaded again by the next require
+			delete(registry.modules, path)
+			return nil, err
+		}
+	}
+	if m.exports, err = moduleExports(m, provides); err != nil {
+		delete(registry.modules, path)
+		return nil, err
+	}
+	m.loading = false
+	return m, nil
+}
+
+// bindings of (provide f (rename-out [g h]) (struct-out point) (all-defined-out))
+func moduleExports(m *module, provides []*ExpOperator) (map[string]interface{}, error) {
+	exports := make(map[string]interface{})
+	export := func(name string, as string) error {
+		v, ok := m.globals[name]
+		if !ok {
+			if v, ok = builtins[name]; !ok {
+				return syntaxError("provide", "provided identifier is not defined or required: %s", name)
+			}
+		}
+		exports[as] = v
+		return nil
+	}
+	for _, provide := range provides {
+		for _, spec := range provide.operands {
+			if id, ok := spec.(*ExpIdentifier); ok {
+				if err := export(id.val, id.val); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			op, ok := spec.(*ExpOperator)
+			if !ok || op.proc != nil {
+				return nil, syntaxError("provide", "bad provide spec: %s", strings.TrimSpace(spec.Print()))
+			}
+			switch op.opeType {
+			case "all-defined-out":
+				for name, v := range m.globals {
+					if !m.imported[name] {
+						exports[name] = v
+					}
+				}
+			case "rename-out":
+				for _, rename := range op.operands {
+					from, to, err := renamePair("rename-out", rename)
+					if err != nil {
+						return nil, err
+					}
+					if err := export(from, to); err != nil {
+						return nil, err
+					}
+				}
+			case "struct-out":
+				for _, operand := range op.operands {
+					id, ok := operand.(*ExpIdentifier)
+					if !ok {
+						return nil, syntaxError("struct-out", "expected an identifier for the structure type name")
+					}
+					st, ok := m.globals["struct:"+id.val].(*structType)
+					if !ok {
+						return nil, syntaxError("struct-out", "identifier is not bound to a structure type: %s", id.val)
+					}
+					for name := range structBindings(st) {
+						if v, ok := m.globals[name]; ok {
+							exports[name] = v
+						}
+					}
+				}
+			default:
+				return nil, syntaxError("provide", "bad provide spec: %s", strings.TrimSpace(spec.Print()))
+			}
+		}
+	}
+	return exports, nil
+}
+
+// [old new] of rename-out, rename-in and only-in
+func renamePair(form string, e Exp) (string, string, error) {
+	if op, ok := e.(*ExpOperator); ok && op.proc == nil && len(op.operands) == 1 {
+		if to, ok := op.operands[0].(*ExpIdentifier); ok {
+			return op.opeType, to.val, nil
+		}
+	}
+	return "", "", syntaxError(form, "expected [old-id new-id], given: %s", strings.TrimSpace(e.Print()))
+}
+
+// the bindings imported by a require spec: "util.rkt", (only-in spec id ...), (prefix-in p: spec) ...
+func requireSpec(spec Exp, p Params) (map[string]interface{}, error) {
+	switch s := spec.(type) {
+	case *ExpQuote:
+		rel, ok := s.val.(string)
+		if !ok || rel == "" {
+			break
+		}
+		path, err := resolveModulePath(rel, p)
+		if err != nil {
+			return nil, err
+		}
+		m, err := loadModule(path, p)
+		if err != nil {
+			return nil, err
+		}
+		bindings := make(map[string]interface{}, len(m.exports))
+		for name, v := range m.exports {
+			bindings[name] = v
+		}
+		return bindings, nil
+	case *ExpIdentifier:
+		if lib, ok := libraries[s.val]; ok {
+			bindings := make(map[string]interface{}, len(lib))
+			for name, v := range lib {
+				bindings[name] = v
+			}
+			return bindings, nil
+		}
+		if !isRacketLibrary(s.val) {
+			return nil, syntaxError("require", "unknown module: %s", s.val)
+		}
+		bindings, err := preludeBindings(p)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range builtins {
+			bindings[name] = v
+		}
+		return bindings, nil
+	case *ExpOperator:
+		if s.proc != nil || len(s.operands) == 0 {
+			break
+		}
+		form := s.opeType
+		nested := s.operands[0]
+		if form == "prefix-in" {
+			if len(s.operands) != 2 {
+				return nil, syntaxError(form, "bad syntax")
+			}
+			nested = s.operands[1]
+		}
+		inner, err := requireSpec(nested, p)
+		if err != nil {
+			return nil, err
+		}
+		switch form {
+		case "only-in", "rename-in":
+			bindings := make(map[string]interface{})
+			if form == "rename-in" {
+				bindings = inner
+			}
+			for _, operand := range s.operands[1:] {
+				from, to := "", ""
+				if id, ok := operand.(*ExpIdentifier); ok && form == "only-in" {
+					from, to = id.val, id.val
+				} else if from, to, err = renamePair(form, operand); err != nil {
+					return nil, err
+				}
+				v, ok := inner[from]
+				if !ok {
+					return nil, syntaxError(form, "identifier `%s' not included in nested require spec", from)
+				}
+				delete(bindings, from)
+				bindings[to] = v
+			}
+			return bindings, nil
+		case "except-in":
+			for _, operand := range s.operands[1:] {
+				id, ok := operand.(*ExpIdentifier)
+				if !ok {
+					return nil, syntaxError(form, "expected an identifier, given: %s", strings.TrimSpace(operand.Print()))
+				}
+				if _, ok := inner[id.val]; !ok {
+					return nil, syntaxError(form, "identifier `%s' not included in nested require spec", id.val)
+				}
+				delete(inner, id.val)
+			}
+			return inner, nil
+		case "prefix-in":
+			prefix, ok := s.operands[0].(*ExpIdentifier)
+			if !ok {
+				return nil, syntaxError(form, "expected an identifier for the prefix")
+			}
+			bindings := make(map[string]interface{}, len(inner))
+			for name, v := range inner {
+				bindings[prefix.val+name] = v
+			}
+			return bindings, nil
+		}
+	}
+	return nil, syntaxError("require", "bad require spec: %s", strings.TrimSpace(spec.Print()))
+}
+
+// (require spec ...) binds the provided names in the current module or session
+func evalRequire(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	for _, spec := range e.operands {
+		if lib, ok := spec.(*ExpIdentifier); ok && isRacketLibrary(lib.val) {
+			continue
+		}
+		bindings, err := requireSpec(spec, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for name, v := range bindings {
+			p.MapIdentifier[name] = v
+			if p.module != nil {
+				p.module.imported[name] = true
+			}
+		}
+	}
+	return void, TYPE_VOID, nil
+}
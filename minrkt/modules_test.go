@@ -0,0 +1,131 @@
+package minrkt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"util.rkt": `#lang racket/base
+; helpers
+(provide double (rename-out [triple times-three]) (struct-out point))
+(define secret 2)
+(define (double x) (* x secret))
+(define (triple x) (* x 3))
+(struct point (x y) #:transparent)
+(display "loading util")
+`,
+		"lib/math.rkt": `#lang racket/base
+(require "../util.rkt")
+(provide (all-defined-out))
+(define (quadruple x) (double (double x)))
+(define answer 42)
+`,
+		"a.rkt":    "#lang racket/base\n(require \"b.rkt\")\n(provide x)\n(define x 1)\n",
+		"b.rkt":    "#lang racket/base\n(require \"a.rkt\")\n(provide y)\n(define y 2)\n",
+		"bad.rkt":  "#lang racket/base\n(provide missing)\n",
+		"lang.rkt": "#lang typed/racket\n(define x 1)\n",
+	})
+	var out bytes.Buffer
+	in := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true}), WithOutput(&out))
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(require \"util.rkt\")", ""},
+		{"(double 5)", "10"},
+		{"(times-three 2)", "6"},
+		{"(point-x (point 1 2))", "1"},
+		{"(require (prefix-in m: \"lib/math.rkt\"))", ""},
+		{"(m:quadruple 1)", "4"},
+		{"m:answer", "42"},
+		{"(require (only-in \"lib/math.rkt\" [answer the-answer]))", ""},
+		{"the-answer", "42"},
+		{"(require (rename-in \"util.rkt\" [double twice]))", ""},
+		{"(twice 4)", "8"},
+		{"(require (except-in \"lib/math.rkt\" answer) racket/base)", ""},
+		{"(quadruple 2)", "8"},
+		// functions of a module see its definitions, the session doesn't
+		{"(with-handlers ([exn:fail:contract:variable? (lambda (e) 'unbound)]) secret)", "'unbound"},
+		{"(with-handlers ([exn:fail:contract:variable? (lambda (e) 'unbound)]) m:double)", "'unbound"},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+	// util.rkt is instantiated once even though it was required several times
+	if got := out.String(); got != "loading util" {
+		t.Errorf("expected util.rkt to be instantiated once but the output is %q", got)
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(require \"a.rkt\")", "require: cycle in loading\n  at path: " + filepath.Join(dir, "a.rkt") +
+			"\n  paths: " + filepath.Join(dir, "a.rkt") + " -> " + filepath.Join(dir, "b.rkt") + " -> " + filepath.Join(dir, "a.rkt")},
+		{"(require \"bad.rkt\")", "provide: provided identifier is not defined or required: missing"},
+		{"(require \"lang.rkt\")", "require: unsupported module language: typed/racket\n  in module: " + filepath.Join(dir, "lang.rkt")},
+		{"(require \"none.rkt\")", "require: cannot open module file\n  path: " + filepath.Join(dir, "none.rkt") + "\n  system error: no such file or directory"},
+		{"(require \"../outside.rkt\")", "require: `read' access denied for ../outside.rkt"},
+		{"(require (only-in \"util.rkt\" secret))", "only-in: identifier `secret' not included in nested require spec"},
+		{"(require srfi/1)", "require: unknown module: srfi/1"},
+		{"(provide x)", "provide: not at module level"},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
+
+func TestModuleRegistries(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"counter.rkt": "#lang racket/base\n(provide count)\n(define count (make-vector 1 0))\n(display \"loading counter\")\n",
+	})
+	// every session instantiates the modules it requires, its definitions are not seen by the others
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		in := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true}), WithOutput(&out))
+		if result, _, err := in.Eval("(begin (require \"counter.rkt\") (vector-set! count 0 (+ (vector-ref count 0) 1)) (vector-ref count 0))"); err != nil || FormatValue(result) != "1" {
+			t.Error("expected count of a new session is 1 but got", result, err)
+		}
+		if got := out.String(); got != "loading counter" {
+			t.Errorf("expected counter.rkt to be instantiated by session %d but the output is %q", i, got)
+		}
+	}
+
+	// Params built without a registry can't keep the modules they require
+	p := Params{MapIdentifier: make(map[string]interface{}), CallStack: make([]map[string]interface{}, 1), files: &FilePolicy{Root: dir, Read: true}}
+	_, _, err := evalLine(p, fmt.Sprintf("(require %q)", filepath.Join(dir, "counter.rkt")))
+	var re *RacketError
+	if !errors.As(err, &re) || re.Kind != ERR_FAIL {
+		t.Error("expected an exn:fail for a session without a module registry but got", err)
+	}
+}
@@ -0,0 +1,159 @@
+package minrkt
+
+import (
+	"fmt"
+)
+
+/*
+An expression returns zero or several values with (values v ...), they are
+passed along by the expressions in tail position, e.g. the body of a function,
+and received by call-with-values, define-values, let-values, let*-values and
+receive. Elsewhere only one value is expected, e.g. by the arguments of a call.
+*/
+
+// identifiers of (a b c), bound to the values in order
+func valuesFormals(form string, formals Exp) ([]string, error) {
+	list, ok := formals.(*ExpOperator)
+	if !ok {
+		return nil, syntaxError(form, "expected a list of identifiers")
+	}
+	var names []string
+	for _, element := range listElements(list) {
+		id, ok := element.(*ExpIdentifier)
+		if !ok || id.val == "." {
+			return nil, syntaxError(form, "expected a list of identifiers")
+		}
+		names = append(names, id.val)
+	}
+	return names, nil
+}
+
+func bindValues(form string, bindings map[string]interface{}, names []string, v interface{}) error {
+	values := ResultValues(v)
+	if len(values) != len(names) {
+		return resultArityError(form, fmt.Sprint(len(names)), len(values))
+	}
+	for i, name := range names {
+		bindings[name] = values[i]
+	}
+	return nil
+}
+
+// (define-values (id ...) expr)
+func evalDefineValues(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) != 2 {
+		return fail(syntaxError("define-values", "bad syntax"), p, k)
+	}
+	names, err := valuesFormals("define-values", e.operands[0])
+	if err != nil {
+		return fail(err, p, k)
+	}
+	return eval(e.operands[1], p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+		if err := bindValues("define-values", p.MapIdentifier, names, v); err != nil {
+			return fail(err, p, k)
+		}
+		return ret(nil, TYPE_DEFINE, k)
+	}))
+}
+
+// (let-values ([(id ...) expr] ...) body ...), let*-values sees the previous bindings
+func evalLetValues(e *ExpOperator, p *Params, k *kont) step {
+	form := e.opeType
+	if len(e.operands) < 2 {
+		return fail(syntaxError(form, "bad syntax"), p, k)
+	}
+	bindings, ok := e.operands[0].(*ExpOperator)
+	if !ok {
+		return fail(syntaxError(form, "expected a list of bindings"), p, k)
+	}
+	var formals [][]string
+	var exps []Exp
+	for _, b := range listElements(bindings) {
+		binding, ok := b.(*ExpOperator)
+		if !ok || binding.proc == nil || len(binding.operands) != 1 {
+			return fail(syntaxError(form, "bad binding"), p, k)
+		}
+		names, err := valuesFormals(form, binding.proc)
+		if err != nil {
+			return fail(err, p, k)
+		}
+		formals, exps = append(formals, names), append(exps, binding.operands[0])
+	}
+	body := newBody(e.operands[1:])
+	// the values of let-values are bound once they are all evaluated, every binding makes a new frame
+	var next func(i int, scope *Params, bound map[string]interface{}, k *kont) step
+	next = func(i int, scope *Params, bound map[string]interface{}, k *kont) step {
+		if i == len(exps) {
+			frame := p.extendFrame()
+			for name, v := range bound {
+				frame[name] = v
+			}
+			inner := p.withFrame(frame)
+			return eval(body, &inner, k)
+		}
+		return eval(exps[i], scope, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+			values := make(map[string]interface{})
+			if err := bindValues(form, values, formals[i], v); err != nil {
+				return fail(err, p, k)
+			}
+			if form == "let*-values" {
+				frame := scope.extendFrame()
+				for name, v := range values {
+					frame[name] = v
+				}
+				inner := scope.withFrame(frame)
+				return next(i+1, &inner, frame, k)
+			}
+			for name, v := range bound {
+				values[name] = v
+			}
+			return next(i+1, scope, values, k)
+		}))
+	}
+	return next(0, p, nil, k)
+}
+
+// (receive formals expr body ...) binds the values like the arguments of (lambda formals body ...)
+func evalReceive(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 3 {
+		return fail(syntaxError("receive", "bad syntax"), p, k)
+	}
+	params, err := formalsToParams("receive", e.operands[0])
+	if err != nil {
+		return fail(err, p, k)
+	}
+	fv, err := newFunction("receive", "", params, e.operands[2:], *p)
+	if err != nil {
+		return fail(err, p, k)
+	}
+	if len(fv.keywords) > 0 || len(fv.optionals) > 0 {
+		return fail(syntaxError("receive", "optional and keyword arguments are not allowed"), p, k)
+	}
+	return eval(e.operands[1], p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+		values := ResultValues(v)
+		if min, max := fv.arity(); len(values) < min || (max >= 0 && len(values) > max) {
+			return fail(resultArityError("receive", arityString(min, max), len(values)), p, k)
+		}
+		body, err := bindArguments(fv, values, nil, p.at(k))
+		if err != nil {
+			return fail(err, p, k)
+		}
+		return eval(fv.body, &body, k)
+	}))
+}
+
+func init() {
+	defineBuiltin("values", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		v, t := newValues(args)
+		return v, t, nil
+	})
+	defineNative("call-with-values", func(args []interface{}, p *Params, k *kont) step {
+		// (call-with-values producer consumer) calls consumer with the values of (producer)
+		if err := checkArity("call-with-values", args, 2, 2); err != nil {
+			return fail(err, p, k)
+		}
+		return applyStep(args[0], nil, nil, p, push(k, func(v interface{}, t TypeEnum, k *kont) step {
+			return applyStep(args[1], ResultValues(v), nil, p, k)
+		}))
+	})
+}
@@ -0,0 +1,74 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultipleValues(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define (split l) (values (car l) (cdr l)))")
+	evalLine(p, "(define-values (q r) (values (+ 1 2) (- 2 1)))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(values 1)", "1"},
+		{"(values 1 'a \"s\")", "1\n'a\n\"s\""},
+		{"(values)", ""},
+		{"(split '(1 2 3))", "1\n'(2 3)"},
+		{"(if #t (values 1 2) 3)", "1\n2"},
+		{"(begin (values 1 2) 3)", "3"},
+		{"(call-with-values (lambda () (values 1 2)) +)", "3"},
+		{"(call-with-values (lambda () (split '(a b))) list)", "'(a (b))"},
+		{"(call-with-values (lambda () 5) (lambda (x) (* x x)))", "25"},
+		{"(list q r)", "'(3 1)"},
+		{"(let-values ([(a b) (values 1 2)] [(c) (values 3)]) (list a b c))", "'(1 2 3)"},
+		{"(let ([a 10]) (let-values ([(a b) (values 1 2)] [(c) (values a)]) (list a b c)))", "'(1 2 10)"},
+		{"(let*-values ([(a b) (values 1 2)] [(c) (values (+ a b))]) (list a b c))", "'(1 2 3)"},
+		{"(let-values ([() (values)]) 'none)", "'none"},
+		{"(receive (a . rest) (values 1 2 3) (list a rest))", "'(1 (2 3))"},
+		{"(receive all (split '(1 2)) all)", "'(1 (2))"},
+		{"(let/ec k (k 1 2))", "1\n2"},
+		{"(call-with-values (lambda () (call/cc (lambda (k) (k 'a 'b)))) list)", "'(a b)"},
+		{"(for/fold ([sum 0] [prod 1]) ([i '(1 2 3 4)]) (values (+ sum i) (* prod i)))", "10\n24"},
+		{"(for/fold ([acc '()]) ([x '(1 2)]) (cons x acc))", "'(2 1)"},
+		{"(let ([g (generator () (yield 1 2) 'done)]) (call-with-values g list))", "'(1 2)"},
+		{"(match (call-with-values (lambda () (values 1 2)) list) [(list a b) (+ a b)])", "3"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+	if result, typ, _ := evalLine(p, "(values 1 2)"); typ != TYPE_VALUES || len(ResultValues(result)) != 2 {
+		t.Error("expected two values but got", ResultValues(result))
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(+ (values 1 2) 3)", "result arity mismatch;\n expected number of values not received\n  expected: 1\n  received: 2"},
+		{"(define x (values))", "result arity mismatch;\n expected number of values not received\n  expected: 1\n  received: 0"},
+		{"(map (lambda (x) (values x x)) '(1))", "result arity mismatch;\n expected number of values not received\n  expected: 1\n  received: 2"},
+		{"(define-values (a b) (values 1 2 3))", "define-values: result arity mismatch;\n expected number of values not received\n  expected: 2\n  received: 3"},
+		{"(let-values ([(a b) 1]) a)", "let-values: result arity mismatch;\n expected number of values not received\n  expected: 2\n  received: 1"},
+		{"(receive (a b . c) (values 1) a)", "receive: result arity mismatch;\n expected number of values not received\n  expected: at least 2\n  received: 1"},
+		{"(for/fold ([a 0] [b 0]) ([i 3]) i)", "for/fold: result arity mismatch;\n expected number of values not received\n  expected: 2\n  received: 1"},
+		{"(for/list ([i 2]) (values i i))", "result arity mismatch;\n expected number of values not received\n  expected: 1\n  received: 2"},
+		{"(call-with-values (lambda () (values 1 2)) (lambda (x) x))", "#<procedure>: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 1\n  given: 2"},
+		{"(define-values (a 1) 2)", "define-values: expected a list of identifiers"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
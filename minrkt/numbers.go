@@ -0,0 +1,277 @@
+package minrkt
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/*
+Numbers are exact or inexact like racket's. Exact integers are stored as int64
+while they fit, as *big.Int otherwise, and the other exact rationals, e.g.
+(/ 1 3), as *big.Rat: the results of exact operations are always in the
+smallest of these forms, so that values which are = have the same
+representation. Inexact reals are float64, like racket's flonums. Literals
+without a decimal point are exact. An operation with an inexact operand gives
+an inexact result.
+*/
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64, *big.Int, *big.Rat:
+		return true
+	}
+	return false
+}
+
+func isExact(v interface{}) bool {
+	switch v.(type) {
+	case int64, *big.Int, *big.Rat:
+		return true
+	}
+	return false
+}
+
+func isExactInteger(v interface{}) bool {
+	switch v.(type) {
+	case int64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// integer? is true for inexact integers too, e.g. 2.0
+func isInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case int64, *big.Int:
+		return true
+	case float64:
+		return n == math.Trunc(n) && !math.IsInf(n, 0)
+	}
+	return false
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f
+	case *big.Rat:
+		f, _ := n.Float64()
+		return f
+	}
+	return math.NaN()
+}
+
+// the exact number as a fraction, the big values are never modified so they can be shared
+func toRat(v interface{}) *big.Rat {
+	switch n := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n)
+	case *big.Int:
+		return new(big.Rat).SetInt(n)
+	case *big.Rat:
+		return n
+	}
+	return nil
+}
+
+// the smallest representation of an exact integer
+func normalizeInt(n *big.Int) interface{} {
+	if n.IsInt64() {
+		return n.Int64()
+	}
+	return n
+}
+
+// the smallest representation of an exact number, integers aren't fractions
+func normalizeRat(r *big.Rat) interface{} {
+	if r.IsInt() {
+		return normalizeInt(new(big.Int).Set(r.Num()))
+	}
+	return r
+}
+
+// the value of a numeric literal, exact unless it has a decimal point or an exponent, e.g. 12345678901234567890 or 1/3,
+// +inf.0, -inf.0 and +nan.0 are the special inexact numbers
+func literalNumber(text string, num float64) interface{} {
+	switch text {
+	case "+inf.0":
+		return math.Inf(1)
+	case "-inf.0":
+		return math.Inf(-1)
+	case "+nan.0", "-nan.0":
+		return math.NaN()
+	}
+	if !strings.ContainsAny(text, ".eE") {
+		text = strings.TrimPrefix(text, "+")
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+		if r, ok := new(big.Rat).SetString(text); ok {
+			return normalizeRat(r)
+		}
+	}
+	return num
+}
+
+func numAdd(a, b interface{}) interface{} {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if s := x + y; (s > x) == (y > 0) {
+				return s
+			}
+		}
+	}
+	if isExact(a) && isExact(b) {
+		return normalizeRat(new(big.Rat).Add(toRat(a), toRat(b)))
+	}
+	return toFloat(a) + toFloat(b)
+}
+
+func numSub(a, b interface{}) interface{} {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if d := x - y; (d < x) == (y > 0) {
+				return d
+			}
+		}
+	}
+	if isExact(a) && isExact(b) {
+		return normalizeRat(new(big.Rat).Sub(toRat(a), toRat(b)))
+	}
+	return toFloat(a) - toFloat(b)
+}
+
+func numMul(a, b interface{}) interface{} {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			if x == 0 || y == 0 {
+				return int64(0)
+			}
+			if m := x * y; m/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64) {
+				return m
+			}
+		}
+	}
+	if isExact(a) && isExact(b) {
+		return normalizeRat(new(big.Rat).Mul(toRat(a), toRat(b)))
+	}
+	return toFloat(a) * toFloat(b)
+}
+
+// dividing by an exact 0 is an error, dividing by 0.0 gives an infinity like racket
+func numDiv(proc string, a, b interface{}) (interface{}, error) {
+	if y, ok := b.(int64); ok {
+		if y == 0 {
+			return nil, divideByZeroError(proc)
+		}
+		if x, ok := a.(int64); ok && x%y == 0 && !(x == math.MinInt64 && y == -1) {
+			return x / y, nil
+		}
+	}
+	if isExact(a) && isExact(b) {
+		return normalizeRat(new(big.Rat).Quo(toRat(a), toRat(b))), nil
+	}
+	return toFloat(a) / toFloat(b), nil
+}
+
+// comparisons of exact numbers don't go through float64, which would lose precision
+func numCompare(op string, a, b interface{}) bool {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			switch op {
+			case "<":
+				return x < y
+			case "<=":
+				return x <= y
+			case "=":
+				return x == y
+			case ">=":
+				return x >= y
+			case ">":
+				return x > y
+			}
+		}
+	}
+	if isExact(a) && isExact(b) {
+		c := toRat(a).Cmp(toRat(b))
+		switch op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case "=":
+			return c == 0
+		case ">=":
+			return c >= 0
+		case ">":
+			return c > 0
+		}
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case "=":
+		return x == y
+	case ">=":
+		return x >= y
+	case ">":
+		return x > y
+	}
+	return false
+}
+
+// racket prints inexact numbers with a decimal point, e.g. 2.0, +inf.0 or 1e+21, and exact fractions like 1/3
+func formatNumber(v interface{}) string {
+	var f float64
+	switch n := v.(type) {
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case *big.Int:
+		return n.String()
+	case *big.Rat:
+		return n.RatString()
+	case float64:
+		f = n
+	}
+	switch {
+	case math.IsNaN(f):
+		return "+nan.0"
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	}
+	if abs := math.Abs(f); abs >= 1e21 || (abs < 1e-4 && abs != 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func numberArg(proc string, arg interface{}) (interface{}, error) {
+	if !isNumber(arg) {
+		return nil, contractError(proc, "number?", arg)
+	}
+	return arg, nil
+}
+
+func naturalArg(proc string, arg interface{}) (int, error) {
+	n, ok := arg.(int64)
+	if !ok || n < 0 {
+		return 0, contractError(proc, "exact-nonnegative-integer?", arg)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,208 @@
+package minrkt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+/*
+Parameters hold the ambient settings of programs, e.g. the current output port.
+(make-parameter v) creates one, calling it without argument gives its value and
+with one argument changes it. (parameterize ([param v] ...) body ...) gives
+parameters new values while the body is evaluated. The bindings of parameterize
+are part of Params, which is passed by value, so they end with the body however
+it returns, by raising an exception or by jumping to a continuation too, and a
+goroutine evaluating with a copy of Params sees the bindings it was started with.
+*/
+
+// procedure created by make-parameter
+type parameter struct {
+	name string
+	// value outside of parameterize, in sessions which don't have their own
+	cell *parameterCell
+	// converts the values given to the parameter, nil when they are kept as they are
+	guard func(v interface{}, p Params) (interface{}, error)
+}
+
+type parameterCell struct {
+	value interface{}
+}
+
+// bindings of parameterize, the innermost one first
+type parameterization struct {
+	param  *parameter
+	cell   *parameterCell
+	parent *parameterization
+	// set instead of a binding below the ones of a generator body, which sees the bindings of the call resuming it
+	resumed *generatorRun
+}
+
+func newParameter(name string, value interface{}, guard func(v interface{}, p Params) (interface{}, error)) *parameter {
+	return &parameter{name: name, cell: &parameterCell{value: value}, guard: guard}
+}
+
+// the cell of the innermost binding of param, changing it changes the value of the parameter
+func (p Params) cell(param *parameter) *parameterCell {
+	for b := p.parameters; b != nil; b = b.parent {
+		if b.resumed != nil {
+			b = &parameterization{parent: b.resumed.parameters}
+		} else if b.param == param {
+			return b.cell
+		}
+	}
+	return param.cell
+}
+
+func (p Params) parameterValue(param *parameter) interface{} {
+	return p.cell(param).value
+}
+
+// Params where param is bound to v, which was already given to its guard
+func (p Params) parameterize(param *parameter, v interface{}) Params {
+	p.parameters = &parameterization{param: param, cell: &parameterCell{value: v}, parent: p.parameters}
+	return p
+}
+
+func (param *parameter) convert(v interface{}, p Params) (interface{}, error) {
+	if param.guard == nil {
+		return v, nil
+	}
+	return param.guard(v, p)
+}
+
+// (param) is the value of the parameter, (param v) changes it
+func applyParameter(param *parameter, args []interface{}, p Params) (interface{}, TypeEnum, error) {
+	if err := checkArity(param.name, args, 0, 1); err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	cell := p.cell(param)
+	if len(args) == 0 {
+		return cell.value, typeOf(cell.value), nil
+	}
+	v, err := param.convert(args[0], p)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	cell.value = v
+	return void, TYPE_VOID, nil
+}
+
+// (parameterize ([param expr] ...) body ...), the parameters and the values are evaluated before any is bound
+func evalParameterize(e *ExpOperator, p *Params, k *kont) step {
+	if len(e.operands) < 2 {
+		return fail(syntaxError("parameterize", "bad syntax"), p, k)
+	}
+	bindings, ok := e.operands[0].(*ExpOperator)
+	if !ok {
+		return fail(syntaxError("parameterize", "expected a list of bindings"), p, k)
+	}
+	body := p.at(k)
+	for _, b := range listElements(bindings) {
+		binding, ok := b.(*ExpOperator)
+		if !ok {
+			return fail(syntaxError("parameterize", "bad binding"), p, k)
+		}
+		elements := listElements(binding)
+		if len(elements) != 2 {
+			return fail(syntaxError("parameterize", "bad binding"), p, k)
+		}
+		v, _, err := evalSingle(elements[0], p.at(k))
+		if err != nil {
+			return fail(err, p, k)
+		}
+		param, ok := v.(*parameter)
+		if !ok {
+			return fail(contractError("parameterize", "parameter?", v), p, k)
+		}
+		if v, _, err = evalSingle(elements[1], p.at(k)); err != nil {
+			return fail(err, p, k)
+		}
+		if v, err = param.convert(v, p.at(k)); err != nil {
+			return fail(err, p, k)
+		}
+		body = body.parameterize(param, v)
+	}
+	return eval(newBody(e.operands[1:]), &body, k)
+}
+
+var outputPortParameter = newParameter("current-output-port", stdoutPort, func(v interface{}, p Params) (interface{}, error) {
+	if _, ok := v.(*outputPort); !ok {
+		return nil, contractError("current-output-port", "output-port?", v)
+	}
+	return v, nil
+})
+
+var inputPortParameter = newParameter("current-input-port", stdinPort, func(v interface{}, p Params) (interface{}, error) {
+	if _, ok := v.(*inputPort); !ok {
+		return nil, contractError("current-input-port", "input-port?", v)
+	}
+	return v, nil
+})
+
+// directory of the relative paths given to the file functions
+var currentDirectoryParameter = newParameter("current-directory", workingDirectory(), nil)
+
+// the directories given to current-directory must exist, relative ones are resolved against the current one
+func changeDirectory(v interface{}, p Params) (interface{}, error) {
+	path, err := pathArg("current-directory", v)
+	if err != nil {
+		return nil, err
+	}
+	full, err := p.files.resolve("current-directory", path, currentDirectory(p), false)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(full); err != nil {
+		return nil, fileError("current-directory", "unable to switch to directory", path, err)
+	} else if !info.IsDir() {
+		return nil, fileError("current-directory", "unable to switch to directory", path, errors.New("not a directory"))
+	}
+	return filepath.Clean(full), nil
+}
+
+// the working directory of the process, relative paths are kept as they are when it's unknown
+func workingDirectory() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+func currentDirectory(p Params) string {
+	return p.parameterValue(currentDirectoryParameter).(string)
+}
+
+func init() {
+	builtins["current-output-port"] = outputPortParameter
+	builtins["current-input-port"] = inputPortParameter
+	builtins["current-directory"] = currentDirectoryParameter
+	currentDirectoryParameter.guard = changeDirectory
+
+	defineBuiltin("make-parameter", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (make-parameter v [guard]), the guard converts the values given later but not v
+		if err := checkArity("make-parameter", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		param := newParameter("parameter-procedure", args[0], nil)
+		if len(args) == 2 {
+			guard := args[1]
+			if !isProcedure(guard) {
+				return nil, TYPE_ERROR, contractError("make-parameter", "(any/c . -> . any)", guard)
+			}
+			param.guard = func(v interface{}, p Params) (interface{}, error) {
+				v, _, err := applySingle(guard, []interface{}{v}, p)
+				return v, err
+			}
+		}
+		return param, TYPE_PROCEDURE, nil
+	})
+	defineBuiltin("parameter?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("parameter?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*parameter)
+		return ok, TYPE_BOOLEAN, nil
+	})
+}
@@ -0,0 +1,124 @@
+package minrkt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParameters(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define p (make-parameter 1))")
+	evalLine(p, "(define q (make-parameter 1 (lambda (x) (* x 10))))")
+	evalLine(p, "(define get-p (parameterize ([p 7]) (lambda () (p))))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(p)", "1"},
+		{"(parameterize ([p 2]) (p))", "2"},
+		{"(list (parameterize ([p 2]) (parameterize ([p 3]) (p))) (p))", "'(3 1)"},
+		{"(parameterize ([p 2] [q 3]) (list (p) (q)))", "'(2 30)"},
+		{"(parameterize ([p 2]) (p 3) (p))", "3"},
+		{"(p)", "1"},
+		{"(begin (p 5) (p))", "5"},
+		{"(begin (p 1) (q 2) (q))", "20"},
+		// the value of a parameter is the one of the caller, not of the lambda
+		{"(get-p)", "1"},
+		{"(parameterize ([p 4]) (get-p))", "4"},
+		{"(with-handlers ([exn:fail? (lambda (e) (p))]) (parameterize ([p 10]) (error \"boom\")))", "1"},
+		{"(list (let/ec k (parameterize ([p 20]) (k (p)))) (p))", "'(20 1)"},
+		{"(list (parameter? p) (parameter? current-output-port) (parameter? car) (procedure? p))", "'(#t #t #f #t)"},
+		{"p", "#<procedure:parameter-procedure>"},
+		{"current-output-port", "#<procedure:current-output-port>"},
+		{"(parameterize ([current-output-port (open-output-string)]) (display \"hidden\") (get-output-string (current-output-port)))", "\"hidden\""},
+		{"(let ([out (open-output-string)]) (parameterize ([current-output-port out]) (printf \"~a!\" (p))) (get-output-string out))", "\"1!\""},
+		{"(with-output-to-string (lambda () (display (output-port? (current-output-port)))))", "\"#t\""},
+		{"(parameterize ([current-input-port (open-input-string \"a b\")]) (read))", "'a"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(parameterize ([car 1]) 1)", "parameterize: contract violation\n  expected: parameter?\n  given: #<procedure:car>"},
+		{"(parameterize ([p]) 1)", "parameterize: bad binding"},
+		{"(parameterize ([current-output-port 5]) 1)", "current-output-port: contract violation\n  expected: output-port?\n  given: 5"},
+		{"(current-pseudo-random-generator 'g)", "current-pseudo-random-generator: contract violation\n  expected: pseudo-random-generator?\n  given: 'g"},
+		{"(p 1 2)", "parameter-procedure: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 0 to 1\n  given: 2"},
+		{"(make-parameter 1 2)", "make-parameter: contract violation\n  expected: (any/c . -> . any)\n  given: 2"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
+
+func TestParameters_Interpreter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := NewInterpreter(WithFilePolicy(FilePolicy{Root: dir, Read: true, Write: true}), WithOutput(&out))
+	other := NewInterpreter(WithOutput(&out))
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(current-directory)", strconv.Quote(dir)},
+		{"(parameterize ([current-directory \"sub\"]) (with-output-to-file \"x.txt\" (lambda () (display 1))) (current-directory))", strconv.Quote(filepath.Join(dir, "sub"))},
+		{"(list (file-exists? \"x.txt\") (file-exists? \"sub/x.txt\"))", "'(#f #t)"},
+		{"(begin (current-directory \"sub\") (file-exists? \"x.txt\"))", "#t"},
+		{"(begin (current-directory \"..\") (current-directory))", strconv.Quote(dir)},
+		// the builtin parameters of a session are its own
+		{"(current-output-port (open-output-string))", ""},
+		{"(random-seed 1)", ""},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+	if _, _, err := other.Eval("(display \"shown\")"); err != nil || out.String() != "shown" {
+		t.Error("expected the output of the other session to be shown but got", out.String(), err)
+	}
+	if v, _, _ := in.Eval("(begin (display \"hidden\") (get-output-string (current-output-port)))"); v != "hidden" {
+		t.Error("expected the session to print to its string port but got", v)
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(current-directory \"missing\")", "current-directory: unable to switch to directory\n  path: missing\n  system error: no such file or directory"},
+		{"(current-directory \"..\")", "current-directory: `read' access denied for .."},
+		{"(current-directory 5)", "current-directory: contract violation\n  expected: path-string?\n  given: 5"},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
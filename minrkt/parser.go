@@ -2,41 +2,123 @@ package minrkt
 
 import (
 	"fmt"
+	"math/big"
+	"strconv"
 )
 
-// will be the value of MapIdentifier if the key is a function name
+// will be the value of MapIdentifier if the key is a function name,
+// lambda expressions also evaluate to it
 type functionValue struct {
+	name string
+	// required arguments
 	args []string
-	body Exp
+	// (define (f a [b 10]) ...)
+	optionals []optionalArg
+	// (define (f a . rest) ...), empty when there are no rest arguments
+	rest string
+	// (define (f #:scale [scale 1]) ...)
+	keywords []keywordArg
+	body     Exp
+	// local variables visible where the function was created
+	env map[string]interface{}
+	// global definitions of the module or session where the function was created
+	globals map[string]interface{}
+	// position of the define or lambda expression, shown in stack traces
+	line int
+	col  int
+}
+
+type optionalArg struct {
+	name       string
+	defaultExp Exp
+}
+
+type keywordArg struct {
+	keyword string
+	name    string
+	// nil when the keyword argument is required
+	defaultExp Exp
+}
+
+// value of case-lambda, the first clause accepting the number of arguments is called
+type caseLambdaValue struct {
+	name    string
+	clauses []functionValue
 }
 
 type Params struct {
 	MapIdentifier map[string]interface{}
-	CallStack     []map[string]interface{}
+	// local variables, only the innermost frame is looked up
+	CallStack []map[string]interface{}
+	// functions being called, the innermost one is the last
+	Frames []StackFrame
+	// continuation of the innermost call, a call given the same continuation is in tail position
+	tail *kont
+	// frames waiting for the value being evaluated, go code evaluating expressions continues them
+	cont *kont
+	// generator whose body is evaluated, for yield
+	generator *generatorRun
+	// modules instantiated by require, shared by the modules of a session
+	modules *moduleRegistry
+	// module whose forms are evaluated, nil outside of modules
+	module *module
+	// bindings of parameterize, parameters have their default value when they aren't bound
+	parameters *parameterization
+	// files that can be opened, no restriction when nil
+	files *FilePolicy
+	// only the builtins written in go are defined
+	noPrelude bool
 }
 
 type TypeEnum int
 
 const (
-	TYPE_FLOAT64 TypeEnum = iota
+	TYPE_FLOAT64 TypeEnum = iota // numbers, exact ones are int64, *big.Int or *big.Rat
 	TYPE_BOOLEAN
 	TYPE_ERROR
 	TYPE_DEFINE
 	TYPE_NOTIFICATION
+	TYPE_PROCEDURE
+	TYPE_LIST
+	TYPE_SYMBOL
+	TYPE_VOID
+	TYPE_KEYWORD
+	TYPE_STRING
+	TYPE_EXCEPTION
+	TYPE_PROMPT_TAG
+	TYPE_PROMISE
+	TYPE_STREAM
+	TYPE_VECTOR
+	TYPE_HASH
+	TYPE_STRUCT
+	TYPE_PORT
+	TYPE_EOF
+	TYPE_RANDOM_GENERATOR
+	TYPE_CHAR
+	TYPE_REGEXP
+	TYPE_VALUES // zero or several values of (values v ...)
 )
 
 type Exp interface {
 	Eval(p Params) (interface{}, TypeEnum, error)
 	Print() string
+	// the step evaluating the expression in the continuation k, see machine.go
+	compute(p *Params, k *kont) step
 }
 
 type ExpOperator struct {
 	opeType  string
 	operands []Exp
+	// expression evaluating to the procedure when the head isn't a name, e.g. ((lambda (x) x) 1)
+	proc Exp
+	// position of the left parenthesis
+	line int
+	col  int
 }
 
 type ExpNum struct {
-	val float64
+	// int64, *big.Int or *big.Rat for exact numbers, float64 otherwise
+	val interface{}
 }
 
 type ExpBool struct {
@@ -51,12 +133,30 @@ type ExpIdentifier struct {
 type ExpFunction struct {
 }
 
+// quoted datum, e.g. '(1 2 3) or 'x
+type ExpQuote struct {
+	val interface{}
+}
+
+// keyword of keyword arguments, e.g. #:scale in (f 1 #:scale 2)
+type ExpKeyword struct {
+	val string
+}
+
 func newExpOperator(ope string) *ExpOperator {
 	return &ExpOperator{opeType: ope}
 }
 
-func newExpNum(num float64) *ExpNum {
-	return &ExpNum{val: num}
+// forms needing more than an operator are nodes of their own, e.g. match keeps its compiled clauses
+func formExp(e *ExpOperator) Exp {
+	if e.opeType == "match" {
+		return &ExpMatch{ExpOperator: e}
+	}
+	return e
+}
+
+func newExpNum(token Token) *ExpNum {
+	return &ExpNum{val: literalNumber(token.val, token.num)}
 }
 
 func newExpBool(val bool) *ExpBool {
@@ -67,30 +167,72 @@ func newExpIdentifier(val string) *ExpIdentifier {
 	return &ExpIdentifier{val: val}
 }
 
-var idx = 0
+func newExpQuote(val interface{}) *ExpQuote {
+	return &ExpQuote{val: val}
+}
+
+func newExpKeyword(val string) *ExpKeyword {
+	return &ExpKeyword{val: val}
+}
+
+// tokens being parsed and the index of the next one, each call of Parse has its own
+type parser struct {
+	tokens []Token
+	idx    int
+}
 
 func Parse(tokens []Token) (Exp, error) {
-	idx = 0
+	ps := &parser{tokens: tokens}
 	if len(tokens) == 0 {
 		return nil, fmt.Errorf("empty expression, you should input an expression")
 	}
 	// the initial token for expression should be a number, ( , true or false
 	if len(tokens) == 1 {
 		if tokens[0].tokenType == TOK_NUM {
-			return newExpNum(tokens[idx].num), nil
+			return newExpNum(tokens[0]), nil
 		} else if tokens[0].tokenType == TOK_TRUE {
 			return newExpBool(true), nil
 		} else if tokens[0].tokenType == TOK_FALSE {
 			return newExpBool(false), nil
 		} else if tokens[0].tokenType == TOK_IDENTIFIER {
 			return newExpIdentifier(tokens[0].val), nil
+		} else if tokens[0].tokenType == TOK_STRING {
+			return buildString(tokens[0])
+		} else if tokens[0].tokenType == TOK_CHAR {
+			return buildChar(tokens[0])
+		} else if tokens[0].tokenType == TOK_REGEXP {
+			return buildRegexp(tokens[0])
 		} else {
 			return nil, fmt.Errorf("for expression with single length, the token should be number ,true or false")
 		}
+	} else if tokens[0].tokenType == TOK_QUOTE || tokens[0].tokenType == TOK_VECTOR {
+		var datum interface{}
+		var err error
+		if tokens[0].tokenType == TOK_QUOTE {
+			datum, err = ps.buildQuotedDatum()
+		} else {
+			// vector literals are quoted like racket's #(1 2)
+			datum, err = ps.buildDatum()
+		}
+		if err != nil {
+			return nil, err
+		} else if ps.idx != len(tokens) {
+			return nil, fmt.Errorf("there shouldn't have any expression outside the quoted datum")
+		} else {
+			return newExpQuote(datum), nil
+		}
+	} else if tokens[0].tokenType == TOK_QUASIQUOTE {
+		if exp, err := ps.buildQuasiquote(); err != nil {
+			return nil, err
+		} else if ps.idx != len(tokens) {
+			return nil, fmt.Errorf("there shouldn't have any expression outside the quasiquoted datum")
+		} else {
+			return exp, nil
+		}
 	} else if tokens[0].tokenType == TOK_LPAREN {
-		if root, err := buildPasedTree(tokens); err != nil {
+		if root, err := ps.buildPasedTree(); err != nil {
 			return nil, err
-		} else if idx != len(tokens) {
+		} else if ps.idx != len(tokens) {
 			return nil, fmt.Errorf("there shouldn't have any expression outside paired parentheses")
 		} else {
 			return root, nil
@@ -100,6 +242,63 @@ func Parse(tokens []Token) (Exp, error) {
 	}
 }
 
+// ParseProgram parses the top-level forms of a file, e.g. the definitions of a module
+func ParseProgram(tokens []Token) ([]Exp, error) {
+	ps := &parser{tokens: tokens}
+	var forms []Exp
+	for ps.idx < len(tokens) && tokens[ps.idx].tokenType != TOK_EOF {
+		form, err := ps.buildForm()
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+	return forms, nil
+}
+
+// the expression starting at the next token
+func (ps *parser) buildForm() (Exp, error) {
+	token := ps.tokens[ps.idx]
+	switch token.tokenType {
+	case TOK_LPAREN:
+		return ps.buildPasedTree()
+	case TOK_QUOTE, TOK_VECTOR:
+		var datum interface{}
+		var err error
+		if token.tokenType == TOK_QUOTE {
+			datum, err = ps.buildQuotedDatum()
+		} else {
+			datum, err = ps.buildDatum()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newExpQuote(datum), nil
+	case TOK_QUASIQUOTE:
+		return ps.buildQuasiquote()
+	}
+	ps.idx++
+	switch token.tokenType {
+	case TOK_NUM:
+		return newExpNum(token), nil
+	case TOK_TRUE:
+		return newExpBool(true), nil
+	case TOK_FALSE:
+		return newExpBool(false), nil
+	case TOK_IDENTIFIER:
+		return newExpIdentifier(token.val), nil
+	case TOK_STRING:
+		return buildString(token)
+	case TOK_CHAR:
+		return buildChar(token)
+	case TOK_REGEXP:
+		return buildRegexp(token)
+	case TOK_KEYWORD:
+		return newExpKeyword(token.val), nil
+	}
+	return nil, fmt.Errorf("unexpected %s", token.val)
+}
+
 func isOperator(tokenType TokenType) bool {
 	if tokenType == TOK_ADD || tokenType == TOK_SUB || tokenType == TOK_MUL || tokenType == TOK_DIV ||
 		tokenType == TOK_AND || tokenType == TOK_OR || tokenType == TOK_NOT || tokenType == TOK_LARGE ||
@@ -114,46 +313,334 @@ func isOperator(tokenType TokenType) bool {
 Each time we encounter (, invoke the buildPasedTree() to construct the expression
 wrapped by the parentheses
 */
-func buildPasedTree(tokens []Token) (Exp, error) {
-	idx++ // initially the token is (, we don't need to check anymore
-	if idx >= len(tokens) {
+func (ps *parser) buildPasedTree() (Exp, error) {
+	lparen := ps.tokens[ps.idx]
+	ps.idx++ // initially the token is (, we don't need to check anymore
+	if ps.idx >= len(ps.tokens) {
 		return nil, fmt.Errorf("expression end too early")
 	}
-	// note first identifier after ( can be function name, e.g (addx x)
-	if !isOperator(tokens[idx].tokenType) && tokens[idx].tokenType != TOK_IDENTIFIER {
+	var root *ExpOperator
+	if ps.tokens[ps.idx].tokenType == TOK_LPAREN {
+		// the procedure is computed by an expression, e.g ((lambda (x) x) 1)
+		if proc, err := ps.buildPasedTree(); err != nil {
+			return nil, err
+		} else {
+			root = &ExpOperator{proc: proc}
+		}
+	} else if ps.tokens[ps.idx].tokenType == TOK_KEYWORD {
+		// list of arguments starting with a keyword argument, e.g. (lambda (#:scale s) ...)
+		root = &ExpOperator{proc: newExpKeyword(ps.tokens[ps.idx].val)}
+		ps.idx++
+	} else if isLiteral(ps.tokens[ps.idx].tokenType) && ps.idx > 1 {
+		// a nested list can start with a literal, e.g. the clause [1 'one] of match
+		if head, err := ps.buildForm(); err != nil {
+			return nil, err
+		} else {
+			root = &ExpOperator{proc: head}
+		}
+	} else if ps.tokens[ps.idx].tokenType == TOK_RPAREN && ps.idx > 1 {
+		// () is only allowed inside another expression, e.g. (lambda () 1)
+		ps.idx++
+		return newExpOperator(""), nil
+	} else if !isOperator(ps.tokens[ps.idx].tokenType) && ps.tokens[ps.idx].tokenType != TOK_IDENTIFIER {
+		// note first identifier after ( can be function name, e.g (addx x)
 		return nil, fmt.Errorf("left parentheses should always followed by an operator")
+	} else {
+		root = newExpOperator(ps.tokens[ps.idx].val)
+		ps.idx++
 	}
-	root := newExpOperator(tokens[idx].val)
-	idx++
-	// quickly check the token after operator is not an operator
-	if idx < len(tokens) && isOperator(tokens[idx].tokenType) {
-		fmt.Println(tokens[idx-1], tokens[idx])
+	root.line, root.col = lparen.line, lparen.col
+	// quickly check the token after operator is not an operator, procedures can be passed to functions, e.g. (map + l)
+	if ps.idx < len(ps.tokens) && isOperator(ps.tokens[ps.idx].tokenType) && isOperator(ps.tokens[ps.idx-1].tokenType) {
+		fmt.Println(ps.tokens[ps.idx-1], ps.tokens[ps.idx])
 		return nil, fmt.Errorf("operator shouldn't followed by an operator")
-	} else if idx < len(tokens) && (root.opeType == "-" || root.opeType == "/") && tokens[idx].tokenType == TOK_RPAREN {
+	} else if ps.idx < len(ps.tokens) && (root.opeType == "-" || root.opeType == "/") && ps.tokens[ps.idx].tokenType == TOK_RPAREN {
 		return nil, fmt.Errorf("- or / shouldn't followed by )")
 	}
 	// check all operands with the oparator
-	for idx < len(tokens) {
-		curToken := tokens[idx]
-		idx++
+	for ps.idx < len(ps.tokens) {
+		curToken := ps.tokens[ps.idx]
+		ps.idx++
 		if curToken.tokenType == TOK_NUM {
-			root.operands = append(root.operands, newExpNum(curToken.num))
+			root.operands = append(root.operands, newExpNum(curToken))
 		} else if curToken.tokenType == TOK_TRUE {
 			root.operands = append(root.operands, newExpBool(true))
 		} else if curToken.tokenType == TOK_FALSE {
 			root.operands = append(root.operands, newExpBool(false))
 		} else if curToken.tokenType == TOK_LPAREN {
-			idx--
-			if subOperand, err := buildPasedTree(tokens); err != nil {
+			ps.idx--
+			if subOperand, err := ps.buildPasedTree(); err != nil {
 				return nil, err
 			} else {
 				root.operands = append(root.operands, subOperand)
 			}
 		} else if curToken.tokenType == TOK_RPAREN {
-			return root, nil
+			return formExp(root), nil
 		} else if curToken.tokenType == TOK_IDENTIFIER {
 			root.operands = append(root.operands, newExpIdentifier(curToken.val))
+		} else if curToken.tokenType == TOK_QUOTE {
+			ps.idx--
+			if datum, err := ps.buildQuotedDatum(); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, newExpQuote(datum))
+			}
+		} else if curToken.tokenType == TOK_VECTOR {
+			ps.idx--
+			if datum, err := ps.buildDatum(); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, newExpQuote(datum))
+			}
+		} else if curToken.tokenType == TOK_QUASIQUOTE {
+			ps.idx--
+			if exp, err := ps.buildQuasiquote(); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, exp)
+			}
+		} else if curToken.tokenType == TOK_UNQUOTE {
+			return nil, fmt.Errorf("unquote: not in quasiquote")
+		} else if curToken.tokenType == TOK_STRING {
+			if str, err := buildString(curToken); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, str)
+			}
+		} else if curToken.tokenType == TOK_CHAR {
+			if c, err := buildChar(curToken); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, c)
+			}
+		} else if curToken.tokenType == TOK_REGEXP {
+			if r, err := buildRegexp(curToken); err != nil {
+				return nil, err
+			} else {
+				root.operands = append(root.operands, r)
+			}
+		} else if curToken.tokenType == TOK_KEYWORD {
+			root.operands = append(root.operands, newExpKeyword(curToken.val))
+		} else if curToken.tokenType == TOK_DOT {
+			// only meaningful in a list of arguments, e.g. (a . rest)
+			root.operands = append(root.operands, newExpIdentifier(curToken.val))
+		} else if isSyntax(curToken.tokenType) {
+			return nil, fmt.Errorf("%s: bad syntax", curToken.val)
+		} else if isOperator(curToken.tokenType) {
+			// operators like + are procedures when used as a value
+			root.operands = append(root.operands, newExpIdentifier(curToken.val))
 		}
 	}
 	return nil, fmt.Errorf("you miss the right parentheses")
 }
+
+// strings evaluate to themselves like quoted data
+func buildString(token Token) (Exp, error) {
+	if str, err := strconv.Unquote(token.val); err != nil {
+		return nil, fmt.Errorf("invalid string: %s", token.val)
+	} else {
+		return newExpQuote(str), nil
+	}
+}
+
+func buildChar(token Token) (Exp, error) {
+	if c, err := parseChar(token.val); err != nil {
+		return nil, err
+	} else {
+		return newExpQuote(c), nil
+	}
+}
+
+// #rx"a+" and #px"\\d+" are compiled once, when they are parsed
+func buildRegexp(token Token) (Exp, error) {
+	if r, err := parseRegexpLiteral(token.val); err != nil {
+		return nil, err
+	} else {
+		return newExpQuote(r), nil
+	}
+}
+
+// `(1 ,x) is parsed as the expression (quasiquote (1 (unquote x)))
+func (ps *parser) buildQuasiquote() (Exp, error) {
+	if datum, err := ps.buildDatum(); err != nil {
+		return nil, err
+	} else {
+		return datumToExp(datum), nil
+	}
+}
+
+// tokens of self-evaluating or quoted values
+func isLiteral(tokenType TokenType) bool {
+	switch tokenType {
+	case TOK_NUM, TOK_TRUE, TOK_FALSE, TOK_STRING, TOK_CHAR, TOK_REGEXP, TOK_QUOTE, TOK_VECTOR, TOK_QUASIQUOTE:
+		return true
+	}
+	return false
+}
+
+// keywords that can't be used as a value
+func isSyntax(tokenType TokenType) bool {
+	return tokenType == TOK_AND || tokenType == TOK_OR || tokenType == TOK_IF || tokenType == TOK_DEFINE
+}
+
+/*
+Quoted data isn't evaluated, build the value directly from tokens:
+'(1 (a b)) -> list of 1 and the list of symbols a and b
+*/
+func (ps *parser) buildQuotedDatum() (interface{}, error) {
+	ps.idx++ // initially the token is ', we don't need to check anymore
+	return ps.buildDatum()
+}
+
+// the datum written in text, for read
+func parseDatum(text string) (interface{}, error) {
+	tokens, err := Tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	ps := &parser{tokens: tokens}
+	datum, err := ps.buildDatum()
+	if err != nil {
+		return nil, err
+	} else if ps.idx != len(tokens) {
+		return nil, fmt.Errorf("there shouldn't have any expression outside the datum")
+	}
+	return datum, nil
+}
+
+func (ps *parser) buildDatum() (interface{}, error) {
+	if ps.idx >= len(ps.tokens) {
+		return nil, fmt.Errorf("expression end too early")
+	}
+	curToken := ps.tokens[ps.idx]
+	ps.idx++
+	switch curToken.tokenType {
+	case TOK_NUM:
+		return literalNumber(curToken.val, curToken.num), nil
+	case TOK_TRUE:
+		return true, nil
+	case TOK_FALSE:
+		return false, nil
+	case TOK_QUOTE:
+		// ''a -> (quote a)
+		ps.idx--
+		if datum, err := ps.buildQuotedDatum(); err != nil {
+			return nil, err
+		} else {
+			return sliceToList([]interface{}{symbol("quote"), datum}), nil
+		}
+	case TOK_QUASIQUOTE, TOK_UNQUOTE:
+		// `a -> (quasiquote a), ,a -> (unquote a) and ,@a -> (unquote-splicing a)
+		name := map[string]string{"`": "quasiquote", ",": "unquote", ",@": "unquote-splicing"}[curToken.val]
+		if datum, err := ps.buildDatum(); err != nil {
+			return nil, err
+		} else {
+			return sliceToList([]interface{}{symbol(name), datum}), nil
+		}
+	case TOK_LPAREN:
+		var elements []interface{}
+		for ps.idx < len(ps.tokens) {
+			if ps.tokens[ps.idx].tokenType == TOK_RPAREN {
+				ps.idx++
+				return sliceToList(elements), nil
+			}
+			if ps.tokens[ps.idx].tokenType == TOK_DOT {
+				// '(1 2 . 3) the datum after . is the tail of the list
+				ps.idx++
+				if len(elements) == 0 {
+					return nil, fmt.Errorf("illegal use of `.`")
+				}
+				tail, err := ps.buildDatum()
+				if err != nil {
+					return nil, err
+				}
+				if ps.idx >= len(ps.tokens) || ps.tokens[ps.idx].tokenType != TOK_RPAREN {
+					return nil, fmt.Errorf("illegal use of `.`")
+				}
+				ps.idx++
+				for i := len(elements) - 1; i >= 0; i-- {
+					tail = &pair{car: elements[i], cdr: tail}
+				}
+				return tail, nil
+			}
+			if datum, err := ps.buildDatum(); err != nil {
+				return nil, err
+			} else {
+				elements = append(elements, datum)
+			}
+		}
+		return nil, fmt.Errorf("you miss the right parentheses")
+	case TOK_VECTOR:
+		// #(1 (a b)) is an immutable vector of data
+		var elements []interface{}
+		for ps.idx < len(ps.tokens) {
+			if ps.tokens[ps.idx].tokenType == TOK_RPAREN {
+				ps.idx++
+				return &vector{elements: elements}, nil
+			}
+			if datum, err := ps.buildDatum(); err != nil {
+				return nil, err
+			} else {
+				elements = append(elements, datum)
+			}
+		}
+		return nil, fmt.Errorf("you miss the right parentheses")
+	case TOK_RPAREN:
+		return nil, fmt.Errorf("unexpected %s", curToken.val)
+	case TOK_DOT:
+		return nil, fmt.Errorf("illegal use of `.`")
+	case TOK_KEYWORD:
+		return keyword(curToken.val[2:]), nil
+	case TOK_STRING:
+		if str, err := strconv.Unquote(curToken.val); err != nil {
+			return nil, fmt.Errorf("invalid string: %s", curToken.val)
+		} else {
+			return str, nil
+		}
+	case TOK_CHAR:
+		return parseChar(curToken.val)
+	case TOK_REGEXP:
+		return parseRegexpLiteral(curToken.val)
+	}
+	// identifiers and operators are symbols
+	return symbol(curToken.val), nil
+}
+
+// the expression written as a datum, the reverse of expToDatum
+func datumToExp(datum interface{}) Exp {
+	switch v := datum.(type) {
+	case int64, float64, *big.Int, *big.Rat:
+		return &ExpNum{val: v}
+	case bool:
+		return newExpBool(v)
+	case symbol:
+		return newExpIdentifier(string(v))
+	case keyword:
+		return newExpKeyword("#:" + string(v))
+	case emptyList:
+		return newExpOperator("")
+	case *pair:
+		var elements []Exp
+		var rest interface{} = v
+		for {
+			p, ok := rest.(*pair)
+			if !ok {
+				break
+			}
+			elements = append(elements, datumToExp(p.car))
+			rest = p.cdr
+		}
+		if _, ok := rest.(emptyList); !ok {
+			// (a . b)
+			elements = append(elements, newExpIdentifier("."), datumToExp(rest))
+		} else if head, ok := v.car.(symbol); ok && head == "quote" && len(elements) == 2 {
+			return newExpQuote(v.cdr.(*pair).car)
+		}
+		if head, ok := elements[0].(*ExpIdentifier); ok {
+			return formExp(&ExpOperator{opeType: head.val, operands: elements[1:]})
+		}
+		return &ExpOperator{proc: elements[0], operands: elements[1:]}
+	}
+	// strings, characters, regexps and vectors evaluate to themselves
+	return newExpQuote(datum)
+}
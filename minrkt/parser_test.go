@@ -4,32 +4,35 @@ import (
 	"testing"
 )
 
-var tokenLP = Token{TOK_LPAREN, 0, "("}
-var tokenAdd = Token{TOK_ADD, 0, "+"}
-var token0 = Token{TOK_NUM, 0, "0"}
-var token1 = Token{TOK_NUM, 1, "1"}
-var token2 = Token{TOK_NUM, 2, "2"}
-var token3 = Token{TOK_NUM, 3, "3"}
-var token4 = Token{TOK_NUM, 4, "4"}
-var tokenRP = Token{TOK_RPAREN, 0, ")"}
-var tokenPlus2 = Token{TOK_NUM, 2, "+2"}
-var tokenMinus3 = Token{TOK_NUM, -3, "-3.0"}
-var tokenSub = Token{TOK_SUB, 0, "-"}
-var tokenMUL = Token{TOK_MUL, 0, "*"}
-var tokenDIV = Token{TOK_DIV, 0, "/"}
-var tokenAND = Token{TOK_AND, 0, "and"}
-var tokenOR = Token{TOK_OR, 0, "or"}
-var tokenNOT = Token{TOK_NOT, 0, "not"}
-var tokenLargeEqual = Token{TOK_LARGEEQUAL, 0, ">="}
-var tokenLessEqual = Token{TOK_LESSEQUAL, 0, "<="}
-var tokenEqual = Token{TOK_EQUAL, 0, "=="}
-var tokenIf = Token{TOK_IF, 0, "if"}
-var tokenTrue = Token{TOK_TRUE, 0, "true"}
-var tokenFalse = Token{TOK_FALSE, 0, "false"}
-var tokenDefine = Token{TOK_DEFINE, 0, "define"}
-var tokenIdentifierX = Token{TOK_IDENTIFIER, 0, "x"}
-var tokenIdentifierY = Token{TOK_IDENTIFIER, 0, "y"}
-var tokenIdentifierFib = Token{TOK_IDENTIFIER, 0, "fib"}
+var tokenLP = Token{TOK_LPAREN, 0, "(", 0, 0}
+var tokenAdd = Token{TOK_ADD, 0, "+", 0, 0}
+var token0 = Token{TOK_NUM, 0, "0", 0, 0}
+var token1 = Token{TOK_NUM, 1, "1", 0, 0}
+var token2 = Token{TOK_NUM, 2, "2", 0, 0}
+var token3 = Token{TOK_NUM, 3, "3", 0, 0}
+var token4 = Token{TOK_NUM, 4, "4", 0, 0}
+var tokenRP = Token{TOK_RPAREN, 0, ")", 0, 0}
+var tokenPlus2 = Token{TOK_NUM, 2, "+2", 0, 0}
+var tokenMinus3 = Token{TOK_NUM, -3, "-3.0", 0, 0}
+var tokenSub = Token{TOK_SUB, 0, "-", 0, 0}
+var tokenMUL = Token{TOK_MUL, 0, "*", 0, 0}
+var tokenDIV = Token{TOK_DIV, 0, "/", 0, 0}
+var tokenAND = Token{TOK_AND, 0, "and", 0, 0}
+var tokenOR = Token{TOK_OR, 0, "or", 0, 0}
+var tokenNOT = Token{TOK_NOT, 0, "not", 0, 0}
+var tokenLargeEqual = Token{TOK_LARGEEQUAL, 0, ">=", 0, 0}
+var tokenLessEqual = Token{TOK_LESSEQUAL, 0, "<=", 0, 0}
+var tokenEqual = Token{TOK_EQUAL, 0, "==", 0, 0}
+var tokenIf = Token{TOK_IF, 0, "if", 0, 0}
+var tokenTrue = Token{TOK_TRUE, 0, "true", 0, 0}
+var tokenFalse = Token{TOK_FALSE, 0, "false", 0, 0}
+var tokenDefine = Token{TOK_DEFINE, 0, "define", 0, 0}
+var tokenIdentifierX = Token{TOK_IDENTIFIER, 0, "x", 0, 0}
+var tokenIdentifierY = Token{TOK_IDENTIFIER, 0, "y", 0, 0}
+var tokenIdentifierFib = Token{TOK_IDENTIFIER, 0, "fib", 0, 0}
+var tokenIdentifierMap = Token{TOK_IDENTIFIER, 0, "map", 0, 0}
+var tokenLambda = Token{TOK_IDENTIFIER, 0, "lambda", 0, 0}
+var tokenQuote = Token{TOK_QUOTE, 0, "'", 0, 0}
 
 func TestParse(t *testing.T) {
 
@@ -153,4 +156,68 @@ func TestVariableAndFunctionParser(t *testing.T) {
 		t.Error("expected parsed tree is", want, " but got", result)
 	}
 
+	// (lambda () 1)
+	tokens = []Token{tokenLP, tokenLambda, tokenLP, tokenRP, token1, tokenRP}
+	root, _ = Parse(tokens)
+	want = "lambda  1.00 "
+	if result := root.Print(); result != want {
+		t.Error("expected parsed tree is", want, " but got", result)
+	}
+
+	// ((lambda (x) x) 2)
+	tokens = []Token{tokenLP, tokenLP, tokenLambda, tokenLP, tokenIdentifierX, tokenRP, tokenIdentifierX, tokenRP, token2, tokenRP}
+	root, _ = Parse(tokens)
+	want = "lambda x x 2.00 "
+	if result := root.Print(); result != want {
+		t.Error("expected parsed tree is", want, " but got", result)
+	}
+
+	// (map + '(1 2))
+	tokens = []Token{tokenLP, tokenIdentifierMap, tokenAdd, tokenQuote, tokenLP, token1, token2, tokenRP, tokenRP}
+	root, _ = Parse(tokens)
+	want = "map + '(1 2) "
+	if result := root.Print(); result != want {
+		t.Error("expected parsed tree is", want, " but got", result)
+	}
+
+	// 'x
+	tokens = []Token{tokenQuote, tokenIdentifierX}
+	root, _ = Parse(tokens)
+	want = "'x "
+	if result := root.Print(); result != want {
+		t.Error("expected parsed tree is", want, " but got", result)
+	}
+
+	// (map and '(1 2))
+	tokens = []Token{tokenLP, tokenIdentifierMap, tokenAND, tokenQuote, tokenLP, token1, token2, tokenRP, tokenRP}
+	if _, err := Parse(tokens); err == nil {
+		t.Error("expected parser error doesn't show up for expression (map and '(1 2))")
+	}
+}
+
+func TestParseProgram(t *testing.T) {
+	tokens, err := Tokenize("; a program\n(define x 1) ; the value\nx 'y \"s\"\n(+ x 2)\n")
+	if err != nil {
+		t.Fatal("unexpected tokenizer error:", err)
+	}
+	forms, err := ParseProgram(tokens)
+	if err != nil {
+		t.Fatal("unexpected parser error:", err)
+	}
+	want := []string{"define x 1.00 ", "x ", "'y ", "\"s\" ", "+ x 2.00 "}
+	if len(forms) != len(want) {
+		t.Fatal("expected", len(want), "forms but got", len(forms))
+	}
+	for i, form := range forms {
+		if result := form.Print(); result != want[i] {
+			t.Error("expected parsed form is", want[i], " but got", result)
+		}
+	}
+	if op, ok := forms[4].(*ExpOperator); !ok || op.line != 4 {
+		t.Error("expected the last form to start on line 4")
+	}
+	tokens, _ = Tokenize("(define x 1) (+ x")
+	if _, err := ParseProgram(tokens); err == nil {
+		t.Error("expected parser error doesn't show up for an unclosed form")
+	}
 }
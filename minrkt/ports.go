@@ -0,0 +1,465 @@
+package minrkt
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// destination of display, write and printf
+type outputPort struct {
+	name string
+	w    io.Writer
+	// what was written to ports created by open-output-string and with-output-to-string
+	buf *strings.Builder
+	// file of ports created by open-output-file, nil for the other ports
+	closer io.Closer
+	closed bool
+}
+
+// source of read-line, read-char and read
+type inputPort struct {
+	name   string
+	r      *bufio.Reader
+	closer io.Closer
+	closed bool
+}
+
+// the value returned by reading functions at the end of the input
+type eofValue struct{}
+
+var eof = eofValue{}
+
+var stdoutPort = &outputPort{name: "stdout", w: os.Stdout}
+
+func newStringPort() *outputPort {
+	buf := &strings.Builder{}
+	return &outputPort{name: "string", w: buf, buf: buf}
+}
+
+func currentOutputPort(p Params) *outputPort {
+	return p.parameterValue(outputPortParameter).(*outputPort)
+}
+
+func (port *outputPort) write(proc string, s string) error {
+	if port.closed {
+		return newRacketError(ERR_FAIL, proc, "output port is closed")
+	}
+	if _, err := io.WriteString(port.w, s); err != nil {
+		return newRacketError(ERR_FAIL, proc, "error writing to stream port\n  system error: %v", err)
+	}
+	return nil
+}
+
+func (port *outputPort) close() error {
+	port.closed = true
+	if port.closer != nil {
+		return port.closer.Close()
+	}
+	return nil
+}
+
+var stdinPort = &inputPort{name: "stdin", r: bufio.NewReader(os.Stdin)}
+
+func newInputPort(name string, r io.Reader) *inputPort {
+	port := &inputPort{name: name, r: bufio.NewReader(r)}
+	if closer, ok := r.(io.Closer); ok {
+		port.closer = closer
+	}
+	return port
+}
+
+func currentInputPort(p Params) *inputPort {
+	return p.parameterValue(inputPortParameter).(*inputPort)
+}
+
+func (port *inputPort) close() error {
+	port.closed = true
+	if port.closer != nil {
+		return port.closer.Close()
+	}
+	return nil
+}
+
+// the optional input port argument at index i, the current input port when it's missing
+func inputPortArg(proc string, args []interface{}, i int, p Params) (*inputPort, error) {
+	port := currentInputPort(p)
+	if len(args) > i {
+		var ok bool
+		if port, ok = args[i].(*inputPort); !ok {
+			return nil, contractError(proc, "input-port?", args[i])
+		}
+	}
+	if port.closed {
+		return nil, newRacketError(ERR_FAIL, proc, "input port is closed")
+	}
+	return port, nil
+}
+
+func readError(proc string, err error) error {
+	return newRacketError(ERR_FAIL, proc, "error reading from stream port\n  system error: %v", err)
+}
+
+/*
+The next line without its line terminator, mode is linefeed for lines ended by \n
+or any for lines ended by \n, \r or \r\n
+*/
+func (port *inputPort) readLine(proc string, mode symbol) (interface{}, error) {
+	var sb strings.Builder
+	for {
+		r, _, err := port.r.ReadRune()
+		if err == io.EOF {
+			if sb.Len() == 0 {
+				return eof, nil
+			}
+			return sb.String(), nil
+		} else if err != nil {
+			return nil, readError(proc, err)
+		}
+		if r == '\n' {
+			return sb.String(), nil
+		}
+		if r == '\r' && mode == "any" {
+			if next, _, err := port.r.ReadRune(); err == nil && next != '\n' {
+				port.r.UnreadRune()
+			}
+			return sb.String(), nil
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func (port *inputPort) readChar(proc string, peek bool) (interface{}, error) {
+	r, _, err := port.r.ReadRune()
+	if err == io.EOF {
+		return eof, nil
+	} else if err != nil {
+		return nil, readError(proc, err)
+	}
+	if peek {
+		port.r.UnreadRune()
+	}
+	return char(r), nil
+}
+
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("()[]{}\";", r)
+}
+
+/*
+Text of the next datum of the port, e.g. (1 "a)" b) or 'x, and false at the
+end of the input. Whitespace and ; comments before the datum are skipped.
+*/
+func (port *inputPort) datumText(proc string) (string, bool, error) {
+	var sb strings.Builder
+	depth := 0
+	inString, escaped, inComment := false, false, false
+	for {
+		r, _, err := port.r.ReadRune()
+		if err == io.EOF {
+			if inString || depth > 0 {
+				return "", false, newRacketError(ERR_FAIL, proc, "unexpected end-of-file")
+			}
+			return sb.String(), sb.Len() > 0, nil
+		} else if err != nil {
+			return "", false, readError(proc, err)
+		}
+		switch {
+		case inComment:
+			inComment = r != '\n'
+			continue
+		case inString:
+			sb.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+				if depth == 0 {
+					return sb.String(), true, nil
+				}
+			}
+			continue
+		case r == '\\' && strings.HasSuffix(sb.String(), "#"):
+			// #\( and #\; are characters, not delimiters
+			sb.WriteRune(r)
+			if r, _, err = port.r.ReadRune(); err != nil {
+				return "", false, newRacketError(ERR_FAIL, proc, "unexpected end-of-file")
+			}
+		case r == ';':
+			inComment = true
+			continue
+		case unicode.IsSpace(r):
+			if depth > 0 {
+				sb.WriteRune(r)
+			}
+			continue
+		case r == '"':
+			inString = true
+		case strings.ContainsRune("([{", r):
+			depth++
+		case strings.ContainsRune(")]}", r):
+			if depth == 0 {
+				return "", false, newRacketError(ERR_FAIL, proc, "unexpected `%c`", r)
+			}
+			depth--
+		}
+		sb.WriteRune(r)
+		if depth > 0 || inString || r == '\'' {
+			continue
+		}
+		if strings.ContainsRune(")]}", r) {
+			return sb.String(), true, nil
+		}
+		// the end of a symbol or a number
+		if next, _, err := port.r.ReadRune(); err == nil {
+			port.r.UnreadRune()
+			// #( starts a vector and #rx" a regexp
+			if isDelimiter(next) && !(r == '#' && next == '(') && !(next == '"' && isRegexpPrefix(sb.String())) {
+				return sb.String(), true, nil
+			}
+		}
+	}
+}
+
+// the optional port argument of display and write at index i, the current output port when it's missing
+func portArg(proc string, args []interface{}, i int, p Params) (*outputPort, error) {
+	if len(args) <= i {
+		return currentOutputPort(p), nil
+	}
+	port, ok := args[i].(*outputPort)
+	if !ok {
+		return nil, contractError(proc, "output-port?", args[i])
+	}
+	return port, nil
+}
+
+// builtins like (display v [port]) writing one value
+func defineWriter(name string, format func(v interface{}) string, suffix string) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(name, args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := portArg(name, args, 1, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if err := port.write(name, format(args[0])+suffix); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return void, TYPE_VOID, nil
+	})
+}
+
+// (printf format v ...) and (fprintf port format v ...)
+func printFormatted(proc string, port *outputPort, args []interface{}) (interface{}, TypeEnum, error) {
+	format, ok := args[0].(string)
+	if !ok {
+		return nil, TYPE_ERROR, contractError(proc, "string?", args[0])
+	}
+	s, err := racketFormat(proc, format, args[1:])
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	if err := port.write(proc, s); err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	return void, TYPE_VOID, nil
+}
+
+func init() {
+	defineWriter("display", displayDatum, "")
+	defineWriter("displayln", displayDatum, "\n")
+	defineWriter("write", formatDatum, "")
+	defineWriter("writeln", formatDatum, "\n")
+	defineWriter("print", FormatValue, "")
+	defineBuiltin("newline", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("newline", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := portArg("newline", args, 0, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if err := port.write("newline", "\n"); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("printf", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("printf", args, 1, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return printFormatted("printf", currentOutputPort(p), args)
+	})
+	defineBuiltin("fprintf", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("fprintf", args, 2, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := portArg("fprintf", args, 0, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return printFormatted("fprintf", port, args[1:])
+	})
+	defineBuiltin("format", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("format", args, 1, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		format, ok := args[0].(string)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("format", "string?", args[0])
+		}
+		s, err := racketFormat("format", format, args[1:])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return s, TYPE_STRING, nil
+	})
+	defineBuiltin("output-port?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("output-port?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*outputPort)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("open-output-string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("open-output-string", args, 0, 0); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return newStringPort(), TYPE_PORT, nil
+	})
+	defineBuiltin("get-output-string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("get-output-string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, ok := args[0].(*outputPort)
+		if !ok || port.buf == nil {
+			return nil, TYPE_ERROR, contractError("get-output-string", "string-port?", args[0])
+		}
+		return port.buf.String(), TYPE_STRING, nil
+	})
+	defineBuiltin("close-output-port", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("close-output-port", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, ok := args[0].(*outputPort)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("close-output-port", "output-port?", args[0])
+		}
+		if err := port.close(); err != nil {
+			return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "close-output-port", "error closing stream port\n  system error: %v", err)
+		}
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("with-output-to-string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (with-output-to-string thunk) is what the thunk printed
+		if err := checkArity("with-output-to-string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port := newStringPort()
+		if _, _, err := applyProcedure(args[0], nil, p.parameterize(outputPortParameter, port)); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return port.buf.String(), TYPE_STRING, nil
+	})
+	defineBuiltin("input-port?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("input-port?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*inputPort)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("open-input-string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("open-input-string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("open-input-string", "string?", args[0])
+		}
+		return newInputPort("string", strings.NewReader(s)), TYPE_PORT, nil
+	})
+	defineBuiltin("close-input-port", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("close-input-port", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, ok := args[0].(*inputPort)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("close-input-port", "input-port?", args[0])
+		}
+		if err := port.close(); err != nil {
+			return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "close-input-port", "error closing stream port\n  system error: %v", err)
+		}
+		return void, TYPE_VOID, nil
+	})
+	builtins["eof"] = eof
+	defineBuiltin("eof-object?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("eof-object?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return args[0] == eof, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("read-line", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (read-line [port mode]), mode is 'linefeed or 'any
+		if err := checkArity("read-line", args, 0, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := inputPortArg("read-line", args, 0, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		mode := symbol("linefeed")
+		if len(args) == 2 {
+			if mode, _ = args[1].(symbol); mode != "linefeed" && mode != "any" {
+				return nil, TYPE_ERROR, contractError("read-line", "(or/c 'linefeed 'any)", args[1])
+			}
+		}
+		line, err := port.readLine("read-line", mode)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return line, typeOf(line), nil
+	})
+	for _, name := range []string{"read-char", "peek-char"} {
+		name := name
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 0, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			port, err := inputPortArg(name, args, 0, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			c, err := port.readChar(name, name == "peek-char")
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return c, typeOf(c), nil
+		})
+	}
+	defineBuiltin("read", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the next datum of the port, not evaluated
+		if err := checkArity("read", args, 0, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		port, err := inputPortArg("read", args, 0, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		text, ok, err := port.datumText("read")
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		} else if !ok {
+			return eof, TYPE_EOF, nil
+		}
+		datum, err := parseDatum(text)
+		if err != nil {
+			return nil, TYPE_ERROR, newRacketError(ERR_FAIL, "read", "%v", err)
+		}
+		return datum, typeOf(datum), nil
+	})
+}
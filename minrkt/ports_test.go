@@ -0,0 +1,180 @@
+package minrkt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOutputPorts(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define out (open-output-string))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(with-output-to-string (lambda () (display \"hi\") (display (list 1 \"a\" (quote b)))))", "\"hi(1 a b)\""},
+		{"(with-output-to-string (lambda () (write \"hi\") (write 'a)))", "\"\\\"hi\\\"a\""},
+		{"(with-output-to-string (lambda () (displayln 1) (newline) (writeln \"s\")))", "\"1\\n\\n\\\"s\\\"\\n\""},
+		{"(with-output-to-string (lambda () (print '(1 2)) (print \"s\")))", "\"'(1 2)\\\"s\\\"\""},
+		{"(with-output-to-string (lambda () (printf \"~a and ~s~n\" \"x\" \"y\")))", "\"x and \\\"y\\\"\\n\""},
+		{"(format \"~a: ~v\" 'list '(1 2))", "\"list: '(1 2)\""},
+		{"(format \"~~ ~a\" #t)", "\"~ #t\""},
+		{"(begin (display \"a\" out) (fprintf out \"~a\" 1) (write \"b\" out) (get-output-string out))", "\"a1\\\"b\\\"\""},
+		{"(with-output-to-string (lambda () (display 'x (current-output-port))))", "\"x\""},
+		{"(with-output-to-string (lambda () (with-output-to-string (lambda () (display 1))) (display 2)))", "\"2\""},
+		{"(output-port? out)", "#t"},
+		{"(output-port? \"out\")", "#f"},
+		{"out", "#<output-port:string>"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(display 1 2)", "display: contract violation\n  expected: output-port?\n  given: 2"},
+		{"(format \"~a ~a\" 1)", "format: format string requires more arguments than given"},
+		{"(format \"~a\" 1 2)", "format: format string requires 1 arguments, given 2"},
+		{"(printf 'x)", "printf: contract violation\n  expected: string?\n  given: 'x"},
+		{"(get-output-string (current-output-port))", "get-output-string: contract violation\n  expected: string-port?\n  given: #<output-port:stdout>"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
+
+// values containing themselves are printed with labels instead of recursing forever
+func TestCyclicOutput(t *testing.T) {
+	p := newTestParams()
+	for _, line := range []string{
+		"(define v (make-vector 2 0))",
+		"(vector-set! v 1 v)",
+		"(struct node (value next) #:mutable #:transparent)",
+		"(define n (node 1 #f))",
+		"(set-node-next! n n)",
+		"(define h (make-hash))",
+		"(hash-set! h 'self h)",
+	} {
+		if _, _, err := evalLine(p, line); err != nil {
+			t.Fatal("unexpected evaluation error for", line, ":", err)
+		}
+	}
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"v", "#0='#(0 #0#)"},
+		{"(with-output-to-string (lambda () (display v)))", "\"#0=#(0 #0#)\""},
+		{"(format \"~a\" v)", "\"#0=#(0 #0#)\""},
+		{"(list v v)", "'(#0=#(0 #0#) #0#)"},
+		{"(vector 1 (list 2 v))", "'#(1 (2 #0=#(0 #0#)))"},
+		{"n", "#0=(node 1 #0#)"},
+		{"(format \"~s\" n)", "\"#0=#(struct:node 1 #0#)\""},
+		{"(node 2 n)", "(node 2 #0=(node 1 #0#))"},
+		{"h", "#0='#hash((self . #0#))"},
+		// shared values which aren't in a cycle are printed each time
+		{"(let ([w (vector 1)]) (list w w))", "'(#(1) #(1))"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+}
+
+func TestInterpreterOutput(t *testing.T) {
+	var out bytes.Buffer
+	in := NewInterpreter(WithOutput(&out))
+	if _, _, err := in.Eval("(define (greet name) (printf \"hello ~a~n\" name))"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, _, err := in.Eval("(for-each greet '(alice bob))"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if got, want := out.String(), "hello alice\nhello bob\n"; got != want {
+		t.Errorf("expected output %q but got %q", want, got)
+	}
+	var other bytes.Buffer
+	in.SetOutput(&other)
+	in.Eval("(display (with-output-to-string (lambda () (greet 'carol))))")
+	if got, want := other.String(), "hello carol\n"; got != want {
+		t.Errorf("expected output %q but got %q", want, got)
+	}
+}
+
+func TestInputPorts(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define in (open-input-string \"first line\\r\\nsecond\\nlast\"))")
+	evalLine(p, "(define (read-lines port) (let ([l (read-line port)]) (if (eof-object? l) '() (cons l (read-lines port)))))")
+	evalLine(p, "(define data (open-input-string \"(1 \\\"a)\\\" [b]) ; comment\\n'sym 42 #(1 2)\"))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(read-line in 'any)", "\"first line\""},
+		{"(peek-char in)", "#\\s"},
+		{"(read-char in)", "#\\s"},
+		{"(read-line in)", "\"econd\""},
+		{"(read-line in)", "\"last\""},
+		{"(eof-object? (read-line in))", "#t"},
+		{"(read-char in)", "#<eof>"},
+		{"(read data)", "'(1 \"a)\" (b))"},
+		{"(read data)", "'(quote sym)"},
+		{"(read data)", "42"},
+		{"(read data)", "'#(1 2)"},
+		{"(eof-object? (read data))", "#t"},
+		{"(input-port? data)", "#t"},
+		{"(input-port? (current-output-port))", "#f"},
+		{"(read-lines (open-input-string \"a\\nb\\n\"))", "'(\"a\" \"b\")"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(read (open-input-string \"(1 2\"))", "read: unexpected end-of-file"},
+		{"(read (open-input-string \")\"))", "read: unexpected `)`"},
+		{"(read-line 'x)", "read-line: contract violation\n  expected: input-port?\n  given: 'x"},
+		{"(let ([port (open-input-string \"x\")]) (close-input-port port) (read-char port))", "read-char: input port is closed"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+
+	in := NewInterpreter(WithInput(strings.NewReader("hello\n(a b)")))
+	if line, _, err := in.Eval("(read-line)"); err != nil || line != "hello" {
+		t.Error("expected to read hello from the input of the interpreter but got", line, err)
+	}
+	if datum, _, err := in.Eval("(read)"); err != nil || FormatValue(datum) != "'(a b)" {
+		t.Error("expected to read (a b) from the input of the interpreter but got", datum, err)
+	}
+}
@@ -0,0 +1,108 @@
+package minrkt
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sync"
+)
+
+/*
+The prelude is a set of modules written in mini-racket and bundled into the
+binary. A prelude module is instantiated the first time one of the names it
+provides is looked up and isn't defined by the program, so programs which
+don't use the prelude don't pay for it. Prelude modules can use each other's
+names without requiring them, their provide forms only list identifiers.
+*/
+//go:embed prelude/*.rkt
+var preludeFiles embed.FS
+
+const preludeDir = "prelude"
+
+var (
+	preludeIndexOnce sync.Once
+	// path of the prelude module providing each name
+	preludeIndex map[string]string
+	// set when the prelude modules can't be read, every lookup of the prelude fails with it
+	preludeIndexErr error
+)
+
+func isPreludePath(p string) bool {
+	return path.Dir(p) == preludeDir
+}
+
+// read the provide forms of the prelude modules without evaluating them
+func buildPreludeIndex() {
+	preludeIndex = make(map[string]string)
+	entries, err := preludeFiles.ReadDir(preludeDir)
+	if err != nil {
+		preludeIndexErr = err
+		return
+	}
+	for _, entry := range entries {
+		file := path.Join(preludeDir, entry.Name())
+		src, err := preludeFiles.ReadFile(file)
+		if err != nil {
+			preludeIndexErr = fmt.Errorf("reading %s: %w", file, err)
+			return
+		}
+		forms, err := parseModule(file, string(src))
+		if err != nil {
+			preludeIndexErr = fmt.Errorf("reading %s: %w", file, err)
+			return
+		}
+		for _, form := range forms {
+			if op, ok := form.(*ExpOperator); ok && op.opeType == "provide" {
+				for _, spec := range op.operands {
+					if id, ok := spec.(*ExpIdentifier); ok {
+						preludeIndex[id.val] = file
+					}
+				}
+			}
+		}
+	}
+}
+
+// the prelude is part of the binary, a module which fails to load is raised as an exn:fail by the lookup
+func preludeError(name string, err error) error {
+	return newRacketError(ERR_FAIL, name, "cannot load the prelude: %v", err)
+}
+
+// the value of a name provided by the prelude, instantiating its module when needed
+func preludeBinding(name string, p Params) (interface{}, bool, error) {
+	if p.noPrelude {
+		return nil, false, nil
+	}
+	preludeIndexOnce.Do(buildPreludeIndex)
+	if preludeIndexErr != nil {
+		return nil, false, preludeError(name, preludeIndexErr)
+	}
+	file, ok := preludeIndex[name]
+	if !ok {
+		return nil, false, nil
+	}
+	m, err := loadModule(file, p)
+	if err != nil {
+		return nil, false, preludeError(name, fmt.Errorf("loading %s: %w", file, err))
+	}
+	v, ok := m.exports[name]
+	return v, ok, nil
+}
+
+// bindings of all the prelude modules, for (require (prefix-in l: racket/list)) and the like
+func preludeBindings(p Params) (map[string]interface{}, error) {
+	bindings := make(map[string]interface{})
+	if p.noPrelude {
+		return bindings, nil
+	}
+	preludeIndexOnce.Do(buildPreludeIndex)
+	for name := range preludeIndex {
+		v, ok, err := preludeBinding(name, p)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			bindings[name] = v
+		}
+	}
+	return bindings, nil
+}
@@ -0,0 +1,146 @@
+package minrkt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPreludeModulesLoad(t *testing.T) {
+	preludeIndexOnce.Do(buildPreludeIndex)
+	p := NewInterpreter().params
+	for name, file := range preludeIndex {
+		m, err := loadModule(file, p)
+		if err != nil {
+			t.Fatal("unexpected error loading", file, ":", err)
+		}
+		if _, ok := m.exports[name]; !ok {
+			t.Error("expected", file, "to provide", name)
+		}
+		// builtins are looked up first, a prelude definition of the same name would never be used
+		if _, ok := builtins[name]; ok {
+			t.Error("expected", name, "of", file, "not to be shadowed by a builtin")
+		}
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	in := NewInterpreter()
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(first '(1 2 3))", "1"},
+		{"(second '(1 2 3))", "2"},
+		{"(third '(1 2 3))", "3"},
+		{"(rest '(1 2 3))", "'(2 3)"},
+		{"(last '(1 2 3))", "3"},
+		{"(empty? '())", "#t"},
+		{"(list-ref '(a b c) 1)", "'b"},
+		{"(list-tail '(a b c) 2)", "'(c)"},
+		{"(take '(1 2 3 4) 2)", "'(1 2)"},
+		{"(drop '(1 2 3 4) 2)", "'(3 4)"},
+		{"(take '(1 2) 0)", "'()"},
+		{"(remove 2 '(1 2 3 2))", "'(1 3 2)"},
+		{"(remove-duplicates '(1 2 1 3 2))", "'(1 2 3)"},
+		{"(flatten '(1 (2 (3 4)) () 5))", "'(1 2 3 4 5)"},
+		{"(append-map (lambda (x) (list x x)) '(1 2))", "'(1 1 2 2)"},
+		{"(filter-map (lambda (x) (if (> x 1) (* x 10) #f)) '(1 2 3))", "'(20 30)"},
+		{"(filter-not (lambda (x) (> x 1)) '(1 2 3))", "'(1)"},
+		{"(count (lambda (x) (> x 1)) '(1 2 3))", "2"},
+		{"(index-of '(a b c) 'c)", "2"},
+		{"(index-of '(a b c) 'd)", "#f"},
+		{"(add-between '(1 2 3) 0)", "'(1 0 2 0 3)"},
+		{"(identity 5)", "5"},
+		{"((const 1) 2 3)", "1"},
+		{"((negate null?) '())", "#f"},
+		{"((compose (lambda (x) (* x 2)) +) 1 2)", "6"},
+		{"((compose1 list car) '(1 2))", "'(1)"},
+		{"((compose) 7)", "7"},
+		{"((curry + 1) 2 3)", "6"},
+		{"((curryr list 1) 2)", "'(2 1)"},
+		{"(string-join '(\"a\" \"b\" \"c\") \", \")", "\"a, b, c\""},
+		{"(string-join '(\"a\" \"b\"))", "\"a b\""},
+		{"(string-join '())", "\"\""},
+		{"(string-prefix? \"hello\" \"he\")", "#t"},
+		{"(string-suffix? \"hello\" \"lo\")", "#t"},
+		{"(string-suffix? \"lo\" \"hello\")", "#f"},
+		{"(string-contains? \"hello\" \"ll\")", "#t"},
+		{"(string-contains? \"hello\" \"x\")", "#f"},
+		{"(string-split \"  foo bar\\tbaz \")", "'(\"foo\" \"bar\" \"baz\")"},
+		{"(string-split \"a,b,,c,\" \",\")", "'(\"a\" \"b\" \"\" \"c\")"},
+		{"(string-trim \"  hi there \\n\")", "\"hi there\""},
+		{"(string-trim \"   \")", "\"\""},
+		{"(string-replace \"a-b-c\" \"-\" \"+\")", "\"a+b+c\""},
+		{"(string-replace \"a-b-c\" \"-\" \"+\" #:all? #f)", "\"a+b-c\""},
+		{"(non-empty-string? \"\")", "#f"},
+		{"(non-empty-string? 'a)", "#f"},
+		// definitions of the program shadow the prelude
+		{"(begin (define (first l) 'mine) (first '(1)))", "'mine"},
+		{"(second '(1 2))", "2"},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(rest '())", "rest: contract violation\n  expected: (and/c list? (not/c empty?))\n  given: '()"},
+		{"(take '(1 2) 3)", "take: contract violation\n  expected: a list with at least 3 elements\n  given: '(1 2)"},
+		{"(string-split \"abc\" \"\")", "string-split: contract violation\n  expected: non-empty-string?\n  given: \"\""},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
+
+func TestPreludeIsLazy(t *testing.T) {
+	in := NewInterpreter()
+	in.Eval("(define x (+ 1 2))")
+	if len(in.params.modules.modules) != 0 {
+		t.Error("expected no prelude module to be loaded before one of its names is used")
+	}
+	in.Eval("(identity x)")
+	if _, ok := in.params.modules.modules["prelude/function.rkt"]; !ok || len(in.params.modules.modules) != 1 {
+		t.Error("expected only prelude/function.rkt to be loaded")
+	}
+
+	bare := NewInterpreter(WithoutPrelude())
+	_, _, err := bare.Eval("(identity 1)")
+	var re *RacketError
+	if !errors.As(err, &re) || re.Kind != ERR_UNBOUND {
+		t.Error("expected identity to be unbound in a bare environment but got", err)
+	}
+	if v, _, err := bare.Eval("(car '(1))"); err != nil || v != int64(1) {
+		t.Error("expected builtins to be defined in a bare environment")
+	}
+}
+
+func TestPreludeLoadError(t *testing.T) {
+	preludeIndexOnce.Do(buildPreludeIndex)
+	// a name provided by a module which can't be loaded
+	preludeIndex["broken-util"] = "prelude/missing.rkt"
+	defer delete(preludeIndex, "broken-util")
+	in := NewInterpreter()
+	_, _, err := in.Eval("(broken-util 1)")
+	var re *RacketError
+	if !errors.As(err, &re) || re.Kind != ERR_FAIL || !strings.HasPrefix(err.Error(), "broken-util: cannot load the prelude: loading prelude/missing.rkt") {
+		t.Error("expected an exn:fail for the prelude module which can't be loaded but got", err)
+	}
+	// the other names of the prelude are still there
+	if v, _, err := in.Eval("(identity 1)"); err != nil || v != int64(1) {
+		t.Error("expected identity to be loaded but got", v, err)
+	}
+}
@@ -0,0 +1,102 @@
+package minrkt
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// result of make-pseudo-random-generator, the same seed always gives the same numbers
+type randomGenerator struct {
+	rand *rand.Rand
+}
+
+func newRandomGenerator(seed int64) *randomGenerator {
+	return &randomGenerator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// generator of random when none is given, the one of the REPL is seeded with the time
+var randomParameter = newParameter("current-pseudo-random-generator", newRandomGenerator(time.Now().UnixNano()),
+	func(v interface{}, p Params) (interface{}, error) {
+		if _, ok := v.(*randomGenerator); !ok {
+			return nil, contractError("current-pseudo-random-generator", "pseudo-random-generator?", v)
+		}
+		return v, nil
+	})
+
+func currentRandomGenerator(p Params) *randomGenerator {
+	return p.parameterValue(randomParameter).(*randomGenerator)
+}
+
+// the largest range of (random k) and (random min max) like racket
+const maxRandomRange = 4294967087
+
+func init() {
+	builtins["current-pseudo-random-generator"] = randomParameter
+
+	defineBuiltin("random", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (random), (random k) or (random min max), with an optional generator as last argument
+		if err := checkArity("random", args, 0, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		gen := currentRandomGenerator(p)
+		if len(args) > 0 {
+			if g, ok := args[len(args)-1].(*randomGenerator); ok {
+				gen, args = g, args[:len(args)-1]
+			}
+		}
+		switch len(args) {
+		case 0:
+			// a float between 0 and 1, both excluded
+			for {
+				if f := gen.rand.Float64(); f != 0 {
+					return f, TYPE_FLOAT64, nil
+				}
+			}
+		case 1:
+			k, ok := args[0].(int64)
+			if !ok || k < 1 || k > maxRandomRange {
+				return nil, TYPE_ERROR, contractError("random", fmt.Sprintf("(or/c (integer-in 1 %d) pseudo-random-generator?)", maxRandomRange), args[0])
+			}
+			return gen.rand.Int63n(k), TYPE_FLOAT64, nil
+		case 2:
+			min, ok := args[0].(int64)
+			if !ok {
+				return nil, TYPE_ERROR, contractError("random", "exact-integer?", args[0])
+			}
+			max, ok := args[1].(int64)
+			if !ok || max <= min || max-min > maxRandomRange {
+				return nil, TYPE_ERROR, contractError("random", fmt.Sprintf("(integer-in %d %d)", min+1, min+maxRandomRange), args[1])
+			}
+			return min + gen.rand.Int63n(max-min), TYPE_FLOAT64, nil
+		}
+		return nil, TYPE_ERROR, contractError("random", "pseudo-random-generator?", args[len(args)-1])
+	})
+	defineBuiltin("random-seed", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// reseeds the current generator, the numbers which follow are always the same for a seed
+		if err := checkArity("random-seed", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		k, ok := args[0].(int64)
+		if !ok || k < 0 || k > 1<<31-1 {
+			return nil, TYPE_ERROR, contractError("random-seed", "(integer-in 0 2147483647)", args[0])
+		}
+		currentRandomGenerator(p).rand.Seed(k)
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("make-pseudo-random-generator", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// racket seeds new generators with the time, they are seeded by the current generator here
+		// so that programs which call random-seed first are repeatable
+		if err := checkArity("make-pseudo-random-generator", args, 0, 0); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return newRandomGenerator(currentRandomGenerator(p).rand.Int63()), TYPE_RANDOM_GENERATOR, nil
+	})
+	defineBuiltin("pseudo-random-generator?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("pseudo-random-generator?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*randomGenerator)
+		return ok, TYPE_BOOLEAN, nil
+	})
+}
@@ -0,0 +1,68 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRandom(t *testing.T) {
+	sample := "(list (random 100) (random 5 10) (random))"
+	first, second := NewInterpreter(WithRandomSeed(42)), NewInterpreter(WithRandomSeed(42))
+	a, _, err := first.Eval(sample)
+	if err != nil {
+		t.Fatal("unexpected evaluation error:", err)
+	}
+	if b, _, _ := second.Eval(sample); !isEqual(a, b) {
+		t.Error("expected interpreters with the same seed to give the same numbers but got", FormatValue(a), "and", FormatValue(b))
+	}
+	second.SetRandomSeed(42)
+	if b, _, _ := second.Eval(sample); !isEqual(a, b) {
+		t.Error("expected SetRandomSeed to restart the numbers but got", FormatValue(b))
+	}
+
+	in := NewInterpreter()
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(begin (random-seed 7) (define l (for/list ([i 5]) (random 1000))) (random-seed 7) (equal? l (for/list ([i 5]) (random 1000))))", "#t"},
+		{"(for/and ([i 100]) (let ([n (random 3)]) (and (exact-integer? n) (>= n 0) (< n 3))))", "#t"},
+		{"(for/and ([i 100]) (let ([n (random -2 2)]) (and (>= n -2) (< n 2))))", "#t"},
+		{"(for/and ([i 100]) (let ([x (random)]) (and (inexact? x) (> x 0) (< x 1))))", "#t"},
+		{"(random 1)", "0"},
+		{"(pseudo-random-generator? (make-pseudo-random-generator))", "#t"},
+		{"(pseudo-random-generator? 1)", "#f"},
+		{"(current-pseudo-random-generator)", "#<pseudo-random-generator>"},
+		// generators made after the same seed give the same numbers
+		{"(begin (random-seed 1) (define g (make-pseudo-random-generator)) (random-seed 1) (= (random 1000 g) (random 1000 (make-pseudo-random-generator))))", "#t"},
+		{"(let ([g (make-pseudo-random-generator)]) (current-pseudo-random-generator g) (eq? g (current-pseudo-random-generator)))", "#t"},
+	}
+	for _, c := range cases {
+		if result, _, err := in.Eval(c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(random 0)", "random: contract violation\n  expected: (or/c (integer-in 1 4294967087) pseudo-random-generator?)\n  given: 0"},
+		{"(random 1.5)", "random: contract violation\n  expected: (or/c (integer-in 1 4294967087) pseudo-random-generator?)\n  given: 1.5"},
+		{"(random 5 5)", "random: contract violation\n  expected: (integer-in 6 4294967092)\n  given: 5"},
+		{"(random 1 2 3)", "random: contract violation\n  expected: pseudo-random-generator?\n  given: 3"},
+		{"(random-seed -1)", "random-seed: contract violation\n  expected: (integer-in 0 2147483647)\n  given: -1"},
+		{"(current-pseudo-random-generator 1)", "current-pseudo-random-generator: contract violation\n  expected: pseudo-random-generator?\n  given: 1"},
+	}
+	for _, c := range errorCases {
+		_, _, err := in.Eval(c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package minrkt
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Regular expressions are compiled with go's regexp package, which doesn't
+backtrack. The differences with racket's regexps are:
+  - backreferences like \1 and lookaround like (?=x) or (?<!x) aren't
+    supported, compiling them is an error
+  - patterns only match strings, byte patterns like #rx#"a" don't exist
+  - in #px patterns, \p{Ll} and the like use go's unicode classes
+Like in racket, . matches newlines, ^ and $ match at the start and the end of
+the input, and #rx patterns treat \ followed by a letter as the letter and {
+as a character.
+*/
+
+// value of (regexp "a+"), #rx"a+", (pregexp "\\d+") or #px"\\d+"
+type regexpValue struct {
+	source  string
+	pregexp bool
+	re      *regexp.Regexp
+}
+
+// the go syntax of a racket pattern
+func translateRegexp(source string, pregexp bool) (string, error) {
+	var sb strings.Builder
+	// . matches newlines
+	sb.WriteString("(?s)")
+	runes := []rune(source)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			next := runes[i]
+			switch {
+			case next >= '0' && next <= '9':
+				return "", &syntax.Error{Code: "backreferences are not supported", Expr: string(runes[i-1 : i+1])}
+			case !pregexp && unicode.IsLetter(next):
+				sb.WriteRune(next)
+			case pregexp && unicode.IsLetter(next) && !strings.ContainsRune("dDwWsSbBpP", next):
+				return "", &syntax.Error{Code: "illegal alphabetic escape", Expr: string(runes[i-1 : i+1])}
+			case unicode.IsLetter(next):
+				// \d, \w, \p{Ll} and the like of #px patterns
+				sb.WriteRune('\\')
+				sb.WriteRune(next)
+			default:
+				sb.WriteString(quoteRune(next))
+			}
+		case !pregexp && (r == '{' || r == '}'):
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '[':
+			// a range, ] right after [ or [^ is a character of the range
+			sb.WriteRune(r)
+			start := i + 1
+			if start < len(runes) && runes[start] == '^' {
+				start++
+			}
+			for i++; i < len(runes); i++ {
+				r = runes[i]
+				if r == ']' && i > start {
+					break
+				}
+				if r == '[' && i+1 < len(runes) && runes[i+1] == ':' {
+					// [:alpha:] classes
+					if end := strings.Index(string(runes[i:]), ":]"); end >= 0 {
+						class := string(runes[i:])[:end+2]
+						sb.WriteString(class)
+						i += utf8.RuneCountInString(class) - 1
+						continue
+					}
+				}
+				if r == '\\' {
+					if !pregexp || i+1 >= len(runes) {
+						// in #rx ranges \ is a character
+						sb.WriteString(`\\`)
+						continue
+					}
+					i++
+					if unicode.IsLetter(runes[i]) {
+						// \d and the like
+						sb.WriteRune('\\')
+						sb.WriteRune(runes[i])
+					} else {
+						sb.WriteString(quoteRune(runes[i]))
+					}
+					continue
+				}
+				sb.WriteRune(r)
+			}
+			if i < len(runes) {
+				sb.WriteRune(']')
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// go only accepts \ before punctuation, racket accepts it before any character
+func quoteRune(r rune) string {
+	if r < utf8.RuneSelf && (unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+		return "\\" + string(r)
+	}
+	return string(r)
+}
+
+func compileRegexp(proc string, source string, pregexp bool) (*regexpValue, error) {
+	pattern, err := translateRegexp(source, pregexp)
+	if err == nil {
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(pattern); err == nil {
+			return &regexpValue{source: source, pregexp: pregexp, re: re}, nil
+		}
+	}
+	if serr, ok := err.(*syntax.Error); ok {
+		return nil, newRacketError(ERR_CONTRACT, proc, "%s: `%s`", serr.Code, strings.TrimPrefix(serr.Expr, "(?s)"))
+	}
+	return nil, newRacketError(ERR_CONTRACT, proc, "%v", err)
+}
+
+// #rx"a+" or #px"\\d+"
+func parseRegexpLiteral(text string) (*regexpValue, error) {
+	source, err := strconv.Unquote(text[3:])
+	if err != nil {
+		return nil, err
+	}
+	return compileRegexp("read", source, text[1] == 'p')
+}
+
+// the start of a regexp literal, for read
+func isRegexpPrefix(text string) bool {
+	return strings.HasSuffix(text, "#rx") || strings.HasSuffix(text, "#px")
+}
+
+// racket prints #rx"a+" and #px"\\d+"
+func formatRegexp(r *regexpValue) string {
+	prefix := "#rx"
+	if r.pregexp {
+		prefix = "#px"
+	}
+	return prefix + strconv.Quote(r.source)
+}
+
+// the pattern argument is a regexp or a string, which is compiled like (regexp s)
+func regexpArg(proc string, arg interface{}) (*regexpValue, error) {
+	switch pattern := arg.(type) {
+	case *regexpValue:
+		return pattern, nil
+	case string:
+		return compileRegexp(proc, pattern, false)
+	}
+	return nil, contractError(proc, "(or/c regexp? string?)", arg)
+}
+
+// the part of the input between the optional start and end arguments at index i, and the index of its start
+func regexpInput(proc string, args []interface{}, i int) (string, int, error) {
+	s, err := stringArg(proc, args[i-1])
+	if err != nil {
+		return "", 0, err
+	}
+	is := indexString(s)
+	start, end := 0, is.length()
+	if len(args) > i {
+		if start, err = naturalArg(proc, args[i]); err != nil {
+			return "", 0, err
+		}
+		if start > is.length() {
+			return "", 0, stringIndexError(proc, start, is)
+		}
+	}
+	if len(args) > i+1 {
+		if end, err = naturalArg(proc, args[i+1]); err != nil {
+			return "", 0, err
+		}
+		if end < start || end > is.length() {
+			return "", 0, stringIndexError(proc, end, is)
+		}
+	}
+	return is.slice(start, end), start, nil
+}
+
+// the matched strings of a match found by FindStringSubmatchIndex, #f for the groups which didn't match
+func matchGroups(s string, loc []int) []interface{} {
+	groups := make([]interface{}, len(loc)/2)
+	for g := range groups {
+		if loc[2*g] < 0 {
+			groups[g] = false
+		} else {
+			groups[g] = s[loc[2*g]:loc[2*g+1]]
+		}
+	}
+	return groups
+}
+
+// the insert of (regexp-replace pattern input insert): & and \0 are the match, \n the group n
+func expandInsert(insert string, groups []interface{}) string {
+	var sb strings.Builder
+	group := func(n int) {
+		if n < len(groups) {
+			if s, ok := groups[n].(string); ok {
+				sb.WriteString(s)
+			}
+		}
+	}
+	for i := 0; i < len(insert); i++ {
+		c := insert[i]
+		switch {
+		case c == '&':
+			group(0)
+		case c == '\\' && i+1 < len(insert):
+			i++
+			if next := insert[i]; next >= '0' && next <= '9' {
+				group(int(next - '0'))
+			} else {
+				sb.WriteByte(next)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// (regexp-replace pattern input insert) and (regexp-replace* pattern input insert)
+func defineRegexpReplace(name string, all bool) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(name, args, 3, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg(name, args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg(name, args[1])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		insert, isString := args[2].(string)
+		if !isString && typeOf(args[2]) != TYPE_PROCEDURE {
+			return nil, TYPE_ERROR, contractError(name, "(or/c string? procedure?)", args[2])
+		}
+		n := 1
+		if all {
+			n = -1
+		}
+		var sb strings.Builder
+		last := 0
+		for _, loc := range pattern.re.FindAllStringSubmatchIndex(s, n) {
+			sb.WriteString(s[last:loc[0]])
+			groups := matchGroups(s, loc)
+			if isString {
+				sb.WriteString(expandInsert(insert, groups))
+			} else {
+				// the procedure is called with the match and the groups
+				got, _, err := applySingle(args[2], groups, p)
+				if err != nil {
+					return nil, TYPE_ERROR, err
+				}
+				replacement, ok := got.(string)
+				if !ok {
+					return nil, TYPE_ERROR, contractError(name, "string?", got)
+				}
+				sb.WriteString(replacement)
+			}
+			last = loc[1]
+		}
+		sb.WriteString(s[last:])
+		return sb.String(), TYPE_STRING, nil
+	})
+}
+
+func init() {
+	for name, pregexp := range map[string]bool{"regexp": false, "pregexp": true} {
+		name, pregexp := name, pregexp
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			source, err := stringArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			r, err := compileRegexp(name, source, pregexp)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return r, TYPE_REGEXP, nil
+		})
+		defineBuiltin(name+"?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			// pregexps are regexps too
+			if err := checkArity(name+"?", args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			r, ok := args[0].(*regexpValue)
+			return ok && (r.pregexp || !pregexp), TYPE_BOOLEAN, nil
+		})
+	}
+	defineBuiltin("regexp-match", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (regexp-match pattern input [start end]) is the list of the match and its groups, or #f
+		if err := checkArity("regexp-match", args, 2, 4); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg("regexp-match", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, _, err := regexpInput("regexp-match", args, 2)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		loc := pattern.re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			return false, TYPE_BOOLEAN, nil
+		}
+		return sliceToList(matchGroups(s, loc)), TYPE_LIST, nil
+	})
+	defineBuiltin("regexp-match?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("regexp-match?", args, 2, 4); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg("regexp-match?", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, _, err := regexpInput("regexp-match?", args, 2)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return pattern.re.MatchString(s), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("regexp-match*", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the list of all the matches, without their groups
+		if err := checkArity("regexp-match*", args, 2, 4); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg("regexp-match*", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, _, err := regexpInput("regexp-match*", args, 2)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var matches []interface{}
+		for _, m := range pattern.re.FindAllString(s, -1) {
+			matches = append(matches, m)
+		}
+		return sliceToList(matches), TYPE_LIST, nil
+	})
+	defineBuiltin("regexp-match-positions", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// pairs of the start and end positions, in characters from the start of the input, of the match and its groups
+		if err := checkArity("regexp-match-positions", args, 2, 4); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg("regexp-match-positions", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, offset, err := regexpInput("regexp-match-positions", args, 2)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		loc := pattern.re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			return false, TYPE_BOOLEAN, nil
+		}
+		positions := make([]interface{}, len(loc)/2)
+		for g := range positions {
+			if loc[2*g] < 0 {
+				positions[g] = false
+				continue
+			}
+			start := offset + utf8.RuneCountInString(s[:loc[2*g]])
+			end := offset + utf8.RuneCountInString(s[:loc[2*g+1]])
+			positions[g] = &pair{car: int64(start), cdr: int64(end)}
+		}
+		return sliceToList(positions), TYPE_LIST, nil
+	})
+	defineRegexpReplace("regexp-replace", false)
+	defineRegexpReplace("regexp-replace*", true)
+	defineBuiltin("regexp-split", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// the parts of the input between the matches, (regexp-split #rx"," "a,,b") -> '("a" "" "b")
+		if err := checkArity("regexp-split", args, 2, 4); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pattern, err := regexpArg("regexp-split", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, _, err := regexpInput("regexp-split", args, 2)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var parts []interface{}
+		last := 0
+		for _, loc := range pattern.re.FindAllStringIndex(s, -1) {
+			parts = append(parts, s[last:loc[0]])
+			last = loc[1]
+		}
+		parts = append(parts, s[last:])
+		return sliceToList(parts), TYPE_LIST, nil
+	})
+	defineBuiltin("regexp-quote", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// a pattern matching the string literally
+		if err := checkArity("regexp-quote", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("regexp-quote", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return regexp.QuoteMeta(s), TYPE_STRING, nil
+	})
+}
@@ -0,0 +1,79 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegexps(t *testing.T) {
+	p := newTestParams()
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"#rx\"a+\"", "#rx\"a+\""},
+		{`#px"\\d+"`, `#px"\\d+"`},
+		{"(regexp \"a|b\")", "#rx\"a|b\""},
+		{"(regexp? #px\"a\")", "#t"},
+		{"(pregexp? #rx\"a\")", "#f"},
+		{"(equal? #rx\"a\" (regexp \"a\"))", "#t"},
+		{"(regexp-match #rx\"(a+)(b)?\" \"xaac\")", "'(\"aa\" \"aa\" #f)"},
+		{"(regexp-match \"x(.)\" \"axbxc\")", "'(\"xb\" \"b\")"},
+		{"(regexp-match #rx\"z\" \"abc\")", "#f"},
+		{`(regexp-match #px"(\\d+)-(\\d+)" "from 10-20")`, `'("10-20" "10" "20")`},
+		{`(regexp-match #rx"\\d" "ad1")`, `'("d")`},
+		{`(regexp-match #rx"a{2}" "a{2}")`, `'("a{2}")`},
+		{`(regexp-match #px"a{2}" "baab")`, `'("aa")`},
+		{`(regexp-match #px"[[:digit:]]+" "ab12")`, `'("12")`},
+		{`(regexp-match #px"\\p{Lu}" "aλΛ")`, `'("Λ")`},
+		{"(regexp-match #rx\"a.b\" \"a\\nb\")", "'(\"a\\nb\")"},
+		{"(regexp-match #rx\"^b\" \"ab\" 1)", "'(\"b\")"},
+		{"(regexp-match #rx\"b$\" \"abc\" 0 2)", "'(\"b\")"},
+		{"(regexp-match? #rx\"b\" \"abc\")", "#t"},
+		{"(regexp-match* #rx\"[0-9]+\" \"a1b22c333\")", "'(\"1\" \"22\" \"333\")"},
+		{"(regexp-match* #rx\"x\" \"abc\")", "'()"},
+		{"(regexp-match-positions #rx\"(b+)\" \"λabbc\")", "'((2 . 4) (2 . 4))"},
+		{"(regexp-match-positions #rx\"c\" \"abc\" 1)", "'((2 . 3))"},
+		{"(regexp-replace #rx\"o\" \"foo\" \"0\")", "\"f0o\""},
+		{"(regexp-replace* #rx\"o\" \"foo\" \"0\")", "\"f00\""},
+		{`(regexp-replace* #rx"([a-z]+)=([0-9]+)" "a=1 b=2" "\\2:\\1")`, `"1:a 2:b"`},
+		{`(regexp-replace #rx"b+" "abbc" "[&]\\&")`, `"a[bb]&c"`},
+		{"(regexp-replace* #rx\"[0-9]+\" \"a1b22\" (lambda (m) (number->string (* 2 (string->number m)))))", "\"a2b44\""},
+		{"(regexp-split #rx\",\" \"a,b,,c\")", "'(\"a\" \"b\" \"\" \"c\")"},
+		{"(regexp-split #rx\" +\" \" a  b \")", "'(\"\" \"a\" \"b\" \"\")"},
+		{"(regexp-split #rx\"\" \"abc\")", "'(\"\" \"a\" \"b\" \"c\" \"\")"},
+		{"(regexp-quote \"a.b*\")", `"a\\.b\\*"`},
+		{"(regexp-match (regexp (regexp-quote \"1+1\")) \"11 1+1\")", "'(\"1+1\")"},
+		{"(read (open-input-string \"(#rx\\\"a b\\\" 1)\"))", "'(#rx\"a b\" 1)"},
+		{"(hash-ref (hash #rx\"a\" 1) (regexp \"a\"))", "1"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(regexp \"(a\")", "regexp: missing closing ): `(a`"},
+		{`(pregexp "(a)\\1")`, "pregexp: backreferences are not supported: `\\1`"},
+		{"(regexp \"(?=a)\")", "regexp: invalid or unsupported Perl syntax: `(?=`"},
+		{`(pregexp "\\q")`, "pregexp: illegal alphabetic escape: `\\q`"},
+		{"(regexp-match 1 \"a\")", "regexp-match: contract violation\n  expected: (or/c regexp? string?)\n  given: 1"},
+		{"(regexp-match #rx\"a\" 'a)", "regexp-match: contract violation\n  expected: string?\n  given: 'a"},
+		{"(regexp-replace #rx\"a\" \"a\" 1)", "regexp-replace: contract violation\n  expected: (or/c string? procedure?)\n  given: 1"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
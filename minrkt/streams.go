@@ -0,0 +1,298 @@
+package minrkt
+
+// result of delay, the thunk is evaluated once by the first force
+type promise struct {
+	thunk  interface{}
+	value  interface{}
+	forced bool
+	// true while the thunk is evaluated, forcing the promise again is an error
+	running bool
+}
+
+// result of stream-cons, both parts are evaluated when they are first needed
+type streamPair struct {
+	first *promise
+	rest  *promise
+}
+
+// result of in-range, the numbers are computed one at a time
+type rangeStream struct {
+	start interface{}
+	end   interface{}
+	step  interface{}
+}
+
+func (r rangeStream) empty() bool {
+	if numCompare(">=", r.step, int64(0)) {
+		return numCompare(">=", r.start, r.end)
+	}
+	return numCompare("<=", r.start, r.end)
+}
+
+func force(pr *promise, p Params) (interface{}, error) {
+	if pr.forced {
+		return pr.value, nil
+	}
+	if pr.running {
+		return nil, newRacketError(ERR_FAIL, "force", "reentrant promise")
+	}
+	pr.running = true
+	val, _, err := applyProcedure(pr.thunk, nil, p)
+	pr.running = false
+	if err != nil {
+		return nil, err
+	}
+	pr.value, pr.forced, pr.thunk = val, true, nil
+	return val, nil
+}
+
+// (delay body ...) and the parts of (stream-cons first rest) are zero argument functions
+func newPromise(form string, e *ExpOperator, body []Exp, p Params) (*promise, error) {
+	fv, err := newFunction(form, "", nil, body, p)
+	if err != nil {
+		return nil, err
+	}
+	fv.line, fv.col = e.line, e.col
+	return &promise{thunk: fv}, nil
+}
+
+func evalDelay(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) == 0 {
+		return nil, TYPE_ERROR, syntaxError("delay", "bad syntax")
+	}
+	if pr, err := newPromise("delay", e, e.operands, p); err != nil {
+		return nil, TYPE_ERROR, err
+	} else {
+		return pr, TYPE_PROMISE, nil
+	}
+}
+
+func evalStreamCons(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) != 2 {
+		return nil, TYPE_ERROR, syntaxError("stream-cons", "bad syntax")
+	}
+	first, err := newPromise("stream-cons", e, e.operands[:1], p)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	rest, err := newPromise("stream-cons", e, e.operands[1:], p)
+	if err != nil {
+		return nil, TYPE_ERROR, err
+	}
+	return &streamPair{first: first, rest: rest}, TYPE_STREAM, nil
+}
+
+// lists are streams too
+func isStream(v interface{}) bool {
+	switch v.(type) {
+	case *streamPair, rangeStream, emptyList:
+		return true
+	case *pair:
+		_, ok := listToSlice(v)
+		return ok
+	}
+	return false
+}
+
+func streamEmpty(s interface{}) bool {
+	switch got := s.(type) {
+	case emptyList:
+		return true
+	case rangeStream:
+		return got.empty()
+	}
+	return false
+}
+
+// first element and the rest of a non empty stream, lists are checked by the callers
+func streamNext(proc string, s interface{}, p Params) (interface{}, interface{}, error) {
+	sp, ok := s.(*streamPair)
+	switch got := s.(type) {
+	case *pair:
+		return got.car, got.cdr, nil
+	case rangeStream:
+		if !got.empty() {
+			return got.start, rangeStream{numAdd(got.start, got.step), got.end, got.step}, nil
+		}
+	}
+	if !ok {
+		return nil, nil, contractError(proc, "(and/c stream? (not/c stream-empty?))", s)
+	}
+	first, err := force(sp.first, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, err := force(sp.rest, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isStream(rest) {
+		return nil, nil, contractError(proc, "stream?", rest)
+	}
+	return first, rest, nil
+}
+
+func init() {
+	defineBuiltin("force", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("force", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		// values which aren't promises are returned as they are
+		pr, ok := args[0].(*promise)
+		if !ok {
+			return args[0], typeOf(args[0]), nil
+		}
+		val, err := force(pr, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return val, typeOf(val), nil
+	})
+	defineBuiltin("make-promise", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("make-promise", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return &promise{value: args[0], forced: true}, TYPE_PROMISE, nil
+	})
+	defineBuiltin("promise?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("promise?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*promise)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("promise-forced?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("promise-forced?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		pr, ok := args[0].(*promise)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("promise-forced?", "promise?", args[0])
+		}
+		return pr.forced, TYPE_BOOLEAN, nil
+	})
+	builtins["empty-stream"] = null
+	defineBuiltin("stream?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("stream?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return isStream(args[0]), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("stream-empty?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("stream-empty?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if !isStream(args[0]) {
+			return nil, TYPE_ERROR, contractError("stream-empty?", "stream?", args[0])
+		}
+		return streamEmpty(args[0]), TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("stream-first", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("stream-first", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if sp, ok := args[0].(*streamPair); ok {
+			// the rest isn't needed yet
+			val, err := force(sp.first, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return val, typeOf(val), nil
+		}
+		if !isStream(args[0]) {
+			return nil, TYPE_ERROR, contractError("stream-first", "(and/c stream? (not/c stream-empty?))", args[0])
+		}
+		first, _, err := streamNext("stream-first", args[0], p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return first, typeOf(first), nil
+	})
+	defineBuiltin("stream-rest", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("stream-rest", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if sp, ok := args[0].(*streamPair); ok {
+			rest, err := force(sp.rest, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			if !isStream(rest) {
+				return nil, TYPE_ERROR, contractError("stream-rest", "stream?", rest)
+			}
+			return rest, typeOf(rest), nil
+		}
+		if !isStream(args[0]) {
+			return nil, TYPE_ERROR, contractError("stream-rest", "(and/c stream? (not/c stream-empty?))", args[0])
+		}
+		_, rest, err := streamNext("stream-rest", args[0], p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return rest, typeOf(rest), nil
+	})
+	defineBuiltin("stream->list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("stream->list", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if !isStream(args[0]) {
+			return nil, TYPE_ERROR, contractError("stream->list", "stream?", args[0])
+		}
+		var values []interface{}
+		for s := args[0]; !streamEmpty(s); {
+			first, rest, err := streamNext("stream->list", s, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			values = append(values, first)
+			s = rest
+		}
+		return sliceToList(values), TYPE_LIST, nil
+	})
+	defineBuiltin("stream-take", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (stream-take s n) is the list of the first n elements, for infinite streams
+		if err := checkArity("stream-take", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		n, err := naturalArg("stream-take", args[1])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if !isStream(args[0]) {
+			return nil, TYPE_ERROR, contractError("stream-take", "stream?", args[0])
+		}
+		var values []interface{}
+		s := args[0]
+		for i := 0; i < n; i++ {
+			first, rest, err := streamNext("stream-take", s, p)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			values = append(values, first)
+			s = rest
+		}
+		return sliceToList(values), TYPE_LIST, nil
+	})
+	defineBuiltin("in-range", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (in-range end), (in-range start end) or (in-range start end step)
+		if err := checkArity("in-range", args, 1, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if !isNumber(arg) {
+				return nil, TYPE_ERROR, contractError("in-range", "real?", arg)
+			}
+		}
+		r := rangeStream{start: int64(0), step: int64(1)}
+		switch len(args) {
+		case 1:
+			r.end = args[0]
+		case 3:
+			r.step = args[2]
+			fallthrough
+		case 2:
+			r.start, r.end = args[0], args[1]
+		}
+		return r, TYPE_STREAM, nil
+	})
+}
@@ -0,0 +1,64 @@
+package minrkt
+
+import (
+	"testing"
+)
+
+func TestStreams(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define count 0)")
+	evalLine(p, "(define pr (delay (set-count) (* 6 7)))")
+	evalLine(p, "(define (nats n) (stream-cons n (nats (+ n 1))))")
+	evalLine(p, "(define (stream-filter f s) (if (f (stream-first s)) (stream-cons (stream-first s) (stream-filter f (stream-rest s))) (stream-filter f (stream-rest s))))")
+	// bound in the session like a definition, the builtins stay the same for the other tests
+	p.MapIdentifier["set-count"] = builtinValue{"set-count", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		p.MapIdentifier["count"] = p.MapIdentifier["count"].(int64) + 1
+		return void, TYPE_VOID, nil
+	}}
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"(promise? pr)", "#t"},
+		{"(promise-forced? pr)", "#f"},
+		{"(force pr)", "42"},
+		// the body is evaluated once
+		{"(force pr)", "42"},
+		{"count", "1"},
+		{"(force 5)", "5"},
+		{"(force (make-promise 'a))", "'a"},
+		{"pr", "#<promise>"},
+		{"(stream-first (stream-cons 1 (car '())))", "1"},
+		{"(stream-first (stream-rest (nats 0)))", "1"},
+		{"(stream-take (nats 5) 3)", "'(5 6 7)"},
+		{"(stream-take (stream-filter (lambda (x) (> x 3)) (nats 1)) 3)", "'(4 5 6)"},
+		{"(stream->list (stream-cons 1 (stream-cons 2 empty-stream)))", "'(1 2)"},
+		{"(stream->list '(1 2))", "'(1 2)"},
+		{"(stream-empty? empty-stream)", "#t"},
+		{"(stream? (nats 0))", "#t"},
+		{"(stream? 1)", "#f"},
+		{"(stream->list (in-range 3))", "'(0 1 2)"},
+		{"(stream->list (in-range 1 10 4))", "'(1 5 9)"},
+		{"(stream->list (in-range 3 0 -1))", "'(3 2 1)"},
+		{"(stream-first (stream-rest (in-range 5 1000000000000)))", "6"},
+		{"(stream-empty? (in-range 2 2))", "#t"},
+		{"(nats 0)", "#<stream>"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	for _, line := range []string{"(stream-first empty-stream)", "(stream-rest (stream-cons 1 2))", "(stream->list 1)"} {
+		if _, _, err := evalLine(p, line); err == nil {
+			t.Error("expected evaluation error doesn't show up for expression", line)
+		}
+	}
+	evalLine(p, "(define r (delay (force r)))")
+	if _, _, err := evalLine(p, "(force r)"); err == nil || err.Error() != "force: reentrant promise" {
+		t.Error("expected a reentrant promise error but got", err)
+	}
+}
@@ -0,0 +1,321 @@
+package minrkt
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+)
+
+/*
+Strings are immutable go strings holding UTF-8 text. Like in racket they are
+sequences of unicode code points: lengths and indexes count characters, not
+bytes, e.g. (string-length "λx") is 2.
+*/
+
+func stringArg(proc string, arg interface{}) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", contractError(proc, "string?", arg)
+	}
+	return s, nil
+}
+
+/*
+The characters of a string which isn't ASCII aren't at the index of their
+bytes, its code points are decoded to index it. The strings indexed last keep
+them, so that a loop over the characters of a string doesn't decode it at each
+step, e.g. (for ([i (string-length s)]) (string-ref s i)).
+*/
+type indexedString struct {
+	s string
+	// nil for ASCII strings, whose bytes are the characters
+	runes []rune
+}
+
+// strings this long or shorter are decoded each time, their code points aren't kept
+const shortString = 32
+
+var indexedStrings struct {
+	sync.Mutex
+	last [8]*indexedString
+	next int
+}
+
+func newIndexedString(s string) *indexedString {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return &indexedString{s: s, runes: []rune(s)}
+		}
+	}
+	return &indexedString{s: s}
+}
+
+func indexString(s string) *indexedString {
+	if len(s) <= shortString {
+		return newIndexedString(s)
+	}
+	indexedStrings.Lock()
+	defer indexedStrings.Unlock()
+	// the same bytes, comparing the contents would cost as much as decoding them
+	data := (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	for _, is := range indexedStrings.last {
+		if is != nil && len(is.s) == len(s) && (*reflect.StringHeader)(unsafe.Pointer(&is.s)).Data == data {
+			return is
+		}
+	}
+	is := newIndexedString(s)
+	indexedStrings.last[indexedStrings.next] = is
+	indexedStrings.next = (indexedStrings.next + 1) % len(indexedStrings.last)
+	return is
+}
+
+// number of characters
+func (is *indexedString) length() int {
+	if is.runes == nil {
+		return len(is.s)
+	}
+	return len(is.runes)
+}
+
+func (is *indexedString) at(i int) rune {
+	if is.runes == nil {
+		return rune(is.s[i])
+	}
+	return is.runes[i]
+}
+
+// the characters from start to end
+func (is *indexedString) slice(start, end int) string {
+	if is.runes == nil {
+		return is.s[start:end]
+	}
+	return string(is.runes[start:end])
+}
+
+// (string<? a b c) like the n-ary numeric comparisons
+func defineStringComparison(name string, compare func(a, b string) bool) {
+	defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(name, args, 1, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for _, arg := range args {
+			if _, err := stringArg(name, arg); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+		}
+		for i := 1; i < len(args); i++ {
+			if !compare(args[i-1].(string), args[i].(string)) {
+				return false, TYPE_BOOLEAN, nil
+			}
+		}
+		return true, TYPE_BOOLEAN, nil
+	})
+}
+
+func stringIndexError(proc string, index int, is *indexedString) *RacketError {
+	if is.length() == 0 {
+		return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "index is out of range for empty string",
+			Fields: []ErrorField{{"index", fmt.Sprint(index)}}}
+	}
+	return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "index is out of range",
+		Fields: []ErrorField{{"index", fmt.Sprint(index)}, {"valid range", fmt.Sprintf("[0, %d]", is.length()-1)},
+			{"string", FormatValue(is.s)}}}
+}
+
+// decimal numbers like the ones of the tokenizer, with an optional exponent, and +inf.0, -inf.0 and +nan.0
+var numberRe = regexp.MustCompile(`^(?:[+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?|[+-](?:inf|nan)\.0)$`)
+
+// exact fractions, e.g. 1/3
+var fractionRe = regexp.MustCompile(`^[+-]?[0-9]+/[0-9]+$`)
+
+func init() {
+	defineBuiltin("string-length", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// strings are sequences of characters, not of bytes
+		if err := checkArity("string-length", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string-length", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return int64(indexString(s).length()), TYPE_FLOAT64, nil
+	})
+	defineBuiltin("substring", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (substring s start [end])
+		if err := checkArity("substring", args, 2, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("substring", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		is := indexString(s)
+		start, err := naturalArg("substring", args[1])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		end := is.length()
+		if len(args) == 3 {
+			if end, err = naturalArg("substring", args[2]); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+		}
+		if start > is.length() {
+			return nil, TYPE_ERROR, &RacketError{Kind: ERR_CONTRACT, Proc: "substring", Message: "starting index is out of range",
+				Fields: []ErrorField{{"starting index", fmt.Sprint(start)}, {"valid range", fmt.Sprintf("[0, %d]", is.length())}, {"string", FormatValue(s)}}}
+		}
+		if end < start || end > is.length() {
+			return nil, TYPE_ERROR, &RacketError{Kind: ERR_CONTRACT, Proc: "substring", Message: "ending index is out of range",
+				Fields: []ErrorField{{"ending index", fmt.Sprint(end)}, {"starting index", fmt.Sprint(start)},
+					{"valid range", fmt.Sprintf("[0, %d]", is.length())}, {"string", FormatValue(s)}}}
+		}
+		return is.slice(start, end), TYPE_STRING, nil
+	})
+	defineBuiltin("string-ref", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("string-ref", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string-ref", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		i, err := naturalArg("string-ref", args[1])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		is := indexString(s)
+		if i >= is.length() {
+			return nil, TYPE_ERROR, stringIndexError("string-ref", i, is)
+		}
+		return char(is.at(i)), TYPE_CHAR, nil
+	})
+	defineBuiltin("string->list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("string->list", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string->list", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var chars []interface{}
+		for _, r := range s {
+			chars = append(chars, char(r))
+		}
+		return sliceToList(chars), TYPE_LIST, nil
+	})
+	defineBuiltin("list->string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("list->string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		l, ok := listToSlice(args[0])
+		if !ok {
+			return nil, TYPE_ERROR, contractError("list->string", "(listof char?)", args[0])
+		}
+		var sb strings.Builder
+		for _, element := range l {
+			c, ok := element.(char)
+			if !ok {
+				return nil, TYPE_ERROR, contractError("list->string", "(listof char?)", args[0])
+			}
+			sb.WriteRune(rune(c))
+		}
+		return sb.String(), TYPE_STRING, nil
+	})
+	defineBuiltin("string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (string #\a #\b) -> "ab"
+		var sb strings.Builder
+		for _, arg := range args {
+			c, err := charArg("string", arg)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			sb.WriteRune(rune(c))
+		}
+		return sb.String(), TYPE_STRING, nil
+	})
+	defineBuiltin("string-append", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			s, err := stringArg("string-append", arg)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			sb.WriteString(s)
+		}
+		return sb.String(), TYPE_STRING, nil
+	})
+	defineStringComparison("string=?", func(a, b string) bool { return a == b })
+	defineStringComparison("string<?", func(a, b string) bool { return a < b })
+	defineStringComparison("string>?", func(a, b string) bool { return a > b })
+	for name, convert := range map[string]func(string) string{"string-upcase": strings.ToUpper, "string-downcase": strings.ToLower} {
+		name, convert := name, convert
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			s, err := stringArg(name, args[0])
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			return convert(s), TYPE_STRING, nil
+		})
+	}
+	defineBuiltin("string->symbol", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("string->symbol", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string->symbol", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return symbol(s), TYPE_SYMBOL, nil
+	})
+	defineBuiltin("symbol->string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("symbol->string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, ok := args[0].(symbol)
+		if !ok {
+			return nil, TYPE_ERROR, contractError("symbol->string", "symbol?", args[0])
+		}
+		return string(s), TYPE_STRING, nil
+	})
+	defineBuiltin("number->string", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("number->string", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if _, err := numberArg("number->string", args[0]); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return formatDatum(args[0]), TYPE_STRING, nil
+	})
+	defineBuiltin("string->number", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// #f when the string isn't a number
+		if err := checkArity("string->number", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, err := stringArg("string->number", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if fractionRe.MatchString(s) {
+			// 1/0 isn't a number
+			if r, ok := new(big.Rat).SetString(strings.TrimPrefix(s, "+")); ok {
+				return normalizeRat(r), TYPE_FLOAT64, nil
+			}
+			return false, TYPE_BOOLEAN, nil
+		}
+		if !numberRe.MatchString(s) {
+			return false, TYPE_BOOLEAN, nil
+		}
+		// the error of a number out of range, e.g. 1e400, comes with the infinity racket reads
+		n, _ := strconv.ParseFloat(s, 64)
+		return literalNumber(s, n), TYPE_FLOAT64, nil
+	})
+}
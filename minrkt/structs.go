@@ -0,0 +1,245 @@
+package minrkt
+
+import (
+	"strings"
+)
+
+// type created by (struct name (field ...)), struct:name is bound to it
+type structType struct {
+	name   string
+	parent *structType
+	// fields of this type, the parent's fields come first in the instances
+	fields  []string
+	mutable []bool
+	// transparent structs print their fields and are compared field-wise by equal?
+	transparent bool
+	// set for exn and its subtypes, raising an instance is an exception of this kind
+	exnKind ErrorKind
+}
+
+type structValue struct {
+	stype  *structType
+	fields []interface{}
+}
+
+// number of fields of the instances, including the parent's
+func (st *structType) fieldCount() int {
+	if st.parent == nil {
+		return len(st.fields)
+	}
+	return st.parent.fieldCount() + len(st.fields)
+}
+
+// instances of subtypes are instances of their parents
+func (st *structType) isA(parent *structType) bool {
+	for t := st; t != nil; t = t.parent {
+		if t == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// the type of (struct exn ...) and its subtypes are only used as parents of exceptions defined with struct
+var exnStructTypes = map[ErrorKind]*structType{}
+
+func exnStructType(kind ErrorKind) *structType {
+	if st, ok := exnStructTypes[kind]; ok {
+		return st
+	}
+	st := &structType{name: string(kind), exnKind: kind}
+	if i := strings.LastIndex(string(kind), ":"); i >= 0 {
+		st.parent = exnStructType(kind[:i])
+	} else {
+		st.fields, st.mutable = []string{"message", "continuation-marks"}, []bool{false, false}
+	}
+	exnStructTypes[kind] = st
+	return st
+}
+
+// (struct name [parent] (field ...) #:transparent #:mutable), fields can be [x #:mutable]
+func evalStruct(e *ExpOperator, p Params) (interface{}, TypeEnum, error) {
+	if len(e.operands) < 2 {
+		return nil, TYPE_ERROR, syntaxError("struct", "bad syntax")
+	}
+	id, ok := e.operands[0].(*ExpIdentifier)
+	if !ok {
+		return nil, TYPE_ERROR, syntaxError("struct", "expected an identifier for the structure type name")
+	}
+	st := &structType{name: id.val}
+	operands := e.operands[1:]
+	if parentID, ok := operands[0].(*ExpIdentifier); ok {
+		parent, found, err := lookupIdentifier("struct:"+parentID.val, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if st.parent, ok = parent.(*structType); !found || !ok {
+			return nil, TYPE_ERROR, syntaxError("struct", "parent struct type not defined: %s", parentID.val)
+		}
+		st.exnKind = st.parent.exnKind
+		operands = operands[1:]
+	}
+	if len(operands) == 0 {
+		return nil, TYPE_ERROR, syntaxError("struct", "missing fields")
+	}
+	fields, ok := operands[0].(*ExpOperator)
+	if !ok {
+		return nil, TYPE_ERROR, syntaxError("struct", "bad syntax;\n expected a list of fields")
+	}
+	allMutable := false
+	for _, option := range operands[1:] {
+		kw, ok := option.(*ExpKeyword)
+		switch {
+		case ok && kw.val == "#:transparent":
+			st.transparent = true
+		case ok && kw.val == "#:mutable":
+			allMutable = true
+		default:
+			return nil, TYPE_ERROR, syntaxError("struct", "unrecognized struct-specification keyword: %s", strings.TrimSpace(option.Print()))
+		}
+	}
+	for _, f := range listElements(fields) {
+		switch field := f.(type) {
+		case *ExpIdentifier:
+			st.fields = append(st.fields, field.val)
+			st.mutable = append(st.mutable, allMutable)
+		case *ExpOperator:
+			// [x #:mutable]
+			if field.proc != nil || len(field.operands) != 1 {
+				return nil, TYPE_ERROR, syntaxError("struct", "bad field: %s", strings.TrimSpace(field.Print()))
+			}
+			if kw, ok := field.operands[0].(*ExpKeyword); !ok || kw.val != "#:mutable" {
+				return nil, TYPE_ERROR, syntaxError("struct", "bad field: %s", strings.TrimSpace(field.Print()))
+			}
+			st.fields = append(st.fields, field.opeType)
+			st.mutable = append(st.mutable, true)
+		default:
+			return nil, TYPE_ERROR, syntaxError("struct", "bad field: %s", strings.TrimSpace(f.Print()))
+		}
+	}
+	for name, v := range structBindings(st) {
+		p.MapIdentifier[name] = v
+	}
+	return nil, TYPE_DEFINE, nil
+}
+
+// constructor, predicate, accessors, mutators and struct:name
+func structBindings(st *structType) map[string]interface{} {
+	bindings := map[string]interface{}{"struct:" + st.name: st}
+	n := st.fieldCount()
+	bindings[st.name] = builtinValue{st.name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(st.name, args, n, n); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return &structValue{stype: st, fields: append([]interface{}{}, args...)}, TYPE_STRUCT, nil
+	}}
+	bindings[st.name+"?"] = builtinValue{st.name + "?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity(st.name+"?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, ok := args[0].(*structValue)
+		return ok && s.stype.isA(st), TYPE_BOOLEAN, nil
+	}}
+	offset := n - len(st.fields)
+	for i, field := range st.fields {
+		index := offset + i
+		accessor := st.name + "-" + field
+		bindings[accessor] = builtinValue{accessor, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(accessor, args, 1, 1); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			s, ok := args[0].(*structValue)
+			if !ok || !s.stype.isA(st) {
+				return nil, TYPE_ERROR, contractError(accessor, st.name+"?", args[0])
+			}
+			return s.fields[index], typeOf(s.fields[index]), nil
+		}}
+		if !st.mutable[i] {
+			continue
+		}
+		mutator := "set-" + st.name + "-" + field + "!"
+		bindings[mutator] = builtinValue{mutator, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(mutator, args, 2, 2); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			s, ok := args[0].(*structValue)
+			if !ok || !s.stype.isA(st) {
+				return nil, TYPE_ERROR, contractError(mutator, st.name+"?", args[0])
+			}
+			s.fields[index] = args[1]
+			return void, TYPE_VOID, nil
+		}}
+	}
+	return bindings
+}
+
+// (point 1 2) for transparent structs, #<point> for opaque ones
+func (pr *printer) formatStruct(s *structValue, printValue bool) string {
+	if !s.stype.transparent {
+		return "#<" + s.stype.name + ">"
+	}
+	fields := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		if printValue {
+			fields[i] = pr.print(f)
+		} else {
+			fields[i] = pr.format(f)
+		}
+	}
+	if printValue {
+		return "(" + strings.Join(append([]string{s.stype.name}, fields...), " ") + ")"
+	}
+	return "#(struct:" + strings.Join(append([]string{s.stype.name}, fields...), " ") + ")"
+}
+
+// transparent structs are equal when they have the same type and equal fields
+func structsEqual(a, b *structValue, seen map[equalVisit]bool) bool {
+	if a == b {
+		return true
+	}
+	if !a.stype.transparent || a.stype != b.stype {
+		return false
+	}
+	for i := range a.fields {
+		if !equalWith(a.fields[i], b.fields[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// the value of (current-continuation-marks), exceptions created by programs need one
+type continuationMarks struct{}
+
+func init() {
+	for _, kind := range exnKinds {
+		builtins["struct:"+string(kind)] = exnStructType(kind)
+		// (exn:fail "message" (current-continuation-marks))
+		name := string(kind)
+		exnKind := kind
+		defineBuiltin(name, func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+			if err := checkArity(name, args, 2, 2); err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			message, ok := args[0].(string)
+			if !ok {
+				return nil, TYPE_ERROR, contractError(name, "string?", args[0])
+			}
+			return exnValue{kind: exnKind, message: message}, TYPE_EXCEPTION, nil
+		})
+	}
+	defineBuiltin("current-continuation-marks", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("current-continuation-marks", args, 0, 0); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return continuationMarks{}, TYPE_STRUCT, nil
+	})
+	defineBuiltin("struct?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// like racket, only instances of transparent structs are struct?
+		if err := checkArity("struct?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		s, ok := args[0].(*structValue)
+		return ok && s.stype.transparent, TYPE_BOOLEAN, nil
+	})
+}
@@ -0,0 +1,89 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStructs(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(struct point (x y) #:transparent #:mutable)")
+	evalLine(p, "(struct account (id [balance #:mutable]))")
+	evalLine(p, "(struct point3 point (z) #:transparent)")
+	evalLine(p, "(define pt (point 1 2))")
+	evalLine(p, "(struct bad-input exn:fail (value))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"pt", "(point 1 2)"},
+		{"(point 'a \"s\")", "(point 'a \"s\")"},
+		{"(list pt)", "'(#(struct:point 1 2))"},
+		{"(account 1 100)", "#<account>"},
+		{"(point? pt)", "#t"},
+		{"(point? 1)", "#f"},
+		{"(point-x pt)", "1"},
+		{"(begin (set-point-y! pt 5) (point-y pt))", "5"},
+		{"(let ([a (account 1 100)]) (set-account-balance! a 50) (account-balance a))", "50"},
+		{"(point3 1 2 3)", "(point3 1 2 3)"},
+		{"(point? (point3 1 2 3))", "#t"},
+		{"(point-x (point3 1 2 3))", "1"},
+		{"(point3-z (point3 1 2 3))", "3"},
+		{"(struct? pt)", "#t"},
+		{"(struct? (account 1 2))", "#f"},
+		{"(with-handlers ([bad-input? (lambda (e) (bad-input-value e))]) (raise (bad-input \"bad\" (current-continuation-marks) 42)))", "42"},
+		{"(with-handlers ([exn:fail? exn-message]) (raise (bad-input \"bad\" (current-continuation-marks) 42)))", "\"bad\""},
+		{"(with-handlers ([exn:fail:contract? (lambda (e) 'contract)] [exn? (lambda (e) 'exn)]) (raise (bad-input \"bad\" (current-continuation-marks) 42)))", "'exn"},
+		{"(exn:fail? (exn:fail \"msg\" (current-continuation-marks)))", "#t"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	// transparent structs are compared field-wise, opaque ones by identity
+	for _, c := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"(point 1 '(2))", "(point 1 (list 2))", true},
+		{"(point 1 2)", "(point 1 3)", false},
+		{"(point 1 2)", "(point3 1 2 3)", false},
+		{"(account 1 2)", "(account 1 2)", false},
+	} {
+		a, _, _ := evalLine(p, c.a)
+		b, _, _ := evalLine(p, c.b)
+		if isEqual(a, b) != c.equal {
+			t.Error("expected equality of", c.a, "and", c.b, "is", c.equal)
+		}
+	}
+	h, _, _ := evalLine(p, "(hash (point 1 2) 'found)")
+	key, _, _ := evalLine(p, "(point 1 2)")
+	if v, ok := h.(*hashTable).ref(key); !ok || v != symbol("found") {
+		t.Error("expected transparent structs to be hashed by their fields")
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(point 1)", "point: arity mismatch;\n the expected number of arguments does not match the given number\n  expected: 2\n  given: 1"},
+		{"(point-x 5)", "point-x: contract violation\n  expected: point?\n  given: 5"},
+		{"(set-account-id! (account 1 2) 3)", "set-account-id!: undefined;\n cannot reference an identifier before its definition"},
+		{"(raise (bad-input \"bad input\" (current-continuation-marks) 1))", "bad input"},
+		{"(struct s (x) #:sealed)", "struct: unrecognized struct-specification keyword: #:sealed"},
+		{"(struct s q (x))", "struct: parent struct type not defined: q"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
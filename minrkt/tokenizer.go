@@ -5,15 +5,17 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // “ raw string literal, no special character here
+// [ ] and { } are accepted as equivalents of ( ) like in Racket
 var tokenRegexList = []string{
-	`^(\()`,
-	`^(\))`,
-	`^([\-\+]?0\.[0-9]+)`,
+	`^([\(\[\{])`,
+	`^([\)\]\}])`,
+	`^([\-\+](?:inf|nan)\.0|[\-\+]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)[eE][\-\+]?[0-9]+|[\-\+]?0?\.[0-9]+)`,
 	`^(0)`,
-	`^([\-\+]?[1-9][0-9]*(?:\.[0-9]*)?)`,
+	`^([\-\+]?[1-9][0-9]*(?:\.[0-9]*|/[1-9][0-9]*)?)`,
 	`^(\+)`,
 	`^(\-)`,
 	`^(\*)`,
@@ -26,17 +28,29 @@ var tokenRegexList = []string{
 	`^(<=)`,
 	`^(>)`,
 	`^(<)`,
-	`^(true)`,
-	`^(false)`,
+	`^(true|#true|#t)`,
+	`^(false|#false|#f)`,
 	`^(if)`,
 	`^(define)`,
-	`^([a-zA-Z]([a-zA-Z]|[0-9]|_)*)`,
+	`^((?:\p{L}|[_?])(?:\p{L}|\p{M}|\p{N}|[_\-!?*<>=/:+%])*|\.\.\.|\.\.[0-9]+)`,
+	`^(')`,
+	`^(\.)`,
+	`^(#:\p{L}(?:\p{L}|\p{M}|\p{N}|[_\-!?*<>=/:+%])*)`,
+	`^("(?:[^"\\]|\\.)*")`,
+	`^(#\()`,
+	`^(#\\(?:[a-zA-Z][a-zA-Z0-9]*|.))`,
+	`^(#[rp]x"(?:[^"\\]|\\.)*")`,
+	"^(`)",
+	`^(,@|,)`,
 }
 
 type Token struct {
 	tokenType TokenType
 	num       float64
 	val       string
+	// position in the input, line starts from 1 and col from 0 like racket
+	line int
+	col  int
 }
 
 type TokenType int
@@ -63,11 +77,25 @@ const (
 	TOK_FALSE
 	TOK_IF
 	TOK_DEFINE
-	TOK_IDENTIFIER
+	TOK_IDENTIFIER // also _ and the ellipses ... and ..k of match patterns
+	TOK_QUOTE
+	TOK_DOT        // . of rest arguments and pairs, e.g. (define (f a . rest) ...)
+	TOK_KEYWORD    // #:scale
+	TOK_STRING     // "hello", val keeps the quotes and escapes
+	TOK_VECTOR     // #( starting a vector literal
+	TOK_CHAR       // #\a, #\space or #\u3BB
+	TOK_REGEXP     // #rx"a+" or #px"\\d+"
+	TOK_QUASIQUOTE // ` of quasiquoted data, e.g. `(1 ,x)
+	TOK_UNQUOTE    // , or ,@ in a quasiquoted datum
 )
 
 var re = regexp.MustCompile(strings.Join(tokenRegexList, "|"))
-var wsRe = regexp.MustCompile(`^\s+`)
+
+// whitespaces and ; comments up to the end of the line
+var wsRe = regexp.MustCompile(`^(?:\s|;[^\n]*)+`)
+
+// the identifier rule alone, index 21 of tokenRegexList
+var identifierRe = regexp.MustCompile(tokenRegexList[21])
 
 func NextToken(remainder string, preToken Token) (Token, string, error) {
 	var hasWhitespaces bool = false
@@ -86,12 +114,17 @@ func NextToken(remainder string, preToken Token) (Token, string, error) {
 	}
 	// to match which token type it corresponds to
 	matched_token := matched_arr[0]
-	for i := 1; i < 23; i++ {
+	for i := 1; i <= len(tokenRegexList); i++ {
 		if matched_token == matched_arr[i] {
 			tokenType = getTokenType(i)
 			break
 		}
 	}
+	// keywords are only keywords when they are not the prefix of a longer identifier, e.g. andmap
+	if identifier := identifierRe.FindString(remainder); len(identifier) > len(matched_token) {
+		matched_token = identifier
+		tokenType = TOK_IDENTIFIER
+	}
 
 	remainder = remainder[len(matched_token):]
 	value, _ := strconv.ParseFloat(matched_token, 64)
@@ -153,19 +186,66 @@ func getTokenType(value int) TokenType {
 		return TOK_DEFINE
 	case 22:
 		return TOK_IDENTIFIER
+	case 23:
+		return TOK_QUOTE
+	case 24:
+		return TOK_DOT
+	case 25:
+		return TOK_KEYWORD
+	case 26:
+		return TOK_STRING
+	case 27:
+		return TOK_VECTOR
+	case 28:
+		return TOK_CHAR
+	case 29:
+		return TOK_REGEXP
+	case 30:
+		return TOK_QUASIQUOTE
+	case 31:
+		return TOK_UNQUOTE
 	}
 	return TOK_INVALID
 }
 
+// opening bracket and its position in the line, used to detect mismatched brackets
+type openBracket struct {
+	val string
+	pos int
+}
+
+var closingBracket = map[string]string{"(": ")", "[": "]", "{": "}", "#(": ")"}
+
 func Tokenize(line string) ([]Token, error) {
 	remainder := line
 	var tokens []Token
 	var preToken Token
+	var brackets []openBracket
+	// line and column of the token are counted from the start of its line
+	lineNum, lineStart, scanned := 1, 0, 0
 	for {
 		token, newRemainder, err := NextToken(remainder, preToken)
 		if err != nil {
 			return nil, err
 		}
+		pos := len(line) - len(newRemainder) - len(token.val)
+		for ; scanned < pos; scanned++ {
+			if line[scanned] == '\n' {
+				lineNum++
+				lineStart = scanned + 1
+			}
+		}
+		token.line, token.col = lineNum, utf8.RuneCountInString(line[lineStart:pos])
+		if token.tokenType == TOK_LPAREN || token.tokenType == TOK_VECTOR {
+			brackets = append(brackets, openBracket{val: token.val, pos: pos})
+		} else if token.tokenType == TOK_RPAREN && len(brackets) > 0 {
+			// (let [x 1) x) -> [ must be closed by ]
+			open := brackets[len(brackets)-1]
+			brackets = brackets[:len(brackets)-1]
+			if closingBracket[open.val] != token.val {
+				return nil, fmt.Errorf("mismatched bracket: %s at position %d closed by %s at position %d", open.val, open.pos, token.val, pos)
+			}
+		}
 		tokens = append(tokens, token)
 		if len(newRemainder) == 0 {
 			return tokens, nil
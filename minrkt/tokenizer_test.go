@@ -64,8 +64,59 @@ func TestTokenizer_NextToken(t *testing.T) {
 		t.Error("expected token type is 11 but got ", token.tokenType)
 	}
 
+	// keywords at the start of a longer identifier
+	if token, _, _ := NextToken("andmap f", preToken); token.tokenType != TOK_IDENTIFIER || token.val != "andmap" {
+		t.Error("expected identifier andmap but got ", token)
+	}
+
+	if token, _, _ := NextToken("null? l", preToken); token.tokenType != TOK_IDENTIFIER || token.val != "null?" {
+		t.Error("expected identifier null? but got ", token)
+	}
+
+	if token, _, _ := NextToken("'(1 2)", preToken); token.tokenType != TOK_QUOTE {
+		t.Error("expected token type is 23 but got ", token.tokenType)
+	}
+
+	if token, _, _ := NextToken("#:scale 2", preToken); token.tokenType != TOK_KEYWORD || token.val != "#:scale" {
+		t.Error("expected keyword #:scale but got ", token)
+	}
+
+	if token, _, _ := NextToken(". rest)", preToken); token.tokenType != TOK_DOT {
+		t.Error("expected token type is 24 but got ", token.tokenType)
+	}
+
+	if token, newRemainder, _ := NextToken(`"a \"b\"" c`, preToken); token.tokenType != TOK_STRING || token.val != `"a \"b\""` {
+		t.Error("expected string token but got ", token)
+	} else if newRemainder != " c" {
+		t.Error("expected remaining string is ", " c", " but got ", newRemainder)
+	}
+
+	if token, _, _ := NextToken("#t", preToken); token.tokenType != TOK_TRUE {
+		t.Error("expected token type is 17 but got ", token.tokenType)
+	}
+
+	if token, _, _ := NextToken("#(1 2)", preToken); token.tokenType != TOK_VECTOR {
+		t.Error("expected token type is 27 but got ", token.tokenType)
+	}
+
+	if token, _, _ := NextToken("`(1 ,x)", preToken); token.tokenType != TOK_QUASIQUOTE {
+		t.Error("expected token type is 30 but got ", token.tokenType)
+	}
+
+	if token, _, _ := NextToken(",@l)", preToken); token.tokenType != TOK_UNQUOTE || token.val != ",@" {
+		t.Error("expected token ,@ but got ", token)
+	}
+
+	if token, _, _ := NextToken("...)", preToken); token.tokenType != TOK_IDENTIFIER || token.val != "..." {
+		t.Error("expected identifier ... but got ", token)
+	}
+
+	if token, _, _ := NextToken("_ x", preToken); token.tokenType != TOK_IDENTIFIER || token.val != "_" {
+		t.Error("expected identifier _ but got ", token)
+	}
+
 	// test error use case
-	if _, _, err := NextToken("ab", preToken); err == nil {
+	if _, _, err := NextToken("\\ab", preToken); err == nil {
 		t.Error("expected error doesn't show up: ", err)
 	}
 
@@ -77,32 +128,73 @@ func TestTokenizer_NextToken(t *testing.T) {
 }
 
 func TestTokenizer_Tokenize(t *testing.T) {
-	tokenLP := Token{TOK_LPAREN, 0, "("}
-	tokenAdd := Token{TOK_ADD, 0, "+"}
-	token2 := Token{TOK_NUM, 2, "2"}
-	token3 := Token{TOK_NUM, 3, "3"}
-	tokenRP := Token{TOK_RPAREN, 0, ")"}
+	tokenLP := Token{TOK_LPAREN, 0, "(", 0, 0}
+	tokenAdd := Token{TOK_ADD, 0, "+", 0, 0}
+	token2 := Token{TOK_NUM, 2, "2", 0, 0}
+	token3 := Token{TOK_NUM, 3, "3", 0, 0}
+	tokenRP := Token{TOK_RPAREN, 0, ")", 0, 0}
 
 	var want = []Token{tokenLP, tokenAdd, token2, token3, tokenRP}
 	if tokens, _ := Tokenize("(+ 2 3)"); !compareTokens(tokens, want) {
 		t.Error("expected token type is", want, " but got", tokens)
 	}
 
-	tokenPlus2 := Token{TOK_NUM, 2, "+2"}
-	tokenMinus3 := Token{TOK_NUM, -3, "-3.0"}
-	tokenSub := Token{TOK_SUB, 0, "-"}
+	tokenPlus2 := Token{TOK_NUM, 2, "+2", 0, 0}
+	tokenMinus3 := Token{TOK_NUM, -3, "-3.0", 0, 0}
+	tokenSub := Token{TOK_SUB, 0, "-", 0, 0}
 	want = []Token{tokenLP, tokenAdd, tokenPlus2, tokenLP, tokenSub, tokenMinus3, tokenRP, tokenRP}
 	if tokens, _ := Tokenize("(+ +2 (- -3.0))"); !compareTokens(tokens, want) {
 		t.Error("expected token type is", want, " but got", tokens)
 	}
 
+	tokenLB := Token{TOK_LPAREN, 0, "[", 0, 0}
+	tokenRB := Token{TOK_RPAREN, 0, "]", 0, 0}
+	tokenLC := Token{TOK_LPAREN, 0, "{", 0, 0}
+	tokenRC := Token{TOK_RPAREN, 0, "}", 0, 0}
+	want = []Token{tokenLB, tokenAdd, token2, tokenLC, tokenSub, tokenMinus3, tokenRC, tokenRB}
+	if tokens, _ := Tokenize("[+ 2 {- -3.0}]"); !compareTokens(tokens, want) {
+		t.Error("expected token type is", want, " but got", tokens)
+	}
+
+	// the numbers printed by formatNumber are read back
+	for _, text := range []string{"+inf.0", "-inf.0", "+nan.0", "1e10", "1.5e-3", "1e+21", ".5", "-.5"} {
+		if tokens, err := Tokenize("(list " + text + ")"); err != nil || len(tokens) != 4 ||
+			tokens[2].tokenType != TOK_NUM || tokens[2].val != text {
+			t.Error("expected the number", text, "but got", tokens, err)
+		}
+	}
+
+	// test mismatched brackets
+	if _, err := Tokenize("(let [x 1) x)"); err == nil {
+		t.Error("expected mismatched bracket error doesn't show up for (let [x 1) x)")
+	} else if err.Error() != "mismatched bracket: [ at position 5 closed by ) at position 9" {
+		t.Error("expected error reports both bracket positions but got", err)
+	}
+	if _, err := Tokenize("{+ 2 3]"); err == nil {
+		t.Error("expected mismatched bracket error doesn't show up for {+ 2 3]")
+	}
 }
 
+// positions are checked separately, see TestTokenizer_Positions
 func compareTokens(token1, token2 []Token) bool {
 	for i, t1 := range token1 {
-		if t1 != token2[i] {
+		t2 := token2[i]
+		if t1.tokenType != t2.tokenType || t1.num != t2.num || t1.val != t2.val {
 			return false
 		}
 	}
 	return true
 }
+
+func TestTokenizer_Positions(t *testing.T) {
+	tokens, _ := Tokenize("(define (f x)\n  (+ x \"é\" 1))")
+	want := [][2]int{{1, 0}, {1, 1}, {1, 8}, {1, 9}, {1, 11}, {1, 12}, {2, 2}, {2, 3}, {2, 5}, {2, 7}, {2, 11}, {2, 12}, {2, 13}}
+	if len(tokens) != len(want) {
+		t.Fatal("expected", len(want), "tokens but got", tokens)
+	}
+	for i, token := range tokens {
+		if token.line != want[i][0] || token.col != want[i][1] {
+			t.Error("expected position of", token.val, "is", want[i], " but got", token.line, token.col)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package minrkt
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// a cons cell, proper lists are chains of pairs ended by null
+type pair struct {
+	car interface{}
+	cdr interface{}
+}
+
+type emptyList struct{}
+
+// the empty list '()
+var null = emptyList{}
+
+type symbol string
+
+// #:scale is stored without its #: prefix
+type keyword string
+
+// value of expressions evaluated only for their side effect, e.g. for-each
+type voidValue struct{}
+
+var void = voidValue{}
+
+// result of (values v ...) other than one value, which is returned as it is
+type multipleValues struct {
+	values []interface{}
+}
+
+// result of the values, e.g. the values given to a continuation
+func newValues(values []interface{}) (interface{}, TypeEnum) {
+	if len(values) == 1 {
+		return values[0], typeOf(values[0])
+	}
+	return &multipleValues{values: values}, TYPE_VALUES
+}
+
+// ResultValues returns the values of a result of Eval: none for (values), two for (values 1 2)
+// and the result itself otherwise
+func ResultValues(v interface{}) []interface{} {
+	if mv, ok := v.(*multipleValues); ok {
+		return mv.values
+	}
+	return []interface{}{v}
+}
+
+// procedure implemented in go, args are already evaluated
+type builtinValue struct {
+	name string
+	fn   func(args []interface{}, p Params) (interface{}, TypeEnum, error)
+}
+
+func typeOf(v interface{}) TypeEnum {
+	switch v.(type) {
+	case int64, float64, *big.Int, *big.Rat:
+		return TYPE_FLOAT64
+	case bool:
+		return TYPE_BOOLEAN
+	case functionValue, builtinValue, caseLambdaValue, continuationValue, *generatorValue, *parameter:
+		return TYPE_PROCEDURE
+	case *pair, emptyList:
+		return TYPE_LIST
+	case symbol:
+		return TYPE_SYMBOL
+	case keyword:
+		return TYPE_KEYWORD
+	case string:
+		return TYPE_STRING
+	case exnValue:
+		return TYPE_EXCEPTION
+	case *promptTag:
+		return TYPE_PROMPT_TAG
+	case *promise:
+		return TYPE_PROMISE
+	case *streamPair, rangeStream:
+		return TYPE_STREAM
+	case *vector:
+		return TYPE_VECTOR
+	case *hashTable:
+		return TYPE_HASH
+	case *structValue, continuationMarks:
+		return TYPE_STRUCT
+	case *outputPort, *inputPort:
+		return TYPE_PORT
+	case eofValue:
+		return TYPE_EOF
+	case *randomGenerator:
+		return TYPE_RANDOM_GENERATOR
+	case char:
+		return TYPE_CHAR
+	case *regexpValue:
+		return TYPE_REGEXP
+	case *multipleValues:
+		return TYPE_VALUES
+	case voidValue:
+		return TYPE_VOID
+	}
+	return TYPE_ERROR
+}
+
+// everything except #f counts as true in conditions
+func isTrue(v interface{}) bool {
+	b, ok := v.(bool)
+	return !ok || b
+}
+
+func isProcedure(v interface{}) bool {
+	return typeOf(v) == TYPE_PROCEDURE
+}
+
+func sliceToList(values []interface{}) interface{} {
+	var list interface{} = null
+	for i := len(values) - 1; i >= 0; i-- {
+		list = &pair{car: values[i], cdr: list}
+	}
+	return list
+}
+
+/*
+convert a proper list into a slice, the second return value is false
+when the value is not a proper list
+*/
+func listToSlice(v interface{}) ([]interface{}, bool) {
+	var values []interface{}
+	for {
+		switch l := v.(type) {
+		case emptyList:
+			return values, true
+		case *pair:
+			values = append(values, l.car)
+			v = l.cdr
+		default:
+			return nil, false
+		}
+	}
+}
+
+// FormatValue prints a value the way the racket REPL does, e.g. '(1 2 3)
+func FormatValue(v interface{}) string {
+	if mv, ok := v.(*multipleValues); ok {
+		// each value on its own line like the racket REPL
+		var lines []string
+		for _, value := range mv.values {
+			lines = append(lines, FormatValue(value))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return newPrinter(v, false).print(v)
+}
+
+// the way write prints values, strings are quoted
+func formatDatum(v interface{}) string {
+	return newPrinter(v, false).format(v)
+}
+
+// the way display prints values, strings and characters are printed as they are
+func displayDatum(v interface{}) string {
+	return newPrinter(v, true).format(v)
+}
+
+/*
+A printer prints a value which can contain itself, e.g. a vector set as its own
+element: the value is labeled #0= where it's printed first, and #0# stands for
+it inside, like '#0=#(1 #0#). Only the values in a cycle are labeled.
+*/
+type printer struct {
+	display bool
+	// the pairs, vectors, hashes and structs reached again from their own elements
+	cycles map[interface{}]bool
+	labels map[interface{}]int
+}
+
+func newPrinter(v interface{}, display bool) *printer {
+	pr := &printer{display: display, labels: make(map[interface{}]int)}
+	switch v.(type) {
+	case *pair, *vector, *hashTable, *structValue:
+		pr.cycles = make(map[interface{}]bool)
+		findCycles(v, make(map[interface{}]bool), make(map[interface{}]bool), pr.cycles)
+	}
+	return pr
+}
+
+// visiting holds the values whose elements are being visited, visited the ones already done
+func findCycles(v interface{}, visiting, visited, cycles map[interface{}]bool) {
+	switch got := v.(type) {
+	case *pair:
+		// the pairs of a list are visited in a loop, a long list doesn't nest calls
+		var chain []interface{}
+		var rest interface{} = got
+		for {
+			l, ok := rest.(*pair)
+			if !ok || visited[l] || visiting[l] {
+				break
+			}
+			visiting[l] = true
+			chain = append(chain, l)
+			rest = l.cdr
+		}
+		if l, ok := rest.(*pair); !ok {
+			findCycles(rest, visiting, visited, cycles)
+		} else if visiting[l] {
+			cycles[l] = true
+		}
+		for _, l := range chain {
+			findCycles(l.(*pair).car, visiting, visited, cycles)
+		}
+		for _, l := range chain {
+			delete(visiting, l)
+			visited[l] = true
+		}
+		return
+	case *vector, *hashTable, *structValue:
+		if visiting[got] {
+			cycles[got] = true
+			return
+		} else if visited[got] {
+			return
+		}
+	default:
+		return
+	}
+	visiting[v] = true
+	switch got := v.(type) {
+	case *vector:
+		for _, element := range got.elements {
+			findCycles(element, visiting, visited, cycles)
+		}
+	case *hashTable:
+		for _, e := range got.root.entries(nil) {
+			findCycles(e.key, visiting, visited, cycles)
+			findCycles(e.value, visiting, visited, cycles)
+		}
+	case *structValue:
+		if got.stype.transparent {
+			for _, f := range got.fields {
+				findCycles(f, visiting, visited, cycles)
+			}
+		}
+	}
+	delete(visiting, v)
+	visited[v] = true
+}
+
+// prefixes the printing of a value in a cycle with its label, or gives #0# when it was already printed
+func (pr *printer) labeled(v interface{}, format func() string) string {
+	if !pr.cycles[v] {
+		return format()
+	}
+	if n, ok := pr.labels[v]; ok {
+		return "#" + strconv.Itoa(n) + "#"
+	}
+	n := len(pr.labels)
+	pr.labels[v] = n
+	return "#" + strconv.Itoa(n) + "=" + format()
+}
+
+// the way the REPL prints a value, lists, symbols, vectors and hashes are quoted
+func (pr *printer) print(v interface{}) string {
+	switch got := v.(type) {
+	case *pair, emptyList, symbol, keyword, *vector, *hashTable:
+		return pr.labeled(v, func() string { return "'" + pr.datum(v) })
+	case *structValue:
+		return pr.labeled(v, func() string { return pr.formatStruct(got, true) })
+	}
+	return pr.datum(v)
+}
+
+// the way write and display print a value
+func (pr *printer) format(v interface{}) string {
+	switch v.(type) {
+	case *pair, *vector, *hashTable, *structValue:
+		return pr.labeled(v, func() string { return pr.datum(v) })
+	}
+	return pr.datum(v)
+}
+
+func (pr *printer) datum(v interface{}) string {
+	switch got := v.(type) {
+	case int64, float64, *big.Int, *big.Rat:
+		return formatNumber(got)
+	case bool:
+		if got {
+			return "#t"
+		}
+		return "#f"
+	case symbol:
+		return string(got)
+	case keyword:
+		return "#:" + string(got)
+	case string:
+		if pr.display {
+			return got
+		}
+		return strconv.Quote(got)
+	case char:
+		if pr.display {
+			return string(rune(got))
+		}
+		return formatChar(got)
+	case exnValue:
+		return "#<" + string(got.kind) + ">"
+	case emptyList:
+		return "()"
+	case *pair:
+		elements := []string{pr.format(got.car)}
+		rest := got.cdr
+		for {
+			// a pair of the list in a cycle is printed with its label after a dot, e.g. #0=(1 2 . #0#)
+			if l, ok := rest.(*pair); ok && !pr.cycles[l] {
+				elements = append(elements, pr.format(l.car))
+				rest = l.cdr
+			} else {
+				break
+			}
+		}
+		// improper list, e.g. (1 . 2)
+		if _, ok := rest.(emptyList); !ok {
+			elements = append(elements, ".", pr.format(rest))
+		}
+		return "(" + strings.Join(elements, " ") + ")"
+	case *vector:
+		elements := make([]string, len(got.elements))
+		for i, element := range got.elements {
+			elements[i] = pr.format(element)
+		}
+		return "#(" + strings.Join(elements, " ") + ")"
+	case *hashTable:
+		return pr.formatHash(got)
+	case *structValue:
+		return pr.formatStruct(got, false)
+	case continuationMarks:
+		return "#<continuation-mark-set>"
+	case functionValue:
+		if got.name == "" {
+			return "#<procedure>"
+		}
+		return "#<procedure:" + got.name + ">"
+	case builtinValue:
+		return "#<procedure:" + got.name + ">"
+	case caseLambdaValue:
+		if got.name == "" {
+			return "#<procedure>"
+		}
+		return "#<procedure:" + got.name + ">"
+	case continuationValue:
+		return "#<continuation>"
+	case *promptTag:
+		return "#<continuation-prompt-tag:" + got.name + ">"
+	case *generatorValue:
+		return "#<procedure:generator>"
+	case *parameter:
+		return "#<procedure:" + got.name + ">"
+	case *promise:
+		return "#<promise>"
+	case *streamPair, rangeStream:
+		return "#<stream>"
+	case *outputPort:
+		return "#<output-port:" + got.name + ">"
+	case *inputPort:
+		return "#<input-port:" + got.name + ">"
+	case eofValue:
+		return "#<eof>"
+	case *randomGenerator:
+		return "#<pseudo-random-generator>"
+	case *regexpValue:
+		return formatRegexp(got)
+	case voidValue:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+/*
+Format string of format and error: ~a displays the next value, ~s writes it,
+~v prints it like the REPL, ~n or ~% is a newline and ~~ is ~
+*/
+func racketFormat(proc string, format string, args []interface{}) (string, error) {
+	var sb strings.Builder
+	next := 0
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '~' {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		if i+1 >= len(runes) {
+			return "", newRacketError(ERR_CONTRACT, proc, "ill-formed pattern string")
+		}
+		i++
+		switch runes[i] {
+		case 'n', '%':
+			sb.WriteString("\n")
+			continue
+		case '~':
+			sb.WriteString("~")
+			continue
+		case 'a', 'A', 's', 'S', 'v', 'V', 'e', 'E':
+		default:
+			return "", newRacketError(ERR_CONTRACT, proc, "ill-formed pattern string")
+		}
+		if next >= len(args) {
+			return "", newRacketError(ERR_CONTRACT, proc, "format string requires more arguments than given")
+		}
+		switch runes[i] {
+		case 'a', 'A':
+			sb.WriteString(displayDatum(args[next]))
+		case 's', 'S':
+			sb.WriteString(formatDatum(args[next]))
+		default:
+			sb.WriteString(FormatValue(args[next]))
+		}
+		next++
+	}
+	if next != len(args) {
+		return "", newRacketError(ERR_CONTRACT, proc, "format string requires %d arguments, given %d", next, len(args))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,239 @@
+package minrkt
+
+import (
+	"fmt"
+)
+
+// fixed size array, literals like #(1 2 3) are immutable
+type vector struct {
+	elements []interface{}
+	mutable  bool
+}
+
+// e.g. (vector-ref v 5) -> index is out of range, index: 5, valid range: [0, 2]
+func indexError(proc string, index int, v *vector) *RacketError {
+	if len(v.elements) == 0 {
+		return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "index is out of range for empty vector",
+			Fields: []ErrorField{{"index", fmt.Sprint(index)}}}
+	}
+	return &RacketError{Kind: ERR_CONTRACT, Proc: proc, Message: "index is out of range",
+		Fields: []ErrorField{{"index", fmt.Sprint(index)}, {"valid range", fmt.Sprintf("[0, %d]", len(v.elements)-1)},
+			{"vector", FormatValue(v)}}}
+}
+
+func vectorArg(proc string, arg interface{}) (*vector, error) {
+	v, ok := arg.(*vector)
+	if !ok {
+		return nil, contractError(proc, "vector?", arg)
+	}
+	return v, nil
+}
+
+func mutableVectorArg(proc string, arg interface{}) (*vector, error) {
+	v, ok := arg.(*vector)
+	if !ok || !v.mutable {
+		return nil, contractError(proc, "(and/c vector? (not/c immutable?))", arg)
+	}
+	return v, nil
+}
+
+// vector and index arguments of vector-ref and vector-set!
+func vectorIndexArgs(proc string, args []interface{}, mutable bool) (*vector, int, error) {
+	var v *vector
+	var err error
+	if mutable {
+		v, err = mutableVectorArg(proc, args[0])
+	} else {
+		v, err = vectorArg(proc, args[0])
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	i, err := naturalArg(proc, args[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	if i >= len(v.elements) {
+		return nil, 0, indexError(proc, i, v)
+	}
+	return v, i, nil
+}
+
+// iteration of in-vector and of vectors used as sequences
+type vectorIterator struct {
+	v *vector
+	i int
+}
+
+func (it *vectorIterator) next(p Params) (interface{}, bool, error) {
+	if it.i >= len(it.v.elements) {
+		return nil, false, nil
+	}
+	it.i++
+	return it.v.elements[it.i-1], true, nil
+}
+
+func init() {
+	defineBuiltin("vector", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return &vector{elements: append([]interface{}{}, args...), mutable: true}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("vector-immutable", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		return &vector{elements: append([]interface{}{}, args...)}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("make-vector", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (make-vector size [fill]), fill is 0 by default
+		if err := checkArity("make-vector", args, 1, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		n, err := naturalArg("make-vector", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		var fill interface{} = int64(0)
+		if len(args) == 2 {
+			fill = args[1]
+		}
+		v := &vector{elements: make([]interface{}, n), mutable: true}
+		for i := range v.elements {
+			v.elements[i] = fill
+		}
+		return v, TYPE_VECTOR, nil
+	})
+	defineBuiltin("vector?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		_, ok := args[0].(*vector)
+		return ok, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("immutable?", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("immutable?", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		switch v := args[0].(type) {
+		case *vector:
+			return !v.mutable, TYPE_BOOLEAN, nil
+		case *hashTable:
+			return !v.mutable, TYPE_BOOLEAN, nil
+		}
+		return false, TYPE_BOOLEAN, nil
+	})
+	defineBuiltin("vector-length", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector-length", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := vectorArg("vector-length", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return int64(len(v.elements)), TYPE_FLOAT64, nil
+	})
+	defineBuiltin("vector-ref", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector-ref", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, i, err := vectorIndexArgs("vector-ref", args, false)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return v.elements[i], typeOf(v.elements[i]), nil
+	})
+	defineBuiltin("vector-set!", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector-set!", args, 3, 3); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, i, err := vectorIndexArgs("vector-set!", args, true)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v.elements[i] = args[2]
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("vector-fill!", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector-fill!", args, 2, 2); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := mutableVectorArg("vector-fill!", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		for i := range v.elements {
+			v.elements[i] = args[1]
+		}
+		return void, TYPE_VOID, nil
+	})
+	defineBuiltin("vector->list", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector->list", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := vectorArg("vector->list", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return sliceToList(v.elements), TYPE_LIST, nil
+	})
+	defineBuiltin("list->vector", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("list->vector", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		elements, ok := listToSlice(args[0])
+		if !ok {
+			return nil, TYPE_ERROR, contractError("list->vector", "list?", args[0])
+		}
+		return &vector{elements: elements, mutable: true}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("vector->immutable-vector", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector->immutable-vector", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := vectorArg("vector->immutable-vector", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if !v.mutable {
+			return v, TYPE_VECTOR, nil
+		}
+		return &vector{elements: append([]interface{}{}, v.elements...)}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("vector-copy", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("vector-copy", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		v, err := vectorArg("vector-copy", args[0])
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return &vector{elements: append([]interface{}{}, v.elements...), mutable: true}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("vector-map", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		// (vector-map f v ...) like map, all the vectors have the same length
+		if err := checkArity("vector-map", args, 2, -1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		mapArgs := []interface{}{args[0]}
+		for _, arg := range args[1:] {
+			v, err := vectorArg("vector-map", arg)
+			if err != nil {
+				return nil, TYPE_ERROR, err
+			}
+			if len(v.elements) != len(args[1].(*vector).elements) {
+				return nil, TYPE_ERROR, newRacketError(ERR_CONTRACT, "vector-map", "all vectors must have same size")
+			}
+			mapArgs = append(mapArgs, sliceToList(v.elements))
+		}
+		results, err := mapLists("vector-map", mapArgs, p)
+		if err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return &vector{elements: results, mutable: true}, TYPE_VECTOR, nil
+	})
+	defineBuiltin("in-vector", func(args []interface{}, p Params) (interface{}, TypeEnum, error) {
+		if err := checkArity("in-vector", args, 1, 1); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		if _, err := vectorArg("in-vector", args[0]); err != nil {
+			return nil, TYPE_ERROR, err
+		}
+		return args[0], TYPE_VECTOR, nil
+	})
+}
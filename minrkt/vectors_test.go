@@ -0,0 +1,73 @@
+package minrkt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	p := newTestParams()
+	evalLine(p, "(define v (make-vector 3 'a))")
+	evalLine(p, "(define lit #(1 (2 x) \"s\"))")
+	cases := []struct {
+		line string
+		want string
+	}{
+		{"#(1 2 3)", "'#(1 2 3)"},
+		{"lit", "'#(1 (2 x) \"s\")"},
+		{"'(a #(b))", "'(a #(b))"},
+		{"(vector 1 (+ 1 1))", "'#(1 2)"},
+		{"v", "'#(a a a)"},
+		{"(make-vector 2)", "'#(0 0)"},
+		{"(vector-length v)", "3"},
+		{"(vector-ref lit 1)", "'(2 x)"},
+		{"(begin (vector-set! v 0 'b) v)", "'#(b a a)"},
+		{"(begin (vector-fill! v 0) v)", "'#(0 0 0)"},
+		{"(vector->list #(1 2))", "'(1 2)"},
+		{"(list->vector '(1 2))", "'#(1 2)"},
+		{"(vector-map + #(1 2) #(10 20))", "'#(11 22)"},
+		{"(vector? lit)", "#t"},
+		{"(vector? '(1))", "#f"},
+		{"(immutable? lit)", "#t"},
+		{"(immutable? v)", "#f"},
+		{"(immutable? (vector->immutable-vector v))", "#t"},
+		{"(immutable? (vector-copy lit))", "#f"},
+		{"(for/list ([x (in-vector #(1 2))]) (* x 10))", "'(10 20)"},
+		{"(for/sum ([x #(1 2 3)]) x)", "6"},
+	}
+	for _, c := range cases {
+		if result, _, err := evalLine(p, c.line); err != nil {
+			t.Error("unexpected evaluation error for", c.line, ":", err)
+		} else if got := FormatValue(result); got != c.want {
+			t.Error("expected evaluated result of", c.line, "is", c.want, " but got", got)
+		}
+	}
+
+	// vectors are compared element by element
+	a, _, _ := evalLine(p, "#(1 (2))")
+	b, _, _ := evalLine(p, "(vector 1 '(2))")
+	if !isEqual(a, b) || isEqual(a, p.MapIdentifier["v"]) {
+		t.Error("expected #(1 (2)) to be equal to (vector 1 '(2)) only")
+	}
+
+	errorCases := []struct {
+		line string
+		want string
+	}{
+		{"(vector-ref #(1 2 3) 5)", "vector-ref: index is out of range\n  index: 5\n  valid range: [0, 2]\n  vector: '#(1 2 3)"},
+		{"(vector-ref (vector) 0)", "vector-ref: index is out of range for empty vector\n  index: 0"},
+		{"(vector-ref #(1) -1)", "vector-ref: contract violation\n  expected: exact-nonnegative-integer?\n  given: -1"},
+		{"(vector-set! lit 0 1)", "vector-set!: contract violation\n  expected: (and/c vector? (not/c immutable?))\n  given: '#(1 (2 x) \"s\")"},
+		{"(vector-length '(1))", "vector-length: contract violation\n  expected: vector?\n  given: '(1)"},
+		{"(vector-map + #(1) #(1 2))", "vector-map: all vectors must have same size"},
+	}
+	for _, c := range errorCases {
+		_, _, err := evalLine(p, c.line)
+		var re *RacketError
+		if !errors.As(err, &re) {
+			t.Error("expected a RacketError for", c.line, " but got", err)
+		} else if err.Error() != c.want {
+			t.Errorf("expected error message of %s is %q but got %q", c.line, c.want, err.Error())
+		}
+	}
+}
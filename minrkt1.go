@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 
@@ -13,8 +14,8 @@ func main() {
 	colorReset := "\033[0m"
 	fmt.Println("Welcome to minimalistic racket phase 1 !")
 	scanner := bufio.NewScanner(os.Stdin)
-	mapIdentifier := make(map[string]interface{})
-	callStack := make([]map[string]interface{}, 5)
+	// the definitions and the ambient settings like the current output port live as long as the session
+	in := minrkt.NewInterpreter(minrkt.WithFilePolicy(minrkt.AllowAllFiles))
 	for {
 		fmt.Print("> ")
 		if !scanner.Scan() {
@@ -32,15 +33,26 @@ func main() {
 			fmt.Println(colorRed, "error in parser phase: ", err, colorReset)
 			continue
 		}
-		if result, t, err := root.Eval(minrkt.Params{MapIdentifier: mapIdentifier, CallStack: callStack}); err != nil {
+		if result, t, err := in.EvalExp(root); err != nil {
 			fmt.Println(colorRed, "error in evaluation phase: ", err, colorReset)
-		} else if t == minrkt.TYPE_FLOAT64 {
-			fmt.Println("Result is: ", result.(float64))
+			// which function calls led to the error
+			var racketErr *minrkt.RacketError
+			if errors.As(err, &racketErr) && len(racketErr.Context) > 0 {
+				fmt.Println(colorRed, racketErr.ContextString(), colorReset)
+			}
 		} else if t == minrkt.TYPE_DEFINE {
 			// define statement: we don't need to do anything
-			fmt.Println("define :", mapIdentifier)
 		} else if t == minrkt.TYPE_NOTIFICATION {
 			fmt.Println(result)
+		} else if t == minrkt.TYPE_VOID {
+			// e.g. for-each: nothing to print
+		} else if t == minrkt.TYPE_VALUES {
+			// each value on its own line, nothing for (values)
+			for _, v := range minrkt.ResultValues(result) {
+				fmt.Println("Result is: ", minrkt.FormatValue(v))
+			}
+		} else if t != minrkt.TYPE_BOOLEAN {
+			fmt.Println("Result is: ", minrkt.FormatValue(result))
 		} else {
 			boolRes := result.(bool)
 			var stringRes string